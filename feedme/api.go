@@ -0,0 +1,150 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"encoding/json"
+	"github.com/velour/feedme/webfeed"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ApiSummaryRunes is the maximum length, in runes, of an article summary
+// returned by the API.
+const apiSummaryRunes = 280
+
+func init() {
+	http.HandleFunc("/api/articles", apiHandler(handleAPIArticles))
+	http.HandleFunc("/api/feeds", apiHandler(handleAPIFeeds))
+}
+
+// An apiArticle is the JSON form of an Article.
+type apiArticle struct {
+	Title   string    `json:"title"`
+	Link    string    `json:"link"`
+	Summary string    `json:"summary"`
+	When    time.Time `json:"when"`
+	// Feed is the URL of the article's feed.
+	Feed string `json:"feed"`
+	Read bool   `json:"read"`
+}
+
+// HandleAPIArticles writes the user's latest articles as a JSON array,
+// newest first. If the since form value is given, as an RFC 3339 time,
+// the articles since that time are written instead.
+func handleAPIArticles(w http.ResponseWriter, r *http.Request, c appengine.Context, key *datastore.Key) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	uinfo, err := loadUserInfo(c, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	since, err := parseSince(r.FormValue("since"), time.Now(), uinfo.latest())
+	if err != nil {
+		http.Error(w, "bad since time: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	articles, errs := articlesSince(c, uinfo, since)
+	for _, err := range errs {
+		c.Errorf("%s", err)
+	}
+	sort.Sort(articles)
+	if err := setRead(c, key, articles); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, apiArticles(articles))
+}
+
+// ParseSince returns the time given by an RFC 3339 since value, or, if
+// the value is empty, the start at time now of a latest view that goes
+// back the given duration.
+func parseSince(s string, now time.Time, latest time.Duration) (time.Time, error) {
+	if s == "" {
+		return now.Add(-latest), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// ApiArticles returns the JSON forms of the articles.
+func apiArticles(articles Articles) []apiArticle {
+	as := make([]apiArticle, len(articles))
+	for i, a := range articles {
+		as[i] = apiArticle{
+			Title:   a.Title,
+			Link:    a.Link,
+			Summary: webfeed.TextSummary(a.DescriptionData, apiSummaryRunes),
+			When:    a.When,
+			Read:    a.Read,
+		}
+		if a.Key != nil && a.Key.Parent() != nil {
+			as[i].Feed = a.Key.Parent().StringID()
+		}
+	}
+	return as
+}
+
+// An apiFeed is the JSON form of a feedListEntry.
+type apiFeed struct {
+	Title      string    `json:"title"`
+	Url        string    `json:"url"`
+	Link       string    `json:"link"`
+	LastFetch  time.Time `json:"lastFetch"`
+	EncodedKey string    `json:"encodedKey"`
+	Unread     int       `json:"unread"`
+}
+
+// HandleAPIFeeds writes the user's feeds as a JSON array, in the order of
+// the manage page.
+func handleAPIFeeds(w http.ResponseWriter, r *http.Request, c appengine.Context, key *datastore.Key) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	uinfo, err := loadUserInfo(c, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	feeds, err := userFeedList(c, key, uinfo)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, apiFeeds(feeds))
+}
+
+// ApiFeeds returns the JSON forms of the feeds.
+func apiFeeds(feeds feedList) []apiFeed {
+	fs := make([]apiFeed, len(feeds))
+	for i, f := range feeds {
+		fs[i] = apiFeed{
+			Title:      f.Title,
+			Url:        f.Url,
+			Link:       f.Link,
+			LastFetch:  f.LastFetch,
+			EncodedKey: f.EncodedKey,
+			Unread:     f.Unread,
+		}
+	}
+	return fs
+}
+
+// WriteJSON writes v to w as JSON.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,118 @@
+package feedme
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		since string
+		t     time.Time
+		err   bool
+	}{
+		{since: "", t: now.Add(-latestDuration)},
+		{since: "2019-12-31T00:00:00Z", t: time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{since: "yesterday", err: true},
+	}
+	for _, test := range tests {
+		got, err := parseSince(test.since, now, latestDuration)
+		if test.err {
+			if err == nil {
+				t.Errorf("Expected an error for since %q", test.since)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for since %q: %s", test.since, err)
+			continue
+		}
+		if !got.Equal(test.t) {
+			t.Errorf("Expected since %q to be %s, got %s", test.since, test.t, got)
+		}
+	}
+}
+
+func TestAPIArticlesJSON(t *testing.T) {
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	articles := Articles{{
+		Title:           "Title",
+		Link:            "http://example.com/a",
+		DescriptionData: []byte("<p>Some <b>text</b></p>"),
+		When:            when,
+		Read:            true,
+	}}
+
+	w := httptest.NewRecorder()
+	writeJSON(w, apiArticles(articles))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %s", ct)
+	}
+
+	var got []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := []map[string]interface{}{{
+		"title":   "Title",
+		"link":    "http://example.com/a",
+		"summary": "Some text",
+		"when":    "2020-01-02T03:04:05Z",
+		"feed":    "",
+		"read":    true,
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	w = httptest.NewRecorder()
+	writeJSON(w, apiArticles(nil))
+	if body := w.Body.String(); body != "[]" {
+		t.Errorf("Expected an empty array, got %s", body)
+	}
+}
+
+func TestAPIFeedsJSON(t *testing.T) {
+	fetched := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	infos := []FeedInfo{
+		{Url: "http://example.com/b.xml", Title: "B", Link: "http://example.com/b", LastFetch: fetched},
+		{Url: "http://example.com/a.xml", Title: "A", Link: "http://example.com/a"},
+	}
+	var feeds feedList
+	for i, f := range infos {
+		feeds = append(feeds, newFeedListEntry(f, fmt.Sprintf("key%d", i), "", i*3))
+	}
+
+	w := httptest.NewRecorder()
+	writeJSON(w, apiFeeds(feeds))
+	var got []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := []map[string]interface{}{
+		{
+			"title":      "B",
+			"url":        "http://example.com/b.xml",
+			"link":       "http://example.com/b",
+			"lastFetch":  "2020-01-02T03:04:05Z",
+			"encodedKey": "key0",
+			"unread":     0.0,
+		},
+		{
+			"title":      "A",
+			"url":        "http://example.com/a.xml",
+			"link":       "http://example.com/a",
+			"lastFetch":  "0001-01-01T00:00:00Z",
+			"encodedKey": "key1",
+			"unread":     3.0,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
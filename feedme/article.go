@@ -0,0 +1,168 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"appengine/user"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+)
+
+func init() {
+	http.HandleFunc("/article/", handleArticle)
+}
+
+// HandleArticle shows the single article whose encoded key ends the path,
+// with links to the previous and next articles. They are the articles
+// around it in its feed or, if the view form value is "latest", in the
+// user's latest view, in the order given by the sort form value or else
+// in the user's preferred order.
+func handleArticle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	key, err := datastore.DecodeKey(path.Base(r.URL.Path))
+	if err != nil || key.Kind() != articleKind {
+		http.NotFound(w, r)
+		return
+	}
+	uinfo, err := getUserInfo(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	i := uinfo.feedIndex(key.Parent())
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	order, err := parseSortOrder(r.FormValue("sort"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The view and sort values are kept by the previous and next links.
+	q := url.Values{}
+	if order != "" {
+		q.Set("sort", order)
+	} else {
+		order = uinfo.SortOrder
+	}
+	view := r.FormValue("view")
+	switch view {
+	case "":
+	case "latest":
+		q.Set("view", view)
+	default:
+		http.Error(w, "unknown view "+view, http.StatusBadRequest)
+		return
+	}
+
+	var page = struct {
+		Logout  string
+		Title   string
+		FeedKey string
+		Errors  []error
+		Article Article
+		// Prev and Next are the URLs of the previous and next
+		// articles, if any.
+		Prev, Next string
+	}{}
+	page.Logout, err = user.LogoutURL(c, "/")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var f FeedInfo
+	if err := datastore.Get(c, key.Parent(), &f); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page.Title = uinfo.title(i, f.Title)
+	page.FeedKey = key.Parent().Encode()
+
+	shown := make(Articles, 1)
+	if err := datastore.Get(c, key, &shown[0]); err == datastore.ErrNoSuchEntity {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	shown[0].Key = key
+	shown.setOrigin(page.Title)
+	if err := setRead(c, userInfoKey(c), shown); err != nil {
+		page.Errors = append(page.Errors, err)
+	}
+	page.Article = shown[0]
+
+	var articles Articles
+	if view == "latest" {
+		var errs []error
+		articles, errs = latestArticles(c, uinfo)
+		page.Errors = append(page.Errors, errs...)
+		if uinfo.CollapseDuplicates {
+			articles = collapseDuplicates(articles)
+		}
+		sort.Sort(sortedArticles{articles, order})
+	} else {
+		// The feed's articles are already in order, since they all
+		// have the same origin.
+		articles, _, err = f.articlesSince(c, articleRange{order: order})
+		if err != nil {
+			page.Errors = append(page.Errors, err)
+		}
+		articles = uinfo.unmuted(articles)
+	}
+	prev, next := adjacentArticles(articles, articleIndex(articles, key))
+	page.Prev = articleURL(prev, q)
+	page.Next = articleURL(next, q)
+
+	executeTemplate(w, c, "articlepage.html", page)
+}
+
+// ArticleIndex returns the index of the article with the given key, or -1
+// if it is not among the articles.
+func articleIndex(articles Articles, key *datastore.Key) int {
+	for i, a := range articles {
+		if a.Key.Equal(key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// AdjacentArticles returns the articles before and after the ith article.
+// Either is nil if the ith article is the first or the last, and both are
+// nil if i is -1.
+func adjacentArticles(articles Articles, i int) (prev, next *Article) {
+	if i < 0 || i >= len(articles) {
+		return nil, nil
+	}
+	if i > 0 {
+		prev = &articles[i-1]
+	}
+	if i < len(articles)-1 {
+		next = &articles[i+1]
+	}
+	return prev, next
+}
+
+// ArticleURL returns the URL of the page of the article with the given
+// query, or the empty string if the article is nil.
+func articleURL(a *Article, q url.Values) string {
+	if a == nil {
+		return ""
+	}
+	u := "/article/" + a.EncodedKey()
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	return u
+}
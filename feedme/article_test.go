@@ -0,0 +1,34 @@
+package feedme
+
+import (
+	"testing"
+)
+
+func TestAdjacentArticles(t *testing.T) {
+	articles := Articles{{Title: "a"}, {Title: "b"}, {Title: "c"}}
+	tests := []struct {
+		articles   Articles
+		i          int
+		prev, next string
+	}{
+		{articles, 0, "", "b"},
+		{articles, 1, "a", "c"},
+		{articles, 2, "b", ""},
+		{articles, -1, "", ""},
+		{articles[:1], 0, "", ""},
+		{nil, -1, "", ""},
+	}
+	title := func(a *Article) string {
+		if a == nil {
+			return ""
+		}
+		return a.Title
+	}
+	for _, test := range tests {
+		prev, next := adjacentArticles(test.articles, test.i)
+		if title(prev) != test.prev || title(next) != test.next {
+			t.Errorf("Expected %d of %d to have previous [%s] and next [%s], got [%s] and [%s]",
+				test.i, len(test.articles), test.prev, test.next, title(prev), title(next))
+		}
+	}
+}
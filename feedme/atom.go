@@ -0,0 +1,79 @@
+package feedme
+
+import (
+	"appengine"
+	"github.com/velour/feedme/webfeed"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AtomPath is the path prefix of the Atom feeds of the users' latest
+// articles. The rest of the path is one of the user's API tokens, since
+// feed readers cannot log in.
+const atomPath = "/feed.atom/"
+
+func init() {
+	http.HandleFunc(atomPath, handleAtom)
+}
+
+// HandleAtom writes the latest articles of the user that owns the API
+// token in the path as an Atom feed.
+func handleAtom(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	tok := strings.TrimPrefix(r.URL.Path, atomPath)
+	if tok == "" || strings.Contains(tok, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	c := appengine.NewContext(r)
+	key, err := tokenUser(datastoreTokens{c}, tok)
+	if err == errBadToken {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	uinfo, err := loadUserInfo(c, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	articles, errs := articlesSince(c, uinfo, time.Now().Add(-uinfo.latest()))
+	for _, err := range errs {
+		c.Errorf("%s", err)
+	}
+
+	root := "http://" + r.Host + "/"
+	f := atomFeed("Feed Me! Latest Articles", root, root+strings.TrimPrefix(r.URL.Path, "/"), articles)
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if err := f.WriteAtom(w); err != nil {
+		c.Errorf("failed to write the Atom feed: %s", err)
+	}
+}
+
+// AtomFeed returns a feed with the given title, link, and self link
+// holding the articles, newest first. The feed's updated time is that of
+// its newest article.
+func atomFeed(title, link, self string, articles Articles) webfeed.Feed {
+	sort.Sort(articles)
+	f := webfeed.Feed{Title: title, Link: link, Self: self}
+	if len(articles) > 0 {
+		f.Updated = articles[0].When
+	}
+	for _, a := range articles {
+		f.Entries = append(f.Entries, webfeed.Entry{
+			Title:   a.Title,
+			Link:    a.Link,
+			Content: a.DescriptionData,
+			When:    a.When,
+		})
+	}
+	return f
+}
@@ -0,0 +1,47 @@
+package feedme
+
+import (
+	"bytes"
+	"github.com/velour/feedme/webfeed"
+	"testing"
+	"time"
+)
+
+func TestAtomFeed(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	articles := Articles{
+		{Title: "Older", Link: "http://example.com/older", DescriptionData: []byte("<p>older</p>"), When: now.Add(-time.Hour)},
+		{Title: "Newer", Link: "http://example.com/newer", DescriptionData: []byte("<p>newer</p>"), When: now},
+	}
+	f := atomFeed("Latest", "http://feedme.example.com/", "http://feedme.example.com/feed.atom/tok", articles)
+
+	var b bytes.Buffer
+	if err := f.WriteAtom(&b); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got, err := webfeed.Read(&b)
+	if err != nil {
+		t.Fatalf("Unexpected error reading the written feed: %s\n%s", err, b.String())
+	}
+	if got.Title != "Latest" || got.Link != "http://feedme.example.com/" {
+		t.Errorf("Expected title Latest and link http://feedme.example.com/, got %s and %s", got.Title, got.Link)
+	}
+	if !got.Updated.Equal(now) {
+		t.Errorf("Expected the feed to be updated at %s, got %s", now, got.Updated)
+	}
+	if len(got.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(got.Entries))
+	}
+	for i, title := range []string{"Newer", "Older"} {
+		e := got.Entries[i]
+		if e.Title != title {
+			t.Errorf("Expected entry %d to be %s, got %s", i, title, e.Title)
+		}
+		if e.ID != e.Link || e.Link == "" {
+			t.Errorf("Expected entry %d to have its link as its ID, got ID %s and link %s", i, e.ID, e.Link)
+		}
+	}
+	if !bytes.Contains(got.Entries[0].Content, []byte("newer")) {
+		t.Errorf("Expected the newer entry's content, got [%s]", got.Entries[0].Content)
+	}
+}
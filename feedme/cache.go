@@ -0,0 +1,136 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"appengine/memcache"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	// LatestCacheTTL is how long the latest articles of a user are
+	// cached.
+	latestCacheTTL = maxCacheDuration / 2
+)
+
+// An articleCache caches Articles by key. Get returns false if the
+// articles are not cached or cannot be read.
+type articleCache interface {
+	get(key string) (Articles, bool)
+	set(key string, as Articles)
+}
+
+// A memcacheArticles is an articleCache using memcache. Errors other than
+// cache misses are logged.
+type memcacheArticles struct {
+	c appengine.Context
+}
+
+func (m memcacheArticles) get(key string) (Articles, bool) {
+	var as Articles
+	_, err := memcache.Gob.Get(m.c, key, &as)
+	if err != nil && err != memcache.ErrCacheMiss {
+		m.c.Errorf("failed to read cached articles: %s", err)
+	}
+	return as, err == nil
+}
+
+func (m memcacheArticles) set(key string, as Articles) {
+	err := memcache.Gob.Set(m.c, &memcache.Item{Key: key, Object: as, Expiration: latestCacheTTL})
+	if err != nil {
+		m.c.Errorf("failed to cache articles: %s", err)
+	}
+}
+
+// LatestArticles returns the articles of the current user's latest view,
+// from memcache if they are cached.
+func latestArticles(c appengine.Context, uinfo UserInfo) (Articles, []error) {
+	return cachedArticles(memcacheArticles{c}, latestCacheKey(c, uinfo), func() (Articles, []error) {
+		return articlesSince(c, uinfo, time.Now().Add(-uinfo.latest()))
+	})
+}
+
+// CachedArticles returns the articles cached under key, or, if they are
+// not cached, the articles returned by load. Articles from load are
+// cached only if load reported no errors.
+func cachedArticles(cache articleCache, key string, load func() (Articles, []error)) (Articles, []error) {
+	if as, ok := cache.get(key); ok {
+		return as, nil
+	}
+	as, errs := load()
+	if len(errs) == 0 {
+		cache.set(key, as)
+	}
+	return as, errs
+}
+
+// LatestCacheKey returns the memcache key of the current user's latest
+// articles. It depends on the versions of the user and of each of their
+// feeds, so that incrementing any of them invalidates the cached articles.
+func latestCacheKey(c appengine.Context, uinfo UserInfo) string {
+	keys := latestVersionKeys(userInfoKey(c), uinfo.Feeds)
+	items, err := memcache.GetMulti(c, keys)
+	if err != nil {
+		c.Errorf("failed to read the latest articles versions: %s", err)
+	}
+	versions := make([]string, len(keys))
+	for i, k := range keys {
+		if it, ok := items[k]; ok {
+			versions[i] = string(it.Value)
+		}
+	}
+	return versionedKey(keys, versions)
+}
+
+// LatestVersionKeys returns the memcache keys of the versions that the
+// latest articles of the user with the given feeds depend on: the user's
+// own, then each feed's.
+func latestVersionKeys(user *datastore.Key, feeds []*datastore.Key) []string {
+	keys := []string{latestVersionKey(user)}
+	for _, f := range feeds {
+		keys = append(keys, latestVersionKey(f))
+	}
+	return keys
+}
+
+// LatestVersionKey returns the memcache key of the version of the latest
+// articles that depend on the user or feed with the given key.
+func latestVersionKey(k *datastore.Key) string {
+	h := sha256.Sum256([]byte(k.String()))
+	return "latestVersion/" + hex.EncodeToString(h[:])
+}
+
+// VersionedKey returns the memcache key of latest articles that depend
+// on the version keys, which have the given versions. Missing versions
+// are empty.
+func versionedKey(keys, versions []string) string {
+	h := sha256.New()
+	for i, k := range keys {
+		h.Write([]byte(k + "=" + versions[i] + "\n"))
+	}
+	return "latest/" + hex.EncodeToString(h.Sum(nil))
+}
+
+// InvalidateLatest removes the current user's cached latest articles.
+func invalidateLatest(c appengine.Context) {
+	incrementLatestVersion(c, userInfoKey(c))
+}
+
+// InvalidateFeedLatest removes the cached latest articles of every user
+// subscribed to the feed.
+func invalidateFeedLatest(c appengine.Context, feed *datastore.Key) {
+	incrementLatestVersion(c, feed)
+}
+
+// IncrementLatestVersion increments the version of the latest articles
+// that depend on the user or feed with the given key. A missing version
+// starts from the current time, so that it does not repeat a version
+// that was evicted.
+func incrementLatestVersion(c appengine.Context, k *datastore.Key) {
+	_, err := memcache.Increment(c, latestVersionKey(k), 1, uint64(time.Now().UnixNano()))
+	if err != nil {
+		c.Errorf("failed to invalidate the latest articles: %s", err)
+	}
+}
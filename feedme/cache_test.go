@@ -0,0 +1,164 @@
+package feedme
+
+import (
+	"appengine/datastore"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// A mapCache is an articleCache backed by a map.
+type mapCache map[string]Articles
+
+func (m mapCache) get(key string) (Articles, bool) {
+	as, ok := m[key]
+	return as, ok
+}
+
+func (m mapCache) set(key string, as Articles) {
+	m[key] = as
+}
+
+func TestCachedArticles(t *testing.T) {
+	cache := make(mapCache)
+	loads := 0
+	load := func() (Articles, []error) {
+		loads++
+		return Articles{{Title: "A"}, {Title: "B"}}, nil
+	}
+
+	first, errs := cachedArticles(cache, "k", load)
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	second, errs := cachedArticles(cache, "k", load)
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if loads != 1 {
+		t.Errorf("Expected the second request to be cached, loaded %d times", loads)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the cached articles %v, got %v", first, second)
+	}
+
+	if _, errs := cachedArticles(cache, "other", load); len(errs) != 0 || loads != 2 {
+		t.Errorf("Expected a different key to load, loaded %d times", loads)
+	}
+}
+
+// A ttlCache is an articleCache backed by a map whose entries expire
+// latestCacheTTL after they are set, as they do in memcache.
+type ttlCache struct {
+	now     time.Time
+	entries map[string]Articles
+	expires map[string]time.Time
+}
+
+func (m *ttlCache) get(key string) (Articles, bool) {
+	if !m.now.Before(m.expires[key]) {
+		return nil, false
+	}
+	as, ok := m.entries[key]
+	return as, ok
+}
+
+func (m *ttlCache) set(key string, as Articles) {
+	m.entries[key] = as
+	m.expires[key] = m.now.Add(latestCacheTTL)
+}
+
+func TestCachedArticlesTTL(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := &ttlCache{now: start, entries: make(map[string]Articles), expires: make(map[string]time.Time)}
+	datastoreReads := 0
+	load := func() (Articles, []error) {
+		datastoreReads++
+		return Articles{{Title: "A"}}, nil
+	}
+
+	tests := []struct {
+		after time.Duration
+		reads int
+	}{
+		{0, 1},
+		{time.Second, 1},
+		{latestCacheTTL - time.Second, 1},
+		{latestCacheTTL, 2},
+		{latestCacheTTL + time.Second, 2},
+	}
+	for _, test := range tests {
+		cache.now = start.Add(test.after)
+		if _, errs := cachedArticles(cache, "k", load); len(errs) != 0 {
+			t.Fatalf("Unexpected errors: %v", errs)
+		}
+		if datastoreReads != test.reads {
+			t.Errorf("Expected %d datastore reads after %s, got %d", test.reads, test.after, datastoreReads)
+		}
+	}
+}
+
+// A brokenCache is an articleCache whose entries cannot be read, as when
+// they fail to unmarshal.
+type brokenCache struct{}
+
+func (brokenCache) get(string) (Articles, bool) { return nil, false }
+func (brokenCache) set(string, Articles)        {}
+
+func TestCachedArticlesBrokenCache(t *testing.T) {
+	load := func() (Articles, []error) {
+		return Articles{{Title: "A"}}, nil
+	}
+	as, errs := cachedArticles(brokenCache{}, "k", load)
+	if len(errs) != 0 || len(as) != 1 || as[0].Title != "A" {
+		t.Errorf("Expected the articles from the datastore, got %v, %v", as, errs)
+	}
+}
+
+func TestCachedArticlesErrors(t *testing.T) {
+	cache := make(mapCache)
+	loads := 0
+	load := func() (Articles, []error) {
+		loads++
+		return Articles{{Title: "A"}}, []error{errors.New("a feed failed")}
+	}
+	for i := 0; i < 2; i++ {
+		if _, errs := cachedArticles(cache, "k", load); len(errs) != 1 {
+			t.Errorf("Expected the load error, got %v", errs)
+		}
+	}
+	if loads != 2 {
+		t.Errorf("Expected articles loaded with errors not to be cached, loaded %d times", loads)
+	}
+}
+
+func TestLatestVersionedKey(t *testing.T) {
+	user := datastore.NewKey(nil, userKind, "alice", 0, nil)
+	other := datastore.NewKey(nil, userKind, "bob", 0, nil)
+	a := datastore.NewKey(nil, feedKind, "http://a.com/feed", 0, nil)
+	b := datastore.NewKey(nil, feedKind, "http://b.com/feed", 0, nil)
+
+	keys := latestVersionKeys(user, []*datastore.Key{a})
+	if exp := []string{latestVersionKey(user), latestVersionKey(a)}; !reflect.DeepEqual(keys, exp) {
+		t.Fatalf("Expected version keys %v, got %v", exp, keys)
+	}
+	if latestVersionKey(b) == latestVersionKey(a) {
+		t.Errorf("Expected feeds to have different version keys")
+	}
+
+	key := versionedKey(keys, []string{"", ""})
+	if k := versionedKey(keys, []string{"", ""}); k != key {
+		t.Errorf("Expected the same versions to give the same key, got %s and %s", key, k)
+	}
+	if k := versionedKey(keys, []string{"", "1"}); k == key {
+		t.Errorf("Expected a new feed version to change the key")
+	}
+	if k := versionedKey(keys, []string{"1", ""}); k == key {
+		t.Errorf("Expected a new user version to change the key")
+	}
+	otherKeys := latestVersionKeys(other, []*datastore.Key{a})
+	if k := versionedKey(otherKeys, []string{"", ""}); k == key {
+		t.Errorf("Expected users with the same feeds to have different keys")
+	}
+}
@@ -0,0 +1,109 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// ArticleRetention is how long articles are kept by default before
+	// they are deleted by the cleanup cron job.
+	articleRetention = 90 * 24 * time.Hour
+
+	// KeptArticles is the number of each feed's newest articles that are
+	// kept however old they are. It is the number that a refresh keeps,
+	// since a refresh deletes the stored articles no longer in the feed,
+	// so that the cleanup never deletes articles that a refresh would
+	// keep. The cleanup removes the articles that refreshes leave behind:
+	// those pushed by WebSub hubs beyond the newest, and those of feeds
+	// that are no longer refreshed or whose fetches keep failing.
+	keptArticles = maxNewArticles
+)
+
+func init() {
+	http.HandleFunc("/cron/cleanup", handleCleanup)
+}
+
+// HandleCleanup deletes the articles of every feed that are older than
+// the retention period, except for each feed's newest keptArticles. The
+// retention period is given in days by the days form value, and is
+// articleRetention if there is none. Only App Engine cron requests are
+// served.
+func handleCleanup(w http.ResponseWriter, r *http.Request) {
+	if !isCron(r) {
+		http.Error(w, "only cron requests are allowed", http.StatusForbidden)
+		return
+	}
+	retention, err := parseRetention(r.FormValue("days"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c := appengine.NewContext(r)
+	feeds, err := datastore.NewQuery(feedKind).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	cutoff := time.Now().Add(-retention)
+	var errs errorList
+	for _, feed := range feeds {
+		n, err := cleanupArticles(c, feed, cutoff)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", feed.StringID(), err))
+			continue
+		}
+		if n > 0 {
+			c.Debugf("%s: deleted %d old articles\n", feed.StringID(), n)
+		}
+	}
+	if len(errs) > 0 {
+		http.Error(w, errs.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ParseRetention returns the retention period given in days, or
+// articleRetention if s is empty.
+func parseRetention(s string) (time.Duration, error) {
+	if s == "" {
+		return articleRetention, nil
+	}
+	days, err := strconv.Atoi(s)
+	if err != nil || days < 1 {
+		return 0, fmt.Errorf("bad number of days: %s", s)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// CleanupArticles deletes the feed's articles from before the cutoff
+// that are not among its newest keptArticles, and returns the number
+// deleted.
+func cleanupArticles(c appengine.Context, feed *datastore.Key, cutoff time.Time) (int, error) {
+	q := datastore.NewQuery(articleKind).Ancestor(feed).Order("-When").Project("When")
+	articles, err := getArticles(c, q)
+	if err != nil {
+		return 0, err
+	}
+	old := articleKeys(expiredArticles(articles, cutoff, keptArticles))
+	if err := deleteArticles(c, old); err != nil {
+		return 0, err
+	}
+	return len(old), setArticleCount(c, feed, articleCount(len(articles), 0, len(old)))
+}
+
+// ExpiredArticles returns the articles, sorted newest first, that are
+// from before the cutoff and are not among the newest keep articles.
+func expiredArticles(articles Articles, cutoff time.Time, keep int) Articles {
+	var old Articles
+	for i, a := range articles {
+		if i >= keep && a.When.Before(cutoff) {
+			old = append(old, a)
+		}
+	}
+	return old
+}
@@ -0,0 +1,75 @@
+package feedme
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestExpiredArticles(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	cutoff := now.Add(-articleRetention)
+	tests := []struct {
+		name    string
+		ages    []time.Duration
+		keep    int
+		expired []string
+	}{
+		{
+			name:    "recent",
+			ages:    []time.Duration{time.Hour, 24 * time.Hour, 30 * 24 * time.Hour},
+			keep:    1,
+			expired: nil,
+		},
+		{
+			name:    "old beyond the newest",
+			ages:    []time.Duration{time.Hour, 100 * 24 * time.Hour, 200 * 24 * time.Hour},
+			keep:    1,
+			expired: []string{"1", "2"},
+		},
+		{
+			name:    "newest kept however old",
+			ages:    []time.Duration{100 * 24 * time.Hour, 200 * 24 * time.Hour, 300 * 24 * time.Hour},
+			keep:    2,
+			expired: []string{"2"},
+		},
+		{
+			name:    "fewer than kept",
+			ages:    []time.Duration{100 * 24 * time.Hour},
+			keep:    2,
+			expired: nil,
+		},
+	}
+	for _, test := range tests {
+		var articles Articles
+		for i, age := range test.ages {
+			articles = append(articles, Article{Title: fmt.Sprint(i), When: now.Add(-age)})
+		}
+		var expired []string
+		for _, a := range expiredArticles(articles, cutoff, test.keep) {
+			expired = append(expired, a.Title)
+		}
+		if fmt.Sprint(expired) != fmt.Sprint(test.expired) {
+			t.Errorf("%s: expected %v to expire, got %v", test.name, test.expired, expired)
+		}
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		days string
+		d    time.Duration
+		err  bool
+	}{
+		{days: "", d: articleRetention},
+		{days: "30", d: 30 * 24 * time.Hour},
+		{days: "0", err: true},
+		{days: "forever", err: true},
+	}
+	for _, test := range tests {
+		d, err := parseRetention(test.days)
+		if (err != nil) != test.err || d != test.d {
+			t.Errorf("Expected %q days to be %s, %t, got %s, %v", test.days, test.d, test.err, d, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"appengine/user"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"sync"
+)
+
+const (
+	csrfSecretKind = "CSRFSecret"
+
+	// CSRFField is the name of the form value holding the CSRF token.
+	csrfField = "csrf"
+)
+
+// A CSRFSecret is the server secret from which the users' CSRF tokens are
+// derived. There is a single CSRFSecret, created when it is first needed.
+type CSRFSecret struct {
+	Secret string `datastore:",noindex"`
+}
+
+var (
+	// CSRFSecretMu guards csrfSecret, the cached Secret of the
+	// CSRFSecret.
+	csrfSecretMu sync.Mutex
+	csrfSecret   string
+)
+
+// GetCSRFSecret returns the server's CSRF secret, creating it if it does
+// not exist.
+func getCSRFSecret(c appengine.Context) (string, error) {
+	csrfSecretMu.Lock()
+	defer csrfSecretMu.Unlock()
+	if csrfSecret != "" {
+		return csrfSecret, nil
+	}
+
+	key := datastore.NewKey(c, csrfSecretKind, "secret", 0, nil)
+	var s CSRFSecret
+	err := datastore.RunInTransaction(c, func(c appengine.Context) error {
+		err := datastore.Get(c, key, &s)
+		if err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		if s.Secret, err = newToken(); err != nil {
+			return err
+		}
+		_, err = datastore.Put(c, key, &s)
+		return err
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	csrfSecret = s.Secret
+	return csrfSecret, nil
+}
+
+// CSRFToken returns the current user's CSRF token, or the empty string if
+// there is no current user.
+func csrfToken(c appengine.Context) (string, error) {
+	u := user.Current(c)
+	if u == nil {
+		return "", nil
+	}
+	secret, err := getCSRFSecret(c)
+	if err != nil {
+		return "", err
+	}
+	return csrfTokenFor(secret, u.ID), nil
+}
+
+// CSRFTokenFor returns the CSRF token of the user with the given ID: the
+// HMAC of the ID with the server's secret.
+func csrfTokenFor(secret, id string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCSRF checks the CSRF token of a form posted by the current user.
+// If it is not the user's token, VerifyCSRF responds with 403 Forbidden
+// and returns false. Requests from the task queue are not checked; App
+// Engine removes their header from external requests.
+func verifyCSRF(w http.ResponseWriter, r *http.Request, c appengine.Context) bool {
+	if r.Header.Get("X-AppEngine-QueueName") != "" {
+		return true
+	}
+	tok, err := csrfToken(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	return checkCSRF(w, r, tok)
+}
+
+// CheckCSRF responds with 403 Forbidden and returns false if the csrf form
+// value of the request is not tok. An empty tok matches nothing.
+func checkCSRF(w http.ResponseWriter, r *http.Request, tok string) bool {
+	got := r.FormValue(csrfField)
+	if tok == "" || !hmac.Equal([]byte(got), []byte(tok)) {
+		http.Error(w, "bad CSRF token", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// CSRFFuncs returns the template functions that embed tok in forms.
+// CsrfField is a hidden input holding the token.
+func csrfFuncs(tok string) template.FuncMap {
+	return template.FuncMap{
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="` + csrfField + `" value="` + template.HTMLEscapeString(tok) + `">`)
+		},
+	}
+}
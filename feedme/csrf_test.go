@@ -0,0 +1,72 @@
+package feedme
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRFTokenFor(t *testing.T) {
+	tok := csrfTokenFor("secret", "alice")
+	if tok == "" {
+		t.Fatalf("Expected a token")
+	}
+	if got := csrfTokenFor("secret", "alice"); got != tok {
+		t.Errorf("Expected the same token [%s], got [%s]", tok, got)
+	}
+	if got := csrfTokenFor("secret", "bob"); got == tok {
+		t.Errorf("Expected another user to get another token")
+	}
+	if got := csrfTokenFor("other", "alice"); got == tok {
+		t.Errorf("Expected another secret to give another token")
+	}
+}
+
+func TestCheckCSRF(t *testing.T) {
+	tok := csrfTokenFor("secret", "alice")
+	tests := []struct {
+		name string
+		form url.Values
+		tok  string
+		ok   bool
+	}{
+		{name: "valid", form: url.Values{"csrf": {tok}}, tok: tok, ok: true},
+		{name: "missing", form: url.Values{}, tok: tok},
+		{name: "invalid", form: url.Values{"csrf": {csrfTokenFor("secret", "bob")}}, tok: tok},
+		{name: "no user", form: url.Values{"csrf": {""}}, tok: ""},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("POST", "/update", strings.NewReader(test.form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		if ok := checkCSRF(w, r, test.tok); ok != test.ok {
+			t.Errorf("%s: expected %t, got %t", test.name, test.ok, ok)
+		}
+		if !test.ok && w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected status %d, got %d", test.name, http.StatusForbidden, w.Code)
+		}
+	}
+}
+
+func TestCSRFField(t *testing.T) {
+	tmpl := template.Must(template.New("t").Funcs(funcs).Parse(`<form method="post">{{csrfField}}</form>`))
+	for _, tok := range []string{"abc", "def"} {
+		// Each execution uses a new copy, so tmpl can be copied again.
+		c, err := withFuncs(tmpl, csrfFuncs(tok))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		var buf bytes.Buffer
+		if err := c.Execute(&buf, nil); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		want := `<form method="post"><input type="hidden" name="csrf" value="` + tok + `"></form>`
+		if buf.String() != want {
+			t.Errorf("Expected [%s], got [%s]", want, buf.String())
+		}
+	}
+}
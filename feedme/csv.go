@@ -0,0 +1,57 @@
+package feedme
+
+import (
+	"appengine"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"time"
+)
+
+func init() {
+	http.HandleFunc("/export.csv", handleExportCSV)
+}
+
+// HandleExportCSV writes the current user's subscriptions as a CSV file,
+// with a row for each feed, sorted by title as on the feeds page.
+func handleExportCSV(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	u, err := getUserInfo(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	feeds, err := userFeedList(c, userInfoKey(c), u)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="feedme.csv"`)
+	if err := writeCSV(w, feeds); err != nil {
+		c.Errorf("failed to write CSV: %s", err)
+	}
+}
+
+// WriteCSV writes a header row and then a row for each of the feeds,
+// giving its title, URL, link, the time it was last fetched, and its
+// category. Feeds that have never been fetched have an empty time.
+func writeCSV(w io.Writer, feeds feedList) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"title", "url", "link", "lastFetch", "category"})
+	for _, f := range feeds {
+		lastFetch := ""
+		if !f.LastFetch.IsZero() {
+			lastFetch = f.LastFetch.UTC().Format(time.RFC3339)
+		}
+		cw.Write([]string{f.Title, f.Url, f.Link, lastFetch, f.Category})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,34 @@
+package feedme
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriteCSV(t *testing.T) {
+	fetched := time.Date(2020, 3, 4, 5, 6, 7, 0, time.FixedZone("EST", -5*60*60))
+	feeds := feedList{
+		{Title: "A, \"quoted\" blog", Url: "http://a.com/feed", Link: "http://a.com/", LastFetch: fetched, Category: "News"},
+		{Title: "New", Url: "http://b.com/feed"},
+	}
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, feeds); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Unexpected error reading [%s]: %s", buf.String(), err)
+	}
+	exp := [][]string{
+		{"title", "url", "link", "lastFetch", "category"},
+		{"A, \"quoted\" blog", "http://a.com/feed", "http://a.com/", "2020-03-04T10:06:07Z", "News"},
+		{"New", "http://b.com/feed", "", "", ""},
+	}
+	if !reflect.DeepEqual(rows, exp) {
+		t.Errorf("Expected rows %q, got %q", exp, rows)
+	}
+}
@@ -0,0 +1,217 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"appengine/taskqueue"
+	"appengine/user"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// DiscoverLimit is the maximum number of feeds shown on the discover page.
+	discoverLimit = 25
+)
+
+func init() {
+	http.HandleFunc("/discover", handleDiscover)
+	http.HandleFunc("/cron/subscribers", handleCronSubscribers)
+}
+
+type discoverEntry struct {
+	Title       string
+	Url         string
+	Link        string
+	Subscribers int
+	EncodedKey  string
+}
+
+// HandleDiscover lists the feeds with the most subscribers that the
+// current user is not subscribed to. A POST either subscribes the user
+// to the feed with the encoded key in the feed form value, or sets
+// whether the user's subscriptions are counted in the discover view.
+func handleDiscover(w http.ResponseWriter, r *http.Request) {
+	c := appengine.NewContext(r)
+	if r.Method == "POST" && !verifyCSRF(w, r, c) {
+		return
+	}
+
+	switch {
+	case r.Method == "POST" && r.FormValue("feed") != "":
+		k, err := datastore.DecodeKey(r.FormValue("feed"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		var f FeedInfo
+		if err := datastore.Get(c, k, &f); err != nil {
+			http.Error(w, k.StringID()+" failed to load from the datastore: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := subscribe(c, f, ""); err != nil {
+			http.Error(w, "failed to subscribe "+f.Url+": "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		invalidateLatest(c)
+		http.Redirect(w, r, "/discover", http.StatusFound)
+		return
+
+	case r.Method == "POST":
+		if err := setNoDiscover(c, r.FormValue("nodiscover") != ""); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/discover", http.StatusFound)
+		return
+
+	case r.Method != "GET":
+		methodNotAllowed(w, "GET", "POST")
+		return
+	}
+
+	var page struct {
+		Title      string
+		Logout     string
+		NoDiscover bool
+		Feeds      []discoverEntry
+	}
+	page.Title = "Discover"
+
+	u, err := getUserInfo(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page.NoDiscover = u.NoDiscover
+
+	mine := make(map[string]bool, len(u.Feeds))
+	for _, k := range u.Feeds {
+		mine[k.StringID()] = true
+	}
+
+	var infos []FeedInfo
+	q := datastore.NewQuery(feedKind).Filter("Subscribers >", 0).Order("-Subscribers").Limit(discoverLimit + len(u.Feeds))
+	keys, err := q.GetAll(c, &infos)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i, f := range infos {
+		if mine[f.Url] || len(page.Feeds) >= discoverLimit {
+			continue
+		}
+		page.Feeds = append(page.Feeds, discoverEntry{
+			Title:       f.Title,
+			Url:         f.Url,
+			Link:        f.Link,
+			Subscribers: f.Subscribers,
+			EncodedKey:  keys[i].Encode(),
+		})
+	}
+
+	page.Logout, err = user.LogoutURL(c, "/")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	executeTemplate(w, c, "discover.html", page)
+}
+
+// SetNoDiscover sets whether the current user's subscriptions are counted
+// in the discover view. The subscriber counts of their feeds are updated
+// by a recount task that is added in the same transaction.
+func setNoDiscover(c appengine.Context, noDiscover bool) error {
+	return datastore.RunInTransaction(c, func(c appengine.Context) error {
+		u, err := getUserInfo(c)
+		if err != nil {
+			return err
+		}
+		if u.NoDiscover == noDiscover {
+			return nil
+		}
+		u.NoDiscover = noDiscover
+		if _, err := datastore.Put(c, userInfoKey(c), &u); err != nil {
+			return err
+		}
+		return addRecountTask(c)
+	}, nil)
+}
+
+// AddRecountTask adds a task to recount the subscribers of every feed.
+// When called in a transaction, the task is only added if the
+// transaction commits, and the task queue retries it until it succeeds.
+func addRecountTask(c appengine.Context) error {
+	t := taskqueue.NewPOSTTask("/cron/subscribers", nil)
+	_, err := taskqueue.Add(c, t, "")
+	return err
+}
+
+// HandleCronSubscribers sets the Subscribers of every feed to the number
+// of users subscribed to it that are counted in the discover view. The
+// counts are recomputed from scratch, so it backfills feeds stored
+// before they were counted and corrects any that have drifted, and it
+// can be retried safely. Only App Engine cron and task queue requests
+// are served.
+func handleCronSubscribers(w http.ResponseWriter, r *http.Request) {
+	if !isCron(r) && !isTask(r) {
+		http.Error(w, "only cron and task queue requests are allowed", http.StatusForbidden)
+		return
+	}
+
+	c := appengine.NewContext(r)
+	var users []UserInfo
+	if _, err := datastore.NewQuery(userKind).GetAll(c, &users); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	counts := countSubscribers(users)
+
+	feeds, err := datastore.NewQuery(feedKind).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var errs errorList
+	for _, feed := range feeds {
+		if err := setSubscribers(c, feed, counts[feed.StringID()]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", feed.StringID(), err))
+		}
+	}
+	if len(errs) > 0 {
+		http.Error(w, errs.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CountSubscribers returns the number of the users subscribed to each
+// feed, by URL, that are counted in the discover view. Feeds pending
+// removal are not counted.
+func countSubscribers(users []UserInfo) map[string]int {
+	counts := make(map[string]int)
+	for _, u := range users {
+		if u.NoDiscover {
+			continue
+		}
+		for _, k := range u.Feeds {
+			counts[k.StringID()]++
+		}
+	}
+	return counts
+}
+
+// SetSubscribers sets the Subscribers of the feed to n.
+func setSubscribers(c appengine.Context, feed *datastore.Key, n int) error {
+	return datastore.RunInTransaction(c, func(c appengine.Context) error {
+		var f FeedInfo
+		if err := datastore.Get(c, feed, &f); err != nil {
+			return err
+		}
+		if f.Subscribers == n {
+			return nil
+		}
+		f.Subscribers = n
+		_, err := datastore.Put(c, feed, &f)
+		return err
+	}, nil)
+}
@@ -0,0 +1,32 @@
+package feedme
+
+import (
+	"appengine/datastore"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCountSubscribers(t *testing.T) {
+	a := datastore.NewKey(nil, feedKind, "http://a.com/feed", 0, nil)
+	b := datastore.NewKey(nil, feedKind, "http://b.com/feed", 0, nil)
+	users := []UserInfo{
+		{Feeds: []*datastore.Key{a, b}},
+		{Feeds: []*datastore.Key{a}, PendingRemoval: []*datastore.Key{b}},
+		{Feeds: []*datastore.Key{a, b}, UserPrefs: UserPrefs{NoDiscover: true}},
+		{},
+	}
+	want := map[string]int{a.StringID(): 2, b.StringID(): 1}
+	if counts := countSubscribers(users); !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v, got %v", want, counts)
+	}
+}
+
+func TestCronSubscribersGuard(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleCronSubscribers(w, httptest.NewRequest("POST", "/cron/subscribers", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
@@ -4,24 +4,59 @@ import (
 	"appengine"
 	"appengine/datastore"
 	"appengine/urlfetch"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
 	"errors"
+	"fmt"
 	"github.com/velour/feedme/webfeed"
 	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	// MaxCacheDuration is the length of time to store a feed before refetching it
+	// MaxCacheDuration is the length of time to store a feed before refetching it,
+	// unless the feed has its own RefreshInterval.
 	maxCacheDuration = 25 * time.Minute
 
+	// MinRefreshInterval and MaxRefreshInterval bound the RefreshInterval
+	// of a feed. The cron refresh runs every minRefreshInterval.
+	minRefreshInterval = 10 * time.Minute
+	maxRefreshInterval = 7 * 24 * time.Hour
+
 	// MaxNewArticles is the maximum number of articles stored when fetching
 	// new articles from a feed.
 	maxNewArticles = 10
 
+	// MaxBatch is the maximum number of entities written or deleted by
+	// a single datastore call.
+	maxBatch = 500
+
+	// CompleteGracePeriod is how long a feed that its publisher has
+	// marked as complete continues to be refreshed.
+	completeGracePeriod = 7 * 24 * time.Hour
+
+	// MaxRetryDelay is the longest that the refresh of a failing feed
+	// is put off.
+	maxRetryDelay = 24 * time.Hour
+
+	// MaxConsecutiveFailures is the number of consecutive failed fetches
+	// after which a feed is disabled.
+	maxConsecutiveFailures = 10
+
 	articleKind = "Article"
 	feedKind    = "Feed"
+
+	// UserAgent is the User-Agent of the requests that fetch feeds and
+	// articles' pages.
+	userAgent = "feedme/1.0 (+https://github.com/velour/feedme)"
 )
 
 // An Article is a single article from a feed.
@@ -30,14 +65,34 @@ type Article struct {
 	Link            string `datastore:",noindex"`
 	DescriptionData []byte `datastore:",noindex"`
 	When            time.Time
+	// InsertedAt is the time at which the article was first stored.
+	InsertedAt time.Time
 	// OriginTitle is the title of the feed from which this article originated.
 	OriginTitle string `datastore:",noindex"`
+	// EntryID is the ID that the feed gave the article, if any.
+	EntryID string `datastore:",noindex"`
+	// FullContent is whether DescriptionData is the main content of the
+	// article's page, fetched in place of the feed's summary.
+	FullContent bool `datastore:",noindex"`
+
+	// Key is the article's datastore key, and Read is whether the
+	// current user has read it. Neither is stored.
+	Key  *datastore.Key `datastore:"-"`
+	Read bool           `datastore:"-"`
+	// AlsoIn holds the titles of the other feeds that carried the
+	// article, if duplicates of it were collapsed. It is not stored.
+	AlsoIn []string `datastore:"-"`
 }
 
 func (a Article) Description() template.HTML {
 	return template.HTML(a.DescriptionData)
 }
 
+// EncodedKey returns the article's encoded datastore key.
+func (a Article) EncodedKey() string {
+	return a.Key.Encode()
+}
+
 // StringID returns a unique string that can be used to identify this
 // article in a datastore.Key.
 func (a Article) StringID() string {
@@ -59,6 +114,59 @@ func (as Articles) Swap(i, j int) {
 	as[i], as[j] = as[j], as[i]
 }
 
+// The orders in which articles can be shown. NewestFirst is the order of
+// Articles.Less.
+const (
+	newestFirst = "newest"
+	oldestFirst = "oldest"
+	// ByFeed groups the articles by the title of their feed, with
+	// each group newest first.
+	byFeed = "feed"
+)
+
+// ParseSortOrder returns the sort order named by s, which is empty for
+// the user's preferred order.
+func parseSortOrder(s string) (string, error) {
+	switch s {
+	case "", newestFirst, oldestFirst, byFeed:
+		return s, nil
+	}
+	return "", errors.New("unknown sort order " + s)
+}
+
+// SortedArticles are Articles sorted in the given order.
+type sortedArticles struct {
+	Articles
+	order string
+}
+
+func (s sortedArticles) Less(i, j int) bool {
+	return articleLess(s.order, s.Articles[i], s.Articles[j])
+}
+
+// ArticleLess returns whether a is shown before b in the given order.
+func articleLess(order string, a, b Article) bool {
+	switch order {
+	case oldestFirst:
+		return a.When.Before(b.When)
+	case byFeed:
+		fa, fb := strings.ToLower(a.OriginTitle), strings.ToLower(b.OriginTitle)
+		if fa != fb {
+			return fa < fb
+		}
+	}
+	return a.When.After(b.When)
+}
+
+// SetOrigin sets the OriginTitle of the articles. Stored articles keep
+// the title that their feed had when they were stored, so it is replaced
+// by the feed's current title when they are shown.
+func (as Articles) setOrigin(title string) {
+	for i := range as {
+		as[i].OriginTitle = title
+	}
+}
+
 // FeedInfo is the information stored for each feed.
 type FeedInfo struct {
 	// The URL from which to fetch the Atom or RSS.
@@ -70,27 +178,277 @@ type FeedInfo struct {
 	// Refs is the number of users currently subscribed to the feed.
 	Refs int `datastore:",noindex"`
 
+	// Subscribers is the number of users subscribed to the feed that
+	// have not opted out of the discover view.
+	Subscribers int
+
 	// LastFetch is the last time the feed was fetched from the source.
-	LastFetch time.Time `datastore:",noindex"`
+	// It is indexed so that stale feeds can be queried.
+	LastFetch time.Time
+
+	// Blocked is true if the publisher has asked not to be listed.
+	Blocked bool `datastore:",noindex"`
+
+	// Complete is true if the publisher has said that the feed will
+	// have no new articles. CompletedAt is when it was first seen
+	// to be complete.
+	Complete    bool      `datastore:",noindex"`
+	CompletedAt time.Time `datastore:",noindex"`
+
+	// LastError describes a problem with the feed's last fetch, and
+	// LastErrorTime is when it happened.
+	LastError     string    `datastore:",noindex"`
+	LastErrorTime time.Time `datastore:",noindex"`
+
+	// FetchUrl is the URL to which Url permanently redirects, if any.
+	// The feed is fetched from FetchUrl, but it is still identified by Url.
+	FetchUrl string `datastore:",noindex"`
+
+	// ETag and LastModified are the validators returned with the last
+	// fetched body of the feed. They are sent with the next fetch so
+	// that the body is not sent again if it has not changed.
+	ETag         string `datastore:",noindex"`
+	LastModified string `datastore:",noindex"`
+
+	// ConsecutiveFailures is the number of fetches of the feed that have
+	// failed since the last that succeeded. The feed is not refreshed
+	// again before NextRetry.
+	ConsecutiveFailures int       `datastore:",noindex"`
+	NextRetry           time.Time `datastore:",noindex"`
+
+	// RetryAfter is the time before which the feed's server asked, with
+	// a Retry-After header, not to be fetched again. Being asked to wait
+	// is not counted as a failure.
+	RetryAfter time.Time `datastore:",noindex"`
+
+	// ArticleCount is the number of the feed's articles that are stored.
+	ArticleCount int `datastore:",noindex"`
+
+	// Disabled is true if the feed has failed maxConsecutiveFailures
+	// times in a row. Disabled feeds are not refreshed until a user
+	// enables them again.
+	Disabled bool `datastore:",noindex"`
+
+	// Hub is the URL of the WebSub hub that the feed gives, if any,
+	// and HubTopic is the URL that identifies the feed at the hub.
+	Hub      string `datastore:",noindex"`
+	HubTopic string `datastore:",noindex"`
+
+	// WebSubSecret is the secret with which the hub signs the content
+	// it pushes, and WebSubExpires is when the hub's subscription
+	// expires.
+	WebSubSecret  string    `datastore:",noindex"`
+	WebSubExpires time.Time `datastore:",noindex"`
+
+	// FetchFull is true if the full content of the feed's new articles
+	// that have only a short summary is fetched from their pages.
+	FetchFull bool `datastore:",noindex"`
+
+	// RefreshInterval is how long after it is fetched the feed is
+	// refreshed again. If it is zero, maxCacheDuration is used.
+	RefreshInterval time.Duration `datastore:",noindex"`
+}
+
+// RefreshEvery returns how long after it is fetched the feed is refreshed
+// again.
+func (f FeedInfo) refreshEvery() time.Duration {
+	if f.RefreshInterval == 0 {
+		return maxCacheDuration
+	}
+	return f.RefreshInterval
 }
 
-// GetArticles returns all articles for a feed, refreshing it if necessary.
-func (f FeedInfo) articlesSince(c appengine.Context, t time.Time) (articles Articles, err error) {
+// Stale returns whether the feed needs to be refreshed at now.
+func (f FeedInfo) stale(now time.Time) bool {
+	return now.Sub(f.LastFetch) > f.refreshEvery()
+}
+
+// ErrNotModified is returned when fetching a feed that has not changed
+// since it was last fetched.
+var errNotModified = errors.New("not modified")
+
+// A retryAfterError is returned when fetching a feed whose server
+// responded 429 or 503 with a Retry-After header.
+type retryAfterError struct {
+	status string
+	// Until is the time before which the server asked not to be
+	// fetched again.
+	until time.Time
+}
+
+func (e retryAfterError) Error() string {
+	return e.status + ", retry after " + e.until.UTC().Format(http.TimeFormat)
+}
+
+// ParseRetryAfter returns the time given by a Retry-After header value
+// received at now, which is either a number of seconds or an HTTP date.
+// The time is at most maxRetryDelay after now. False is returned if the
+// value is not valid.
+func parseRetryAfter(s string, now time.Time) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	var t time.Time
+	if secs, err := strconv.Atoi(s); err == nil {
+		if secs < 0 {
+			return time.Time{}, false
+		}
+		t = now.Add(time.Duration(secs) * time.Second)
+	} else if t, err = http.ParseTime(s); err != nil {
+		return time.Time{}, false
+	}
+	if max := now.Add(maxRetryDelay); t.After(max) {
+		t = max
+	}
+	return t, true
+}
+
+// FetchURL returns the URL from which the feed should be fetched.
+func (f FeedInfo) fetchURL() string {
+	if f.FetchUrl != "" {
+		return f.FetchUrl
+	}
+	return f.Url
+}
+
+// An articleRange selects some of a feed's articles, oldest first if order
+// is oldestFirst and newest first otherwise: those from at or after since
+// that follow the time after in the order, and at most limit of them. Each
+// is ignored if it is zero.
+type articleRange struct {
+	since time.Time
+	order string
+	after time.Time
+	limit int
+}
+
+// ArticlesSince returns the feed's articles in the range, and whether more
+// articles follow them. If the range is limited, the articles at the time
+// of the last of them are all returned, even if that is more than the
+// limit, so that a page never splits articles that are equal in the order.
+func (f FeedInfo) articlesSince(c appengine.Context, r articleRange) (Articles, bool, error) {
 	key := datastore.NewKey(c, feedKind, f.Url, 0, nil)
-	if t.IsZero() {
-		_, err = datastore.NewQuery(articleKind).Ancestor(key).GetAll(c, &articles)
-	} else {
-		_, err = datastore.NewQuery(articleKind).Ancestor(key).Filter("When >=", t).GetAll(c, &articles)
+	when, after := "-When", "When <"
+	if r.order == oldestFirst {
+		when, after = "When", "When >"
+	}
+	q := datastore.NewQuery(articleKind).Ancestor(key)
+	if !r.since.IsZero() {
+		q = q.Filter("When >=", r.since)
+	}
+	if !r.after.IsZero() {
+		q = q.Filter(after, r.after)
 	}
-	return
+	if r.limit <= 0 {
+		articles, err := getArticles(c, q.Order(when))
+		return articles, false, err
+	}
+	articles, err := getArticles(c, q.Order(when).Limit(r.limit+1))
+	if err != nil || len(articles) <= r.limit {
+		return articles, false, err
+	}
+	last := articles[len(articles)-1].When
+	ties, err := getArticles(c, datastore.NewQuery(articleKind).Ancestor(key).Filter("When =", last))
+	if err != nil {
+		return nil, false, err
+	}
+	return append(withoutTime(articles, last), ties...), true, nil
+}
+
+// WithoutTime returns the articles, which are in order by time, without
+// the articles at the end that are at time t.
+func withoutTime(articles Articles, t time.Time) Articles {
+	for len(articles) > 0 && articles[len(articles)-1].When.Equal(t) {
+		articles = articles[:len(articles)-1]
+	}
+	return articles
+}
+
+// ArticlesInsertedSince returns all articles for a feed that were first
+// stored after the given time.
+func (f FeedInfo) articlesInsertedSince(c appengine.Context, t time.Time) (Articles, error) {
+	key := datastore.NewKey(c, feedKind, f.Url, 0, nil)
+	return getArticles(c, datastore.NewQuery(articleKind).Ancestor(key).Filter("InsertedAt >", t))
+}
+
+// ArticlesPage returns a page of at most n of the feed's articles, oldest
+// first if order is oldestFirst and newest first otherwise, starting at
+// the given cursor, along with the encoded cursor of
+// the next page. The next cursor is empty if there are no more articles.
+func (f FeedInfo) articlesPage(c appengine.Context, cursor datastore.Cursor, n int, order string) (Articles, string, error) {
+	key := datastore.NewKey(c, feedKind, f.Url, 0, nil)
+	when := "-When"
+	if order == oldestFirst {
+		when = "When"
+	}
+	q := datastore.NewQuery(articleKind).Ancestor(key).Order(when).Start(cursor).Limit(n)
+	var articles Articles
+	it := q.Run(c)
+	for {
+		var a Article
+		k, err := it.Next(&a)
+		if err == datastore.Done {
+			break
+		} else if err != nil {
+			return nil, "", err
+		}
+		a.Key = k
+		articles = append(articles, a)
+	}
+	if len(articles) < n {
+		return articles, "", nil
+	}
+	next, err := it.Cursor()
+	if err != nil {
+		return nil, "", err
+	}
+	return articles, next.String(), nil
+}
+
+// GetArticles returns the articles found by a query, with their keys.
+func getArticles(c appengine.Context, q *datastore.Query) (Articles, error) {
+	var articles Articles
+	keys, err := q.GetAll(c, &articles)
+	if err != nil {
+		return nil, err
+	}
+	for i := range articles {
+		articles[i].Key = keys[i]
+	}
+	return articles, nil
 }
 
 // EnsureFresh refreshes the feed only if it is stale.
+// Feeds that have been complete for longer than completeGracePeriod are
+// never refreshed, failing feeds are not refreshed before NextRetry, and
+// disabled feeds are not refreshed at all. If another feed on the same
+// host is being fetched, the feed is not refreshed; it is still stale,
+// so the next cron refresh adds a task for it again.
 func (f *FeedInfo) ensureFresh(c appengine.Context) error {
-	if time.Since(f.LastFetch) > maxCacheDuration {
+	if f.Disabled {
+		c.Debugf("%s: disabled, not refreshing\n", f.Url)
+		return nil
+	}
+	if f.Complete && time.Since(f.CompletedAt) > completeGracePeriod {
+		c.Debugf("%s: complete since %s, not refreshing\n", f.Url, f.CompletedAt)
+		return nil
+	}
+	if time.Now().Before(f.NextRetry) {
+		c.Debugf("%s: failed %d times, not refreshing until %s\n", f.Url, f.ConsecutiveFailures, f.NextRetry)
+		return nil
+	}
+	if time.Now().Before(f.RetryAfter) {
+		c.Debugf("%s: asked to retry after %s, not refreshing\n", f.Url, f.RetryAfter)
+		return nil
+	}
+	if !f.stale(time.Now()) {
+		return nil
+	}
+	ok, err := withHostLease(memcacheLeases{c}, f.fetchURL(), func() error {
 		return f.refresh(c)
+	})
+	if !ok {
+		c.Debugf("%s: another feed on its host is being fetched, leaving it to the next cron refresh\n", f.Url)
 	}
-	return nil
+	return err
 }
 
 // RefreshFeed fetches and updates a feed from the remote source,
@@ -107,15 +465,38 @@ func (f *FeedInfo) refresh(c appengine.Context) error {
 			return err
 		}
 		if fetchErr != nil {
+			// If the feed is not modified, only the fetch time
+			// is updated.
 			*f = stored
 			f.LastFetch = time.Now()
+			if ra, ok := fetchErr.(retryAfterError); ok {
+				f.LastError = ra.Error()
+				f.LastErrorTime = f.LastFetch
+				f.RetryAfter = ra.until
+			} else if fetchErr == errNotModified {
+				f.succeeded()
+			} else {
+				f.failed(fetchErr, f.LastFetch)
+			}
 		} else {
-			*f = fnew
+			if stored.Title != "" && stored.Title != fnew.Title {
+				c.Infof("%s: title changed from [%s] to [%s]", f.Url, stored.Title, fnew.Title)
+			}
+			*f = refreshed(stored, fnew, time.Now())
 		}
 		f.Refs = stored.Refs
+		f.Subscribers = stored.Subscribers
 		_, err = datastore.Put(c, key, f)
 		return err
 	}, nil)
+	if fetchErr == errNotModified {
+		return err
+	}
+	if ra, ok := fetchErr.(retryAfterError); ok && err == nil {
+		d := ra.until.Sub(time.Now())
+		c.Infof("%s: %s, refreshing in %s", f.Url, ra.status, d)
+		return addRefreshTask(c, datastore.NewKey(c, feedKind, f.Url, 0, nil), d)
+	}
 	if fetchErr != nil {
 		return fetchErr
 	}
@@ -123,15 +504,136 @@ func (f *FeedInfo) refresh(c appengine.Context) error {
 		return err
 	}
 
+	if f.LastError != "" && len(articles) == 0 {
+		// The body was an error page rather than the feed, so the
+		// stored articles are kept.
+		return nil
+	}
+	if f.needsWebSub(time.Now()) {
+		if err := f.subscribeWebSub(c); err != nil {
+			c.Errorf("%s: failed to subscribe to %s: %s", f.Url, f.Hub, err)
+		}
+	}
 	return f.updateArticles(c, articles)
 }
 
+// Refreshed returns the FeedInfo of a feed, stored before a fetch at time
+// now that returned fetched. The publisher's current title and link are
+// used, but a title that is missing from the fetched feed is kept from
+// stored, as are the article count, whether to fetch full content, the
+// refresh interval and, if the feed's hub has not changed, the WebSub
+// subscription.
+func refreshed(stored, fetched FeedInfo, now time.Time) FeedInfo {
+	f := fetched
+	if f.Title == f.Url && stored.Title != "" {
+		// ParseFeed uses the URL if the feed has no title.
+		f.Title = stored.Title
+	}
+	if f.Complete && stored.Complete && !stored.CompletedAt.IsZero() {
+		f.CompletedAt = stored.CompletedAt
+	} else if f.Complete {
+		f.CompletedAt = now
+	}
+	f.ArticleCount = stored.ArticleCount
+	f.FetchFull = stored.FetchFull
+	f.RefreshInterval = stored.RefreshInterval
+	if f.Hub == stored.Hub && f.HubTopic == stored.HubTopic {
+		f.WebSubSecret = stored.WebSubSecret
+		f.WebSubExpires = stored.WebSubExpires
+	}
+	return f
+}
+
+// Failed records that fetching the feed failed with err at time t,
+// putting off its next refresh, or disabling the feed if it has failed
+// too many times.
+func (f *FeedInfo) failed(err error, t time.Time) {
+	f.LastError = err.Error()
+	f.LastErrorTime = t
+	f.ConsecutiveFailures++
+	f.NextRetry = t.Add(retryDelay(f.ConsecutiveFailures))
+	if f.ConsecutiveFailures >= maxConsecutiveFailures {
+		f.Disabled = true
+	}
+}
+
+// Succeeded records that fetching the feed succeeded, clearing the
+// error of the last failure.
+func (f *FeedInfo) succeeded() {
+	f.LastError = ""
+	f.LastErrorTime = time.Time{}
+	f.ConsecutiveFailures = 0
+	f.NextRetry = time.Time{}
+}
+
+// Enable re-enables a disabled feed, forgetting its failures so that it
+// is refreshed again right away. The time of the last failed fetch is
+// forgotten too, or the feed would seem fresh until its refresh interval
+// had passed.
+func (f *FeedInfo) enable() {
+	f.succeeded()
+	f.Disabled = false
+	f.LastFetch = time.Time{}
+}
+
+// RefreshKeys returns the keys of the feeds that are not disabled and
+// are stale at now.
+func refreshKeys(keys []*datastore.Key, infos []FeedInfo, now time.Time) []*datastore.Key {
+	var stale []*datastore.Key
+	for i, k := range keys {
+		if !infos[i].Disabled && infos[i].stale(now) {
+			stale = append(stale, k)
+		}
+	}
+	return stale
+}
+
+// RetryDelay returns how long to wait before refreshing a feed that has
+// failed n consecutive times. The delay doubles with each failure, up to
+// maxRetryDelay.
+func retryDelay(n int) time.Duration {
+	d := maxCacheDuration
+	for i := 1; i < n && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d
+}
+
 // ReadSource returns the feed title and articles read from the source.
+// The raw body of the feed is stored so that it can be re-parsed later.
+//
+// If the feed has not changed since it was last fetched, errNotModified
+// is returned.
 func (f FeedInfo) readSource(c appengine.Context) (FeedInfo, Articles, error) {
-	feed, articles, err := fetchUrl(c, f.Url)
+	client := urlfetch.Client(c)
+	res, err := fetchWith(client, f.fetchURL(), f.ETag, f.LastModified)
+	if err != nil && f.FetchUrl != "" {
+		c.Debugf("%s: failed to fetch from %s, trying the original URL: %s", f.Url, f.FetchUrl, err.Error())
+		res, err = fetchWith(client, f.Url, f.ETag, f.LastModified)
+	}
+	if err != nil {
+		return FeedInfo{}, nil, err
+	}
+	if res.NotModified {
+		c.Debugf("%s: not modified", f.Url)
+		return FeedInfo{}, nil, errNotModified
+	}
+	body, final := res.Body, res.Final
+	if err := storeRawBody(c, f.Url, body); err != nil {
+		c.Errorf("%s: failed to store the raw body: %s", f.Url, err.Error())
+	}
+	feed, articles, err := parseFeed(c, f.Url, body)
 	if err != nil {
 		return FeedInfo{}, nil, err
 	}
+	if final != f.Url {
+		feed.FetchUrl = final
+	}
+	feed.ETag = res.ETag
+	feed.LastModified = res.LastModified
 	sort.Sort(articles)
 	if len(articles) > maxNewArticles {
 		articles = articles[:maxNewArticles]
@@ -139,7 +641,15 @@ func (f FeedInfo) readSource(c appengine.Context) (FeedInfo, Articles, error) {
 	return feed, articles, nil
 }
 
+// UpdateArticles stores the feed's new articles and deletes its stored
+// articles that are no longer in the feed.
 func (f FeedInfo) updateArticles(c appengine.Context, articles Articles) error {
+	return f.storeArticles(c, articles, true)
+}
+
+// StoreArticles stores the articles that are new to the feed. If replace
+// is true, the stored articles that are not among them are deleted.
+func (f FeedInfo) storeArticles(c appengine.Context, articles Articles, replace bool) error {
 	key := datastore.NewKey(c, feedKind, f.Url, 0, nil)
 	q := datastore.NewQuery(articleKind).Ancestor(key).KeysOnly()
 	stored := make(map[string]*datastore.Key)
@@ -152,7 +662,11 @@ func (f FeedInfo) updateArticles(c appengine.Context, articles Articles) error {
 		}
 		stored[k.StringID()] = k
 	}
+	total := len(stored)
 
+	now := time.Now()
+	var newKeys []*datastore.Key
+	var newArticles Articles
 	for _, a := range articles {
 		k := datastore.NewKey(c, articleKind, a.StringID(), 0, key)
 		id := k.StringID()
@@ -160,52 +674,239 @@ func (f FeedInfo) updateArticles(c appengine.Context, articles Articles) error {
 			delete(stored, id)
 			continue
 		}
-		if _, err := datastore.Put(c, k, &a); err != nil {
-			return err
+		a.InsertedAt = now
+		newKeys = append(newKeys, k)
+		newArticles = append(newArticles, a)
+	}
+	if err := putArticles(c, newKeys, newArticles); err != nil {
+		return err
+	}
+	if f.FetchFull {
+		if err := addFullContentTasks(c, newKeys, newArticles); err != nil {
+			c.Errorf("%s: failed to add full content tasks: %s", f.Url, err)
 		}
 	}
 
-	for _, k := range stored {
-		if err := datastore.Delete(c, k); err != nil {
-			return err
+	var oldKeys []*datastore.Key
+	if replace {
+		for _, k := range stored {
+			oldKeys = append(oldKeys, k)
 		}
 	}
-	return nil
+	if err := deleteArticles(c, oldKeys); err != nil {
+		return err
+	}
+	return setArticleCount(c, key, articleCount(total, len(newKeys), len(oldKeys)))
 }
 
-// RmArticles removes the articles associated with a feed.
-func (f FeedInfo) rmArticles(c appengine.Context) error {
-	key := datastore.NewKey(c, feedKind, f.Url, 0, nil)
-	q := datastore.NewQuery(articleKind).Ancestor(key).KeysOnly()
-	for it := q.Run(c); ; {
-		k, err := it.Next(nil)
-		switch {
-		case err == datastore.Done:
+// PutArticles stores articles with the given keys in batches. If some of
+// the articles fail to be stored, the remaining batches are still stored
+// and an errorList of the failures is returned.
+func putArticles(c appengine.Context, keys []*datastore.Key, articles Articles) error {
+	return putBatches(keys, articles, func(keys []*datastore.Key, articles Articles) error {
+		_, err := datastore.PutMulti(c, keys, articles)
+		return err
+	})
+}
+
+// PutBatches calls put with successive batches of at most maxBatch of the
+// keys and their articles. If put returns an appengine.MultiError, the
+// failures are collected and the remaining batches are still put;
+// otherwise it stops at the first error.
+func putBatches(keys []*datastore.Key, articles Articles, put func([]*datastore.Key, Articles) error) error {
+	var errs errorList
+	err := batches(len(keys), func(i, j int) error {
+		err := put(keys[i:j], articles[i:j])
+		if me, ok := err.(appengine.MultiError); ok {
+			for k, err := range me {
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %s", keys[i+k].StringID(), err))
+				}
+			}
 			return nil
-		case err != nil:
-			return err
 		}
-		if err := datastore.Delete(c, k); err != nil {
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Batches calls f with the bounds [i, j) of successive batches of at most
+// maxBatch of n items, stopping at the first error.
+func batches(n int, f func(i, j int) error) error {
+	for i := 0; i < n; i += maxBatch {
+		j := i + maxBatch
+		if j > n {
+			j = n
+		}
+		if err := f(i, j); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// RmArticles removes the articles associated with a feed, along with the
+// users' ArticleStates and ReadCounts for them. It uses queries and
+// transactions of its own, so it must not be called in a transaction.
+func (f FeedInfo) rmArticles(c appengine.Context) error {
+	key := datastore.NewKey(c, feedKind, f.Url, 0, nil)
+	articles, err := datastore.NewQuery(articleKind).Ancestor(key).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		return err
+	}
+	if err := deleteArticles(c, articles); err != nil {
+		return err
+	}
+	counts, err := datastore.NewQuery(readCountKind).Filter("Feed =", key).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		return err
+	}
+	return batches(len(counts), func(i, j int) error {
+		return datastore.DeleteMulti(c, counts[i:j])
+	})
+}
+
 // FetchUrl reads a feed from the given URL.
 func fetchUrl(c appengine.Context, url string) (FeedInfo, Articles, error) {
-	var finfo FeedInfo
-	resp, err := urlfetch.Client(c).Get(url)
+	body, _, err := fetchBody(c, url)
+	if err != nil {
+		return FeedInfo{}, nil, err
+	}
+	return parseFeed(c, url, body)
+}
+
+// FetchBody returns the raw body of the given URL, and the URL reached
+// by following only the permanent redirects from it.
+func fetchBody(c appengine.Context, url string) ([]byte, string, error) {
+	return fetchBodyWith(urlfetch.Client(c), url)
+}
+
+// MaxRedirects is the maximum number of redirects followed when fetching.
+const maxRedirects = 10
+
+// FetchBodyWith is fetchBody using the given http.Client.
+func fetchBodyWith(client *http.Client, url string) ([]byte, string, error) {
+	res, err := fetchWith(client, url, "", "")
+	return res.Body, res.Final, err
+}
+
+// A fetchResult is the result of fetching a URL.
+type fetchResult struct {
+	Body []byte
+	// Final is the URL reached by following only the permanent
+	// redirects from the fetched URL.
+	Final string
+	// ETag and LastModified are the validators of the body, if the
+	// server gave them.
+	ETag         string
+	LastModified string
+	// NotModified is true if the server reported that the body has not
+	// changed since the validators given to fetchWith. Body is then
+	// empty.
+	NotModified bool
+}
+
+// FetchWith fetches a URL using the given http.Client. If an ETag or
+// Last-Modified time from a previous fetch is given, the request is
+// conditional on the body having changed since. If the server responds
+// 429 or 503 with a Retry-After header, a retryAfterError is returned.
+func fetchWith(client *http.Client, url, etag, lastModified string) (fetchResult, error) {
+	final := url
+	permanent := true
+	cl := *client
+	cl.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		switch req.Response.StatusCode {
+		case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+			if permanent {
+				final = req.URL.String()
+			}
+		default:
+			permanent = false
+		}
+		return nil
+	}
+
+	req, err := newFetchRequest(url)
+	if err != nil {
+		return fetchResult{Final: url}, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	// Setting Accept-Encoding explicitly keeps the transport from
+	// decompressing the body, so it is decompressed below.
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := cl.Do(req)
 	if err != nil {
-		return finfo, nil, err
+		return fetchResult{Final: url}, err
 	}
 	defer resp.Body.Close()
+	res := fetchResult{
+		Final:        final,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		res.NotModified = true
+		res.ETag, res.LastModified = etag, lastModified
+		return res, nil
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if until, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return res, retryAfterError{status: resp.Status, until: until}
+		}
+	}
+	body := io.Reader(resp.Body)
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return res, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+	res.Body, err = ioutil.ReadAll(body)
+	return res, err
+}
 
-	feed, err := webfeed.Read(resp.Body)
+// NewFetchRequest returns a GET request for the URL with feedme's
+// User-Agent.
+func newFetchRequest(url string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		if _, ok := err.(webfeed.ErrBadTime); ok {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	return req, nil
+}
+
+// ParseFeed returns the feed information and articles from the raw body
+// of the feed fetched from the given URL.
+func parseFeed(c appengine.Context, url string, body []byte) (FeedInfo, Articles, error) {
+	var finfo FeedInfo
+	feed, err := webfeed.ReadWithOptions(bytes.NewReader(body), webfeed.ReadOptions{Sanitize: true})
+	notAFeed := err == webfeed.ErrNotAFeed
+	if err != nil {
+		if _, ok := err.(webfeed.ErrBadTimes); ok {
 			c.Debugf("%s: %s", url, err.Error())
 			err = nil
+		} else if notAFeed {
+			// An error page served with a successful status, such
+			// as a login page or a captive portal, is recorded as
+			// the feed's LastError below rather than failing.
+			err = nil
 		} else {
 			err = errors.New("failed to fetch " + url + ": " + err.Error())
 			return finfo, nil, err
@@ -219,6 +920,21 @@ func fetchUrl(c appengine.Context, url string) (FeedInfo, Articles, error) {
 	}
 	finfo.Link = feed.Link
 	finfo.LastFetch = time.Now()
+	finfo.Blocked = feed.Blocked
+	finfo.Complete = feed.Complete
+	if len(feed.Hubs) > 0 {
+		finfo.Hub = feed.Hubs[0]
+		finfo.HubTopic = feed.Self
+		if finfo.HubTopic == "" {
+			finfo.HubTopic = url
+		}
+	}
+	if notAFeed {
+		w := notAFeedError(body, feed)
+		c.Warningf("%s: %s", url, w)
+		finfo.LastError = w
+		finfo.LastErrorTime = finfo.LastFetch
+	}
 
 	as := make(Articles, len(feed.Entries))
 	for i, ent := range feed.Entries {
@@ -241,6 +957,7 @@ func fetchUrl(c appengine.Context, url string) (FeedInfo, Articles, error) {
 			Title:           title,
 			Link:            ent.Link,
 			OriginTitle:     feed.Title,
+			EntryID:         ent.ID,
 			DescriptionData: content,
 			When:            ent.When,
 		}
@@ -249,17 +966,70 @@ func fetchUrl(c appengine.Context, url string) (FeedInfo, Articles, error) {
 	return finfo, as, nil
 }
 
+// ValidateFeedURL returns an error if s is not an absolute http or https
+// URL with a host.
+func validateFeedURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid URL", s)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+	case "":
+		return fmt.Errorf("%s is not a valid feed URL: it must begin with http:// or https://", s)
+	default:
+		return fmt.Errorf("%s is not a valid feed URL: the scheme must be http or https, not %s", s, u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%s is not a valid feed URL: it has no host", s)
+	}
+	return nil
+}
+
 // CheckUrl returns information about a feed and nil if the URL is a
 // valid feed, otherwise it returns an error.
+//
+// If the URL is that of a web page rather than a feed, the first feed
+// that the page links to is checked instead, and its URL is returned in
+// the FeedInfo. If the URL permanently redirects, the URL to which it
+// redirects is returned, so that the feed is not subscribed twice under
+// different URLs.
 func checkUrl(c appengine.Context, url string) (FeedInfo, error) {
-	resp, err := urlfetch.Client(c).Get(url)
+	return checkUrlWith(c, urlfetch.Client(c), url)
+}
+
+// CheckUrlWith is checkUrl using the given http.Client.
+func checkUrlWith(c appengine.Context, client *http.Client, url string) (FeedInfo, error) {
+	body, final, err := fetchBodyWith(client, url)
 	if err != nil {
 		return FeedInfo{}, err
 	}
-	defer resp.Body.Close()
-	f, err := webfeed.Read(resp.Body)
+	finfo, err := readFeedInfo(c, final, body)
+	if err != webfeed.ErrNotAFeed {
+		return finfo, err
+	}
+	urls, derr := webfeed.Discover(bytes.NewReader(body), final)
+	if derr != nil || len(urls) == 0 {
+		return FeedInfo{}, errors.New(finfo.LastError)
+	}
+	c.Debugf("%s is not a feed, trying %s", url, urls[0])
+	body, final, err = fetchBodyWith(client, urls[0])
 	if err != nil {
-		if _, ok := err.(webfeed.ErrBadTime); ok {
+		return FeedInfo{}, err
+	}
+	return readFeedInfo(c, final, body)
+}
+
+// ReadFeedInfo returns the FeedInfo for the body of the feed fetched
+// from the given URL. If the body is not a feed, webfeed.ErrNotAFeed is
+// returned along with a FeedInfo whose LastError says why.
+func readFeedInfo(c appengine.Context, url string, body []byte) (FeedInfo, error) {
+	f, err := webfeed.Read(bytes.NewReader(body))
+	if err == webfeed.ErrNotAFeed {
+		return FeedInfo{Url: url, LastError: notAFeedError(body, f)}, err
+	}
+	if err != nil {
+		if _, ok := err.(webfeed.ErrBadTimes); ok {
 			c.Debugf("%s: %s", url, err.Error())
 			err = nil
 		} else {
@@ -268,3 +1038,68 @@ func checkUrl(c appengine.Context, url string) (FeedInfo, error) {
 	}
 	return FeedInfo{Url: url, Title: f.Title, Link: f.Link}, err
 }
+
+// NotAFeedError returns the error to record for a body that is not a
+// feed: the softError warning if it looks like an error page, and
+// webfeed.ErrNotAFeed's otherwise.
+func notAFeedError(body []byte, feed webfeed.Feed) string {
+	if w := softError(body, feed); w != "" {
+		return w
+	}
+	return webfeed.ErrNotAFeed.Error()
+}
+
+// SoftErrorSize is the body size, in bytes, below which a feed with no
+// entries is considered to be an error page.
+const softErrorSize = 512
+
+// SoftErrorPatterns are lower-case strings commonly found in the bodies
+// of error pages.
+var softErrorPatterns = []string{
+	"not found",
+	"404",
+	"error",
+	"does not exist",
+	"<html",
+}
+
+// SoftError returns a warning if a feed appears to be an error page that
+// was served with a successful status, and the empty string otherwise.
+// A feed is considered to be an error page if it has no entries, its root
+// element is not that of a feed, and its body is either very small or
+// contains one of the softErrorPatterns.
+func softError(body []byte, feed webfeed.Feed) string {
+	if len(feed.Entries) > 0 || isFeedRoot(body) {
+		return ""
+	}
+	if len(body) < softErrorSize {
+		return "the feed has no entries and looks like an error page"
+	}
+	lower := bytes.ToLower(body)
+	for _, p := range softErrorPatterns {
+		if bytes.Contains(lower, []byte(p)) {
+			return "the feed has no entries and looks like an error page"
+		}
+	}
+	return ""
+}
+
+// IsFeedRoot returns true if the root element of body is an RSS, RDF, or
+// Atom feed element.
+func isFeedRoot(body []byte) bool {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.Strict = false
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return false
+		}
+		if el, ok := tok.(xml.StartElement); ok {
+			switch el.Name.Local {
+			case "rss", "feed", "RDF":
+				return true
+			}
+			return false
+		}
+	}
+}
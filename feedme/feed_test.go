@@ -0,0 +1,640 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/velour/feedme/webfeed"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSoftError(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		error bool
+	}{
+		{
+			name:  "soft 404",
+			body:  `<html><head><title>404 Not Found</title></head><body>Not Found</body></html>`,
+			error: true,
+		},
+		{
+			name: "large error page",
+			body: `<html><head><title>Oops</title></head><body>` +
+				strings.Repeat("<p>Sorry, something went wrong.</p>", 50) +
+				`</body></html>`,
+			error: true,
+		},
+		{
+			name:  "empty RSS",
+			body:  `<rss version="2.0"><channel><title>Empty</title></channel></rss>`,
+			error: false,
+		},
+		{
+			name:  "empty Atom",
+			body:  `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><title>Empty</title></feed>`,
+			error: false,
+		},
+		{
+			name:  "RSS with entries",
+			body:  `<rss version="2.0"><channel><title>Error</title><item><title>404</title></item></channel></rss>`,
+			error: false,
+		},
+	}
+
+	for _, test := range tests {
+		f, _, err := parseFeed(logContext{}, "http://example.com/feed", []byte(test.body))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if w := f.LastError; (w != "") != test.error {
+			t.Errorf("%s: expected an error %t, got [%s]", test.name, test.error, w)
+		}
+	}
+}
+
+func TestParseFeedNotAFeed(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{
+			name: "login page",
+			body: `<!DOCTYPE html><html><head><title>Sign in</title></head><body>` +
+				strings.Repeat("<p>Please sign in to continue.</p>", 20) +
+				`<form><input name="user"><input name="password" type="password"></form></body></html>`,
+			err: "the feed has no entries and looks like an error page",
+		},
+		{
+			name: "unknown document",
+			body: `<?xml version="1.0"?><catalog>` + strings.Repeat("<book><title>Go</title></book>", 20) + `</catalog>`,
+			err:  webfeed.ErrNotAFeed.Error(),
+		},
+	}
+	for _, test := range tests {
+		f, articles, err := parseFeed(logContext{}, "http://example.com/feed", []byte(test.body))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if f.LastError != test.err || f.LastErrorTime.IsZero() {
+			t.Errorf("%s: expected the error [%s], got [%s] at %s", test.name, test.err, f.LastError, f.LastErrorTime)
+		}
+		if len(articles) != 0 {
+			t.Errorf("%s: expected no articles, got %d", test.name, len(articles))
+		}
+	}
+}
+
+func TestBatches(t *testing.T) {
+	tests := []struct {
+		n      int
+		bounds [][2]int
+	}{
+		{0, nil},
+		{1, [][2]int{{0, 1}}},
+		{maxBatch, [][2]int{{0, maxBatch}}},
+		{maxBatch + 1, [][2]int{{0, maxBatch}, {maxBatch, maxBatch + 1}}},
+		{2*maxBatch + 7, [][2]int{{0, maxBatch}, {maxBatch, 2 * maxBatch}, {2 * maxBatch, 2*maxBatch + 7}}},
+	}
+	for _, test := range tests {
+		var bounds [][2]int
+		batches(test.n, func(i, j int) error {
+			bounds = append(bounds, [2]int{i, j})
+			return nil
+		})
+		if !reflect.DeepEqual(bounds, test.bounds) {
+			t.Errorf("Expected batches(%d) to be %v, got %v", test.n, test.bounds, bounds)
+		}
+	}
+}
+
+func TestBatchesError(t *testing.T) {
+	n := 0
+	err := batches(3*maxBatch, func(i, j int) error {
+		n++
+		return errorList{}
+	})
+	if err == nil || n != 1 {
+		t.Errorf("Expected batches to stop at the first error, got %d calls and error %v", n, err)
+	}
+}
+
+func TestPutBatches(t *testing.T) {
+	n := 2*maxBatch + 1
+	keys := make([]*datastore.Key, n)
+	for i := range keys {
+		keys[i] = datastore.NewKey(nil, articleKind, strconv.Itoa(i), 0, nil)
+	}
+	articles := make(Articles, n)
+
+	var sizes []int
+	err := putBatches(keys, articles, func(keys []*datastore.Key, articles Articles) error {
+		sizes = append(sizes, len(keys))
+		if len(sizes) == 2 {
+			return nil
+		}
+		me := make(appengine.MultiError, len(keys))
+		me[0] = errors.New("failed")
+		return me
+	})
+	if exp := []int{maxBatch, maxBatch, 1}; !reflect.DeepEqual(sizes, exp) {
+		t.Errorf("Expected batches of %v, got %v", exp, sizes)
+	}
+	errs, ok := err.(errorList)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Expected an errorList of 2 failures, got %v", err)
+	}
+	for i, id := range []string{"0", strconv.Itoa(2 * maxBatch)} {
+		if exp := id + ": failed"; errs[i].Error() != exp {
+			t.Errorf("Expected failure %q, got %q", exp, errs[i])
+		}
+	}
+
+	calls := 0
+	err = putBatches(keys, articles, func([]*datastore.Key, Articles) error {
+		calls++
+		return errors.New("unavailable")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Expected putBatches to stop at the first error, got %d calls and error %v", calls, err)
+	}
+}
+
+func TestFetchBodyRedirectMemory(t *testing.T) {
+	hits := make(map[string]int)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		hits[r.URL.Path]++
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		hits[r.URL.Path]++
+		w.Write([]byte(`<rss version="2.0"><channel><title>New</title></channel></rss>`))
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	f := FeedInfo{Url: s.URL + "/old"}
+	_, final, err := fetchBodyWith(s.Client(), f.fetchURL())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if final != s.URL+"/new" {
+		t.Fatalf("Expected the final URL to be %s/new, got %s", s.URL, final)
+	}
+	f.FetchUrl = final
+
+	body, _, err := fetchBodyWith(s.Client(), f.fetchURL())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.Contains(string(body), "New") {
+		t.Errorf("Expected the feed body, got [%s]", body)
+	}
+	if hits["/old"] != 1 || hits["/new"] != 2 {
+		t.Errorf("Expected the second fetch to skip the redirect, got hits %v", hits)
+	}
+}
+
+func TestFetchBodyTemporaryRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusFound)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<rss version="2.0"><channel><title>New</title></channel></rss>`))
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	_, final, err := fetchBodyWith(s.Client(), s.URL+"/old")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if final != s.URL+"/moved" {
+		t.Errorf("Expected the final URL to stop at the temporary redirect, %s/moved, got %s", s.URL, final)
+	}
+}
+
+// A notModifiedTransport is an http.RoundTripper that responds with
+// 304 Not Modified to requests with the validators it returned, and
+// with a feed otherwise.
+type notModifiedTransport struct {
+	reqs []*http.Request
+}
+
+func (tr *notModifiedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	tr.reqs = append(tr.reqs, r)
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader(`<rss version="2.0"><channel><title>Feed</title></channel></rss>`)),
+		Request:    r,
+	}
+	resp.Header.Set("ETag", `"v1"`)
+	resp.Header.Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	if r.Header.Get("If-None-Match") == `"v1"` {
+		resp.StatusCode = http.StatusNotModified
+		resp.Header = make(http.Header)
+		resp.Body = ioutil.NopCloser(strings.NewReader(""))
+	}
+	return resp, nil
+}
+
+func TestFetchConditional(t *testing.T) {
+	tr := &notModifiedTransport{}
+	client := &http.Client{Transport: tr}
+	const url = "http://example.com/feed"
+
+	res, err := fetchWith(client, url, "", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if res.NotModified || len(res.Body) == 0 {
+		t.Fatalf("Expected the feed body, got NotModified=%t body=[%s]", res.NotModified, res.Body)
+	}
+	if h := tr.reqs[0].Header; h.Get("If-None-Match") != "" || h.Get("If-Modified-Since") != "" {
+		t.Errorf("Expected an unconditional request, got %v", h)
+	}
+
+	res, err = fetchWith(client, url, res.ETag, res.LastModified)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	h := tr.reqs[1].Header
+	if etag := h.Get("If-None-Match"); etag != `"v1"` {
+		t.Errorf("Expected If-None-Match \"v1\", got %q", etag)
+	}
+	if lm := h.Get("If-Modified-Since"); lm != "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Errorf("Expected If-Modified-Since Mon, 02 Jan 2006 15:04:05 GMT, got %q", lm)
+	}
+	if !res.NotModified || len(res.Body) != 0 {
+		t.Errorf("Expected not modified with no body, got NotModified=%t body=[%s]", res.NotModified, res.Body)
+	}
+	if res.ETag != `"v1"` || res.LastModified != "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Errorf("Expected the validators to be kept, got %q and %q", res.ETag, res.LastModified)
+	}
+}
+
+func TestFetchGzip(t *testing.T) {
+	const feed = `<rss version="2.0"><channel><title>Compressed</title></channel></rss>`
+	tests := []struct {
+		name string
+		gzip bool
+	}{
+		{name: "gzip", gzip: true},
+		{name: "identity", gzip: false},
+	}
+	for _, test := range tests {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ae := r.Header.Get("Accept-Encoding"); ae != "gzip" {
+				t.Errorf("%s: expected Accept-Encoding gzip, got %q", test.name, ae)
+			}
+			if !test.gzip {
+				w.Write([]byte(feed))
+				return
+			}
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			gz.Write([]byte(feed))
+			gz.Close()
+		}))
+
+		body, _, err := fetchBodyWith(s.Client(), s.URL)
+		s.Close()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if string(body) != feed {
+			t.Errorf("%s: expected [%s], got [%s]", test.name, feed, body)
+			continue
+		}
+		f, err := webfeed.Read(bytes.NewReader(body))
+		if err != nil || f.Title != "Compressed" {
+			t.Errorf("%s: expected title Compressed, got %q, %v", test.name, f.Title, err)
+		}
+	}
+}
+
+func TestCheckUrlPermanentRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<rss version="2.0"><channel><title>New</title><item><title>Item</title></item></channel></rss>`))
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	// The feed is well formed, so nothing is logged to the nil context.
+	finfo, err := checkUrlWith(nil, s.Client(), s.URL+"/old")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if finfo.Url != s.URL+"/new" {
+		t.Errorf("Expected the URL to be %s/new, got %s", s.URL, finfo.Url)
+	}
+	if finfo.Title != "New" {
+		t.Errorf("Expected the title to be New, got %s", finfo.Title)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		n     int
+		delay time.Duration
+	}{
+		{n: 1, delay: maxCacheDuration},
+		{n: 2, delay: 2 * maxCacheDuration},
+		{n: 3, delay: 4 * maxCacheDuration},
+		{n: 6, delay: 32 * maxCacheDuration},
+		{n: 7, delay: maxRetryDelay},
+		{n: 100, delay: maxRetryDelay},
+	}
+	for _, test := range tests {
+		if d := retryDelay(test.n); d != test.delay {
+			t.Errorf("Expected the delay after %d failures to be %s, got %s", test.n, test.delay, d)
+		}
+	}
+}
+
+func TestFailedBackoff(t *testing.T) {
+	now := time.Now()
+	var f FeedInfo
+	var last time.Duration
+	for i := 1; i <= 10; i++ {
+		f.failed(errors.New("fetch failed"), now)
+		if f.ConsecutiveFailures != i {
+			t.Fatalf("Expected %d failures, got %d", i, f.ConsecutiveFailures)
+		}
+		d := f.NextRetry.Sub(now)
+		if d < last {
+			t.Errorf("Expected the backoff after %d failures to be at least %s, got %s", i, last, d)
+		}
+		if d > maxRetryDelay {
+			t.Errorf("Expected the backoff after %d failures to be at most %s, got %s", i, maxRetryDelay, d)
+		}
+		last = d
+	}
+
+	f.succeeded()
+	if f.ConsecutiveFailures != 0 || !f.NextRetry.IsZero() {
+		t.Errorf("Expected success to reset the backoff, got %d failures and next retry %s", f.ConsecutiveFailures, f.NextRetry)
+	}
+}
+
+func TestDisable(t *testing.T) {
+	now := time.Now()
+	// A failed refresh sets LastFetch, as refresh does.
+	f := FeedInfo{LastFetch: now}
+	for i := 1; i < maxConsecutiveFailures; i++ {
+		f.failed(errors.New("fetch failed"), now)
+		if f.Disabled {
+			t.Fatalf("Expected the feed to be enabled after %d failures", i)
+		}
+	}
+	f.failed(errors.New("fetch failed"), now)
+	if !f.Disabled {
+		t.Fatalf("Expected the feed to be disabled after %d failures", maxConsecutiveFailures)
+	}
+
+	f.enable()
+	if f.Disabled || f.ConsecutiveFailures != 0 || !f.NextRetry.IsZero() {
+		t.Errorf("Expected enabling to reset the feed, got disabled=%t, %d failures, next retry %s",
+			f.Disabled, f.ConsecutiveFailures, f.NextRetry)
+	}
+	if !f.stale(now) {
+		t.Errorf("Expected the re-enabled feed to be refreshed right away, last fetched %s", f.LastFetch)
+	}
+	f.failed(errors.New("fetch failed"), now)
+	if f.Disabled {
+		t.Errorf("Expected the re-enabled feed to be enabled after one failure")
+	}
+}
+
+func TestRefreshKeys(t *testing.T) {
+	now := time.Now()
+	infos := []FeedInfo{
+		{Url: "a"},
+		{Url: "b", Disabled: true},
+		{Url: "c"},
+		{Url: "d", LastFetch: now.Add(-time.Hour), RefreshInterval: 6 * time.Hour},
+	}
+	keys := []*datastore.Key{nil, nil, nil, nil}
+	if n := len(refreshKeys(keys, infos, now)); n != 2 {
+		t.Errorf("Expected 2 enabled, stale feeds, got %d", n)
+	}
+}
+
+func TestStale(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		interval time.Duration
+		fetched  time.Duration
+		stale    bool
+	}{
+		{name: "default fresh", fetched: maxCacheDuration - time.Minute, stale: false},
+		{name: "default stale", fetched: maxCacheDuration + time.Minute, stale: true},
+		{name: "short interval", interval: minRefreshInterval, fetched: 15 * time.Minute, stale: true},
+		{name: "long interval", interval: 24 * time.Hour, fetched: 2 * time.Hour, stale: false},
+		{name: "long interval passed", interval: 24 * time.Hour, fetched: 25 * time.Hour, stale: true},
+	}
+	for _, test := range tests {
+		f := FeedInfo{LastFetch: now.Add(-test.fetched), RefreshInterval: test.interval}
+		if stale := f.stale(now); stale != test.stale {
+			t.Errorf("%s: expected stale=%t, got %t", test.name, test.stale, stale)
+		}
+		e := newFeedListEntry(f, "", "", 0)
+		if fresh := e.Fresh(); fresh == test.stale {
+			t.Errorf("%s: expected the list entry to be fresh=%t, got %t", test.name, !test.stale, fresh)
+		}
+	}
+}
+
+func TestRefreshedRefreshInterval(t *testing.T) {
+	stored := FeedInfo{Url: "http://example.com/feed", RefreshInterval: time.Hour}
+	if f := refreshed(stored, FeedInfo{Url: stored.Url}, time.Now()); f.RefreshInterval != time.Hour {
+		t.Errorf("Expected the refresh interval to be kept, got %s", f.RefreshInterval)
+	}
+}
+
+func TestFailedRecordsError(t *testing.T) {
+	now := time.Now()
+	var f FeedInfo
+	f.failed(errors.New("connection refused"), now)
+	if f.LastError != "connection refused" {
+		t.Errorf("Expected the error connection refused, got %q", f.LastError)
+	}
+	if !f.LastErrorTime.Equal(now) {
+		t.Errorf("Expected the error time %s, got %s", now, f.LastErrorTime)
+	}
+
+	f.succeeded()
+	if f.LastError != "" || !f.LastErrorTime.IsZero() {
+		t.Errorf("Expected success to clear the error, got %q at %s", f.LastError, f.LastErrorTime)
+	}
+}
+
+func TestValidateFeedURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"http://example.com/feed", true},
+		{"https://example.com/feed.xml", true},
+		{"HTTPS://example.com", true},
+		{"htttp://example.com/feed", false},
+		{"example.com/feed", false},
+		{"feed", false},
+		{"ftp://example.com/feed", false},
+		{"http://", false},
+		{"http:///feed", false},
+		{"http://exa mple.com/feed", false},
+		{"javascript:alert(1)", false},
+	}
+	for _, test := range tests {
+		err := validateFeedURL(test.url)
+		if (err == nil) != test.ok {
+			t.Errorf("Expected %q to be valid=%t, got error %v", test.url, test.ok, err)
+		}
+	}
+}
+
+func TestRefreshed(t *testing.T) {
+	now := time.Now()
+	completed := now.Add(-time.Hour)
+	tests := []struct {
+		name            string
+		stored, fetched FeedInfo
+		title, link     string
+		completedAt     time.Time
+	}{
+		{
+			name:    "title changed",
+			stored:  FeedInfo{Url: "http://example.com/feed", Title: "Old", Link: "http://example.com/old"},
+			fetched: FeedInfo{Url: "http://example.com/feed", Title: "New", Link: "http://example.com/new"},
+			title:   "New",
+			link:    "http://example.com/new",
+		},
+		{
+			name:    "title removed",
+			stored:  FeedInfo{Url: "http://example.com/feed", Title: "Old"},
+			fetched: FeedInfo{Url: "http://example.com/feed", Title: "http://example.com/feed"},
+			title:   "Old",
+		},
+		{
+			name:    "never had a title",
+			stored:  FeedInfo{Url: "http://example.com/feed"},
+			fetched: FeedInfo{Url: "http://example.com/feed", Title: "http://example.com/feed"},
+			title:   "http://example.com/feed",
+		},
+		{
+			name:        "newly complete",
+			stored:      FeedInfo{Url: "http://example.com/feed", Title: "T"},
+			fetched:     FeedInfo{Url: "http://example.com/feed", Title: "T", Complete: true},
+			title:       "T",
+			completedAt: now,
+		},
+		{
+			name:        "still complete",
+			stored:      FeedInfo{Url: "http://example.com/feed", Title: "T", Complete: true, CompletedAt: completed},
+			fetched:     FeedInfo{Url: "http://example.com/feed", Title: "T", Complete: true},
+			title:       "T",
+			completedAt: completed,
+		},
+	}
+	for _, test := range tests {
+		f := refreshed(test.stored, test.fetched, now)
+		if f.Title != test.title || f.Link != test.link {
+			t.Errorf("%s: expected title [%s] and link [%s], got [%s] and [%s]", test.name, test.title, test.link, f.Title, f.Link)
+		}
+		if !f.CompletedAt.Equal(test.completedAt) {
+			t.Errorf("%s: expected completed at %s, got %s", test.name, test.completedAt, f.CompletedAt)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		until time.Time
+		ok    bool
+	}{
+		{value: "120", until: now.Add(2 * time.Minute), ok: true},
+		{value: " 0 ", until: now, ok: true},
+		{value: "Wed, 21 Oct 2015 08:00:00 GMT", until: now.Add(32 * time.Minute), ok: true},
+		{value: "Wednesday, 21-Oct-15 08:00:00 GMT", until: now.Add(32 * time.Minute), ok: true},
+		{value: "999999", until: now.Add(maxRetryDelay), ok: true},
+		{value: "Fri, 01 Jan 2100 00:00:00 GMT", until: now.Add(maxRetryDelay), ok: true},
+		{value: "-5"},
+		{value: "soon"},
+		{value: ""},
+	}
+	for _, test := range tests {
+		until, ok := parseRetryAfter(test.value, now)
+		if ok != test.ok || !until.Equal(test.until) {
+			t.Errorf("%q: expected %s, %t, got %s, %t", test.value, test.until, test.ok, until, ok)
+		}
+	}
+}
+
+func TestFetchRetryAfter(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/limited", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+	mux.HandleFunc("/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	start := time.Now()
+	_, err := fetchWith(s.Client(), s.URL+"/limited", "", "")
+	ra, ok := err.(retryAfterError)
+	if !ok {
+		t.Fatalf("Expected a retryAfterError, got %v", err)
+	}
+	if ra.until.Before(start.Add(59*time.Second)) || ra.until.After(time.Now().Add(61*time.Second)) {
+		t.Errorf("Expected to retry in a minute, got %s", ra.until)
+	}
+
+	if _, err := fetchWith(s.Client(), s.URL+"/unavailable", "", ""); err != nil {
+		t.Errorf("Expected no error without a Retry-After header, got %v", err)
+	}
+}
+
+func TestFetchUserAgent(t *testing.T) {
+	var agent string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.UserAgent()
+	}))
+	defer s.Close()
+	if _, _, err := fetchBodyWith(s.Client(), s.URL); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if agent != userAgent {
+		t.Errorf("Expected the User-Agent %s, got %s", userAgent, agent)
+	}
+}
@@ -5,55 +5,104 @@ import (
 	"appengine/datastore"
 	"appengine/taskqueue"
 	"appengine/user"
-	"encoding/xml"
+	"errors"
 	"fmt"
 	"html/template"
+	"net"
 	"net/http"
+	"net/url"
 	"path"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-var (
-	templateFiles = []string{
-		"tmplt/navbar.html",
-		"tmplt/feed.html",
-		"tmplt/manage.html",
-		"tmplt/article.html",
-		"tmplt/articles.html",
-	}
+const (
+	// LatestDuration is how far back the latest view goes for users
+	// that have not set their own duration, which must be between
+	// minLatestDuration and maxLatestDuration.
+	latestDuration    = 18 * time.Hour
+	minLatestDuration = time.Hour
+	maxLatestDuration = 30 * 24 * time.Hour
 
-	funcs = template.FuncMap{
-		"dateTime": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
-		"stringEq": func(a, b string) bool { return a == b },
-	}
+	// MaxHostRefreshes is the maximum number of feeds on the same host
+	// that are scheduled to be refreshed at the same time. It matches the
+	// host lease, which lets only one of them be fetched at a time.
+	maxHostRefreshes = 1
 
-	templates = template.Must(template.New("t").Funcs(funcs).ParseFiles(templateFiles...))
-)
+	// HostRefreshDelay is the delay between successive groups of
+	// refreshes of feeds on the same host.
+	hostRefreshDelay = 30 * time.Second
 
-const (
-	latestDuration = 18 * time.Hour
+	// MaxParallelFeeds is the maximum number of feeds whose articles
+	// are read from the datastore at the same time.
+	maxParallelFeeds = 10
+
+	// ArticlesPerPage is the number of articles shown on each page of
+	// an articles view.
+	articlesPerPage = 50
 )
 
 func init() {
 	http.HandleFunc("/list", handleList)
 	http.HandleFunc("/addopml", handleOpml)
+	http.HandleFunc("/exportopml", handleExportOpml)
 	http.HandleFunc("/update", handleUpdate)
 	http.HandleFunc("/refresh", handleRefresh)
 	http.HandleFunc("/refreshAll", handleRefreshAll)
+	http.HandleFunc("/cron/refresh", handleCronRefresh)
+	http.HandleFunc("/cron/reindex", handleCronReindex)
+	http.HandleFunc("/enable", handleEnableFeed)
+	http.HandleFunc("/rename", handleRenameFeed)
+	http.HandleFunc("/refreshInterval", handleRefreshInterval)
 	http.HandleFunc("/", handleRoot)
 }
 
+// MethodNotAllowed responds with 405 Method Not Allowed, listing the
+// allowed methods in the Allow header.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
+
 type feedListEntry struct {
+	// Title is the title shown to the user: their own title for the
+	// feed, if they have given it one, or else the publisher's title.
+	// UserTitle is the user's own title, if any.
 	Title      string
+	UserTitle  string
 	Url        string
+	Link       string
 	LastFetch  time.Time
 	EncodedKey string
+	Category   string
+	Blocked    bool
+	Complete   bool
+	Disabled   bool
+	// FetchFull is whether the full content of the feed's articles is
+	// fetched from their pages.
+	FetchFull bool
+	// RefreshInterval is how long after it is fetched the feed is
+	// refreshed again.
+	RefreshInterval time.Duration
+	LastError       string
+	// LastErrorTime is when LastError happened.
+	LastErrorTime time.Time
+	// Unread is the number of the feed's articles that the user has
+	// not read.
+	Unread int
 }
 
 func (f feedListEntry) Fresh() bool {
-	return time.Since(f.LastFetch) < maxCacheDuration
+	return time.Since(f.LastFetch) < f.RefreshInterval
+}
+
+// RefreshMinutes returns how long after it is fetched the feed is
+// refreshed again, in minutes.
+func (f feedListEntry) RefreshMinutes() int {
+	return int(f.RefreshInterval / time.Minute)
 }
 
 // feedListEntrys is a type for sorting the infos.
@@ -72,10 +121,14 @@ func (u feedList) Swap(i, j int) {
 }
 
 func handleList(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/list" || r.Method != "GET" {
+	if r.URL.Path != "/list" {
 		http.NotFound(w, r)
 		return
 	}
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
 
 	c := appengine.NewContext(r)
 
@@ -84,8 +137,17 @@ func handleList(w http.ResponseWriter, r *http.Request) {
 		User   UserInfo
 		Logout string
 		Feeds  feedList
+		// Subscribed is the URL of a feed that was just subscribed
+		// by the quick subscribe page.
+		Subscribed  string
+		Bookmarklet template.URL
+		// Removed are the feeds that were removed recently enough
+		// that their removal can be undone.
+		Removed []removedFeed
 	}
 	page.Title = "Feeds"
+	page.Subscribed = r.FormValue("subscribed")
+	page.Bookmarklet = bookmarklet(r.Host)
 
 	var err error
 	page.User, err = getUserInfo(c)
@@ -94,22 +156,12 @@ func handleList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	infos := make([]FeedInfo, len(page.User.Feeds))
-	if err = datastore.GetMulti(c, page.User.Feeds, infos); err != nil {
+	page.Feeds, err = userFeedList(c, userInfoKey(c), page.User)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	for i := range infos {
-		page.Feeds = append(page.Feeds, feedListEntry{
-			Title:      infos[i].Title,
-			Url:        infos[i].Url,
-			LastFetch:  infos[i].LastFetch,
-			EncodedKey: page.User.Feeds[i].Encode(),
-		})
-	}
-
-	sort.Sort(page.Feeds)
+	page.Removed = page.User.removedFeeds()
 
 	page.Logout, err = user.LogoutURL(c, "/")
 	if err != nil {
@@ -117,8 +169,52 @@ func handleList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := templates.ExecuteTemplate(w, "manage.html", page); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	executeTemplate(w, c, "manage.html", page)
+}
+
+// UserFeedList returns the sorted list of the feeds of the user with the
+// given key and UserInfo.
+func userFeedList(c appengine.Context, userKey *datastore.Key, uinfo UserInfo) (feedList, error) {
+	infos := make([]FeedInfo, len(uinfo.Feeds))
+	if err := datastore.GetMulti(c, uinfo.Feeds, infos); err != nil {
+		return nil, err
+	}
+
+	var feeds feedList
+	for i := range infos {
+		unread, err := cachedUnreadCount(c, userKey, infos[i])
+		if err != nil {
+			c.Errorf("%s: failed to count unread articles: %s", infos[i].Url, err)
+		}
+		entry := newFeedListEntry(infos[i], uinfo.Feeds[i].Encode(), uinfo.category(i), unread)
+		entry.Title = uinfo.title(i, entry.Title)
+		if i < len(uinfo.Titles) {
+			entry.UserTitle = uinfo.Titles[i]
+		}
+		feeds = append(feeds, entry)
+	}
+	sort.Sort(feeds)
+	return feeds, nil
+}
+
+// NewFeedListEntry returns the feedListEntry of a feed, given its encoded
+// key, its category, and the number of its articles that are unread.
+func newFeedListEntry(f FeedInfo, encodedKey, category string, unread int) feedListEntry {
+	return feedListEntry{
+		Title:           f.Title,
+		Url:             f.Url,
+		Link:            f.Link,
+		LastFetch:       f.LastFetch,
+		EncodedKey:      encodedKey,
+		Category:        category,
+		Blocked:         f.Blocked,
+		Complete:        f.Complete,
+		Disabled:        f.Disabled,
+		FetchFull:       f.FetchFull,
+		RefreshInterval: f.refreshEvery(),
+		LastError:       f.LastError,
+		LastErrorTime:   f.LastErrorTime,
+		Unread:          unread,
 	}
 }
 
@@ -142,6 +238,18 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 		Link     string
 		Errors   []error
 		Articles Articles
+		// View is the view given to /markAllRead, and FeedKey is the
+		// encoded key of the feed if the page shows a single feed.
+		View    string
+		FeedKey string
+		// Next is the URL of the next page of articles, if any.
+		Next string
+		// HideRead is true if read articles are hidden, and
+		// ReadToggle is the URL of the page with them shown if they
+		// are hidden, or hidden if they are shown. ReadToggle is
+		// empty for the all view, which always shows them.
+		HideRead   bool
+		ReadToggle string
 	}{}
 
 	feedPage.Logout, err = user.LogoutURL(c, "/")
@@ -150,12 +258,43 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	order, err := parseSortOrder(r.FormValue("sort"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The sort value overrides the user's preference, and it is kept by
+	// the links to other pages of the view.
+	sortValue := order
+	if order == "" {
+		order = uinfo.SortOrder
+	}
+
+	cursor := r.FormValue("cursor")
+	var next string
+	// Paged is true if the articles were read a page at a time.
+	paged := false
 	if r.URL.Path == "/" {
 		feedPage.Title = "Latest Articles"
-		feedPage.Articles, feedPage.Errors = articlesSince(c, uinfo, time.Now().Add(-latestDuration))
+		feedPage.View = "latest"
+		feedPage.Articles, feedPage.Errors = latestArticles(c, uinfo)
+	} else if r.URL.Path == "/new" {
+		feedPage.Title = "New Articles"
+		feedPage.Articles, feedPage.Errors = articlesInsertedSince(c, uinfo, uinfo.LastVisit)
 	} else if r.URL.Path == "/all" {
 		feedPage.Title = "All Articles"
-		feedPage.Articles, feedPage.Errors = articlesSince(c, uinfo, time.Time{})
+		feedPage.View = "all"
+		var last *Article
+		if cursor != "" {
+			a, err := parseArticleCursor(order, cursor)
+			if err != nil {
+				http.Error(w, "bad cursor", http.StatusBadRequest)
+				return
+			}
+			last = &a
+		}
+		feedPage.Articles, next, feedPage.Errors = articlesPage(c, uinfo, order, last, articlesPerPage)
+		paged = true
 	} else {
 		var key *datastore.Key
 		var err error
@@ -170,99 +309,355 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 			feedPage.Errors = []error{err}
 
 		} else {
-			feedPage.Title = f.Title
+			cur, err := datastore.DecodeCursor(cursor)
+			if err != nil {
+				http.Error(w, "bad cursor", http.StatusBadRequest)
+				return
+			}
+			feedPage.Title = uinfo.title(uinfo.feedIndex(key), f.Title)
 			feedPage.Link = f.Link
-			feedPage.Articles, err = f.articlesSince(c, time.Time{})
+			feedPage.FeedKey = key.Encode()
+			feedPage.Articles, next, err = f.articlesPage(c, cur, articlesPerPage, order)
+			paged = true
 			if err != nil {
 				feedPage.Errors = []error{err}
 			}
+			feedPage.Articles.setOrigin(feedPage.Title)
+			feedPage.Articles = uinfo.unmuted(feedPage.Articles)
 		}
 	}
 
+	if uinfo.CollapseDuplicates && feedPage.FeedKey == "" {
+		feedPage.Articles = collapseDuplicates(feedPage.Articles)
+	}
 	c.Debugf("%d articles\n", len(feedPage.Articles))
-	sort.Sort(feedPage.Articles)
+	sort.Sort(sortedArticles{feedPage.Articles, order})
+	unread := r.FormValue("unread")
+	feedPage.HideRead = hideRead(r.URL.Path, uinfo.HideRead, unread)
+	if r.URL.Path != "/all" {
+		toggle := "1"
+		if feedPage.HideRead {
+			toggle = "0"
+		}
+		q := url.Values{"unread": {toggle}}
+		if sortValue != "" {
+			q.Set("sort", sortValue)
+		}
+		feedPage.ReadToggle = r.URL.Path + "?" + q.Encode()
+	}
+	if feedPage.HideRead {
+		// The read articles are removed before paging, so that each
+		// page is full.
+		if err := setRead(c, userInfoKey(c), feedPage.Articles); err != nil {
+			feedPage.Errors = append(feedPage.Errors, err)
+		}
+		feedPage.Articles = unreadArticles(feedPage.Articles)
+	}
+	if !paged {
+		feedPage.Articles, next, err = pageArticles(feedPage.Articles, order, cursor, articlesPerPage)
+		if err != nil {
+			http.Error(w, "bad cursor", http.StatusBadRequest)
+			return
+		}
+	}
+	if next != "" {
+		q := url.Values{"cursor": {next}}
+		if unread != "" {
+			q.Set("unread", unread)
+		}
+		if sortValue != "" {
+			q.Set("sort", sortValue)
+		}
+		feedPage.Next = r.URL.Path + "?" + q.Encode()
+	}
+	if !feedPage.HideRead {
+		if err := setRead(c, userInfoKey(c), feedPage.Articles); err != nil {
+			feedPage.Errors = append(feedPage.Errors, err)
+		}
+	}
 
-	if err := templates.ExecuteTemplate(w, "articles.html", feedPage); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// The previous visit time was captured in uinfo above, so it is safe to
+	// overwrite it now that the page's articles have been computed.
+	if err := setLastVisit(c, time.Now()); err != nil {
+		feedPage.Errors = append(feedPage.Errors, err)
 	}
+
+	executeTemplate(w, c, "articles.html", feedPage)
 }
 
-func articlesSince(c appengine.Context, uinfo UserInfo, t time.Time) (articles Articles, errs []error) {
-	for _, key := range uinfo.Feeds {
-		var f FeedInfo
-		if err := datastore.Get(c, key, &f); err != nil {
-			err = fmt.Errorf("%s: failed to load from the datastore: %s", key.StringID(), err.Error())
-			errs = append(errs, err)
-			continue
+// HideRead returns whether read articles are hidden from the view at the
+// given path, for a user whose HideRead preference is pref, given the
+// unread query value. An unread value of "1" hides them and "0" shows
+// them, overriding the preference. The all view always shows them.
+func hideRead(path string, pref bool, unread string) bool {
+	switch {
+	case path == "/all":
+		return false
+	case unread == "1":
+		return true
+	case unread == "0":
+		return false
+	}
+	return pref
+}
+
+// UnreadArticles returns the articles that are not read. The articles'
+// Read fields must already be set.
+func unreadArticles(articles Articles) Articles {
+	var as Articles
+	for _, a := range articles {
+		if !a.Read {
+			as = append(as, a)
+		}
+	}
+	return as
+}
+
+// CollapseDuplicates returns the articles without duplicates. Articles
+// with the same entry ID or the same normalized link are duplicates, and
+// only the earliest published of them is kept. The AlsoIn field of a kept
+// article lists the titles of the other feeds that carried it.
+//
+// Only entry IDs that are URIs are compared, since other IDs, such as RSS
+// guids that are serial numbers, are only unique within their feed.
+func collapseDuplicates(articles Articles) Articles {
+	sorted := make(Articles, len(articles))
+	copy(sorted, articles)
+	sort.Stable(sort.Reverse(sorted))
+
+	var kept Articles
+	seen := make(map[string]int)
+	for _, a := range sorted {
+		var ids []string
+		if strings.Contains(a.EntryID, ":") {
+			ids = append(ids, "id "+a.EntryID)
+		}
+		if a.Link != "" {
+			ids = append(ids, "link "+normalizeURL(a.Link))
+		}
+		i, dup := -1, false
+		for _, id := range ids {
+			if i, dup = seen[id]; dup {
+				break
+			}
+		}
+		if !dup {
+			i = len(kept)
+			kept = append(kept, a)
+		} else if k := &kept[i]; a.OriginTitle != k.OriginTitle && !containsString(k.AlsoIn, a.OriginTitle) {
+			k.AlsoIn = append(k.AlsoIn, a.OriginTitle)
+		}
+		for _, id := range ids {
+			if _, ok := seen[id]; !ok {
+				seen[id] = i
+			}
+		}
+	}
+	return kept
+}
+
+// ContainsString returns whether s is in ss.
+func containsString(ss []string, s string) bool {
+	for _, t := range ss {
+		if t == s {
+			return true
 		}
-		as, err := f.articlesSince(c, t)
+	}
+	return false
+}
+
+// PageArticles returns the page of at most n of the articles, sorted in
+// the given order, that follows the given cursor, along with the cursor
+// of the next page, which is empty on the last page. A cursor is the
+// time of the last article of the previous page, followed by its feed's
+// title in the byFeed order. Articles that are equal in the order are
+// never split across pages, so a page can have more than n articles.
+func pageArticles(articles Articles, order, cursor string, n int) (Articles, string, error) {
+	if cursor != "" {
+		last, err := parseArticleCursor(order, cursor)
 		if err != nil {
-			err = fmt.Errorf("%s: failed to read articles: %s", f.Url, err.Error())
-			errs = append(errs, err)
-			continue
+			return nil, "", err
 		}
-		articles = append(articles, as...)
+		i := sort.Search(len(articles), func(i int) bool {
+			return articleLess(order, last, articles[i])
+		})
+		articles = articles[i:]
 	}
-	return
+	if len(articles) <= n {
+		return articles, "", nil
+	}
+	end := n
+	for end < len(articles) && !articleLess(order, articles[n-1], articles[end]) {
+		end++
+	}
+	if end == len(articles) {
+		return articles, "", nil
+	}
+	return articles[:end], articleCursor(order, articles[end-1]), nil
 }
 
-type Outline struct {
-	XmlURL   string     `xml:"xmlUrl,attr"`
-	Outlines []*Outline `xml:"outline"`
+// ArticleCursor returns the cursor of the page that follows a.
+func articleCursor(order string, a Article) string {
+	cursor := a.When.Format(time.RFC3339Nano)
+	if order == byFeed {
+		cursor += " " + strings.ToLower(a.OriginTitle)
+	}
+	return cursor
 }
 
-func handleOpml(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.NotFound(w, r)
-		return
+// ParseArticleCursor returns an article that sorts the same in the given
+// order as the one from which cursor was made.
+func parseArticleCursor(order, cursor string) (Article, error) {
+	var a Article
+	if order == byFeed {
+		i := strings.Index(cursor, " ")
+		if i < 0 {
+			return Article{}, errors.New("bad cursor")
+		}
+		cursor, a.OriginTitle = cursor[:i], cursor[i+1:]
 	}
+	var err error
+	a.When, err = time.Parse(time.RFC3339Nano, cursor)
+	return a, err
+}
 
-	c := appengine.NewContext(r)
+func articlesSince(c appengine.Context, uinfo UserInfo, t time.Time) (Articles, []error) {
+	return userArticles(c, uinfo, func(f FeedInfo, title string) (Articles, error) {
+		as, _, err := f.articlesSince(c, articleRange{since: t})
+		return as, err
+	})
+}
 
-	f, _, err := r.FormFile("opml")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+func articlesInsertedSince(c appengine.Context, uinfo UserInfo, t time.Time) (Articles, []error) {
+	return userArticles(c, uinfo, func(f FeedInfo, title string) (Articles, error) {
+		return f.articlesInsertedSince(c, t)
+	})
+}
+
+// ArticlesPage returns the page of at most n of the articles of the user's
+// feeds, in the given order, that follows the article last, or the first
+// page if last is nil, as pageArticles does, along with the cursor of the
+// next page. Only enough of the articles of each feed to fill the page
+// are read.
+func articlesPage(c appengine.Context, uinfo UserInfo, order string, last *Article, n int) (Articles, string, []error) {
+	var mu sync.Mutex
+	more := false
+	articles, errs := userArticles(c, uinfo, func(f FeedInfo, title string) (Articles, error) {
+		r, ok := feedPageRange(order, title, last, n)
+		if !ok {
+			return nil, nil
+		}
+		as, m, err := f.articlesSince(c, r)
+		mu.Lock()
+		more = more || m
+		mu.Unlock()
+		return as, err
+	})
+	page, next := mergePage(articles, order, more, n)
+	return page, next, errs
+}
+
+// MergePage returns the page of at most n of the articles read from each
+// feed in its feedPageRange, in the given order, and the cursor of the
+// next page. More is whether some feeds have articles after those read.
+func mergePage(articles Articles, order string, more bool, n int) (Articles, string) {
+	sort.Sort(sortedArticles{articles, order})
+	page, next, _ := pageArticles(articles, order, "", n)
+	if next == "" && more && len(page) > 0 {
+		next = articleCursor(order, page[len(page)-1])
 	}
+	return page, next
+}
 
-	var b struct {
-		Body Outline `xml:"body"`
+// FeedPageRange returns the range of the articles of the feed with the
+// given title that can be on the page of at most n articles, in the given
+// order, that follows the article last, or the first page if last is nil.
+// It returns false if none of the feed's articles follow last.
+func feedPageRange(order, title string, last *Article, n int) (articleRange, bool) {
+	r := articleRange{order: order, limit: n}
+	if last == nil {
+		return r, true
 	}
-	err = xml.NewDecoder(f).Decode(&b)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	if order == byFeed {
+		switch t := strings.ToLower(title); {
+		case t < last.OriginTitle:
+			return articleRange{}, false
+		case t > last.OriginTitle:
+			return r, true
+		}
 	}
+	r.after = last.When
+	return r, true
+}
 
-	urls := opmlWalk(&b.Body, nil)
-
-	c.Debugf("Got %d URLs from OPML", len(urls))
+// UserArticles returns the articles selected by get from each of the user's
+// feeds, which is given the feed and the user's title for it. The feeds are
+// read concurrently, but the articles and errors are returned in the order
+// of the user's feeds.
+func userArticles(c appengine.Context, uinfo UserInfo, get func(f FeedInfo, title string) (Articles, error)) (articles Articles, errs []error) {
+	infos := make([]FeedInfo, len(uinfo.Feeds))
+	loadErrs := splitMultiError(len(infos), datastore.GetMulti(c, uinfo.Feeds, infos))
 
-	for _, url := range urls {
-		c.Debugf("opml %s", url)
-		f, err := checkUrl(c, url)
-		if err != nil {
-			http.Error(w, "failed to check URL "+url+": "+err.Error(), http.StatusInternalServerError)
+	results := make([]Articles, len(uinfo.Feeds))
+	feedErrs := make([]error, len(uinfo.Feeds))
+	parallel(len(uinfo.Feeds), maxParallelFeeds, func(i int) {
+		if err := loadErrs[i]; err != nil {
+			feedErrs[i] = fmt.Errorf("%s: failed to load from the datastore: %s", uinfo.Feeds[i].StringID(), err.Error())
 			return
 		}
-
-		if err = subscribe(c, f); err != nil {
-			http.Error(w, "failed to subscribe "+url+": "+err.Error(), http.StatusInternalServerError)
+		f := infos[i]
+		title := uinfo.title(i, f.Title)
+		as, err := get(f, title)
+		if err != nil {
+			feedErrs[i] = fmt.Errorf("%s: failed to read articles: %s", f.Url, err.Error())
 			return
 		}
+		as.setOrigin(title)
+		results[i] = as
+	})
+	for i, as := range results {
+		if feedErrs[i] != nil {
+			errs = append(errs, feedErrs[i])
+			continue
+		}
+		articles = append(articles, uinfo.unmuted(as)...)
 	}
-
-	http.Redirect(w, r, "/list", http.StatusFound)
+	return
 }
 
-func opmlWalk(r *Outline, urls []string) []string {
-	if r.XmlURL != "" {
-		urls = append(urls, r.XmlURL)
+// SplitMultiError returns the error for each of the n entities of a
+// datastore multi-entity call that returned err. If err is not an
+// appengine.MultiError, it is the error for every entity.
+func splitMultiError(n int, err error) []error {
+	if me, ok := err.(appengine.MultiError); ok {
+		return me
+	}
+	errs := make([]error, n)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
 	}
-	for _, kid := range r.Outlines {
-		urls = append(urls, opmlWalk(kid, nil)...)
+	return errs
+}
+
+// Parallel calls f(i) for each i in [0, n), with at most max calls running
+// at once, and returns when all of the calls have returned.
+func parallel(n, max int, f func(i int)) {
+	sem := make(chan struct{}, max)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			f(i)
+		}(i)
 	}
-	return urls
+	wg.Wait()
 }
 
 type errorList []error
@@ -277,11 +672,14 @@ func (es errorList) Error() string {
 
 func handleUpdate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		http.NotFound(w, r)
+		methodNotAllowed(w, "POST")
 		return
 	}
 
 	c := appengine.NewContext(r)
+	if !verifyCSRF(w, r, c) {
+		return
+	}
 	u, err := getUserInfo(c)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -289,11 +687,16 @@ func handleUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	curFeeds := make(map[string]bool)
+	// Normalized maps the normalized URL of each current feed to its URL.
+	normalized := make(map[string]string)
 	for _, f := range u.Feeds {
 		curFeeds[f.StringID()] = true
+		normalized[normalizeURL(f.StringID())] = f.StringID()
 	}
 
-	var errs errorList
+	var rep ImportReport
+	// Seen holds the normalized URLs that have been handled.
+	seen := make(map[string]bool)
 
 	urls := strings.Split(r.FormValue("urls"), "\n")
 	for _, url := range urls {
@@ -301,43 +704,215 @@ func handleUpdate(w http.ResponseWriter, r *http.Request) {
 		if len(url) == 0 {
 			continue
 		}
-		if curFeeds[url] {
-			delete(curFeeds, url)
+		norm := normalizeURL(url)
+		if cur, ok := normalized[norm]; ok && curFeeds[cur] {
+			// The feed is already subscribed, though perhaps
+			// under a differently written URL.
+			delete(curFeeds, cur)
+			seen[norm] = true
+			if cur != url {
+				rep.skip(url)
+			}
+		} else if seen[norm] {
+			rep.skip(url)
 		} else {
+			seen[norm] = true
+			if err := validateFeedURL(url); err != nil {
+				rep.fail(url, err)
+				continue
+			}
 			c.Debugf("Subscribing to [%s]", url)
 			f, err := checkUrl(c, url)
 			if err != nil {
 				err = fmt.Errorf("Failed to read %s: %s", url, err.Error())
-				errs = append(errs, err)
+				rep.fail(url, err)
 				continue
 			}
-			if err := subscribe(c, f); err != nil {
+			if f.Url != url {
+				// The URL redirects to the feed or is a web page
+				// that links to it. Keep the feed if it is already
+				// subscribed.
+				fnorm := normalizeURL(f.Url)
+				seen[fnorm] = true
+				if cur, ok := normalized[fnorm]; ok {
+					delete(curFeeds, cur)
+					rep.skip(url)
+					continue
+				}
+			}
+			if err := subscribe(c, f, ""); err != nil {
 				err = fmt.Errorf("Failed to subscribe to %s: %s", url, err.Error())
-				errs = append(errs, err)
+				rep.fail(url, err)
+				continue
 			}
+			rep.add(url)
 		}
 	}
 
 	for url := range curFeeds {
 		k := datastore.NewKey(c, feedKind, url, 0, nil)
-		c.Debugf("Unsubscribing from [%s]", url)
-		if err := unsubscribe(c, k); err != nil {
+		c.Debugf("Removing [%s]", url)
+		if err := removeFeed(c, k); err != nil {
 			err = fmt.Errorf("Failed to unsubscribe from %s: %s", url, err.Error())
-			errs = append(errs, err)
+			rep.fail(url, err)
 		}
 	}
 
-	if len(errs) > 0 {
-		http.Error(w, errs.Error(), http.StatusInternalServerError)
+	invalidateLatest(c)
+
+	if rep.empty() {
+		http.Redirect(w, r, "/list", http.StatusFound)
+		return
+	}
+	reportURL, err := saveImportReport(c, &rep)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, reportURL, http.StatusFound)
+}
+
+// NormalizeURL returns a form of a feed URL that is the same for URLs
+// that differ only in ways that do not matter for finding duplicate
+// subscriptions: the scheme, if it is http or https, the case of the
+// host, a default port, and a trailing slash. The result is only for
+// comparison; it is not itself a URL.
+func normalizeURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Host)
+	if h, port, err := net.SplitHostPort(host); err == nil && (port == "80" || port == "443") {
+		host = h
+	}
+	n := host + strings.TrimRight(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		n += "?" + u.RawQuery
+	}
+	if scheme != "http" && scheme != "https" {
+		n = scheme + "://" + n
+	}
+	return n
+}
+
+// HandleRenameFeed sets the current user's own title for the feed given by
+// the feed form value to the title form value. An empty title restores
+// the publisher's title.
+func handleRenameFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if !verifyCSRF(w, r, c) {
+		return
+	}
+
+	key, err := datastore.DecodeKey(r.FormValue("feed"))
+	if err != nil {
+		http.Error(w, "bad feed key", http.StatusBadRequest)
 		return
 	}
+	if err := renameFeed(c, key, strings.TrimSpace(r.FormValue("title"))); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	invalidateLatest(c)
+	http.Redirect(w, r, "/list", http.StatusFound)
+}
 
+// HandleRefreshInterval sets the refresh interval of the feed given by
+// the feed form value, which must be one of the current user's feeds, to
+// the number of minutes given by the minutes form value. If minutes is
+// empty, the default interval is used.
+func handleRefreshInterval(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if !verifyCSRF(w, r, c) {
+		return
+	}
+	uinfo, err := getUserInfo(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	key, err := datastore.DecodeKey(r.FormValue("feed"))
+	if err != nil || !uinfo.subscribed(key) {
+		http.Error(w, "bad feed key", http.StatusBadRequest)
+		return
+	}
+	d, err := parseRefreshMinutes(r.FormValue("minutes"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = datastore.RunInTransaction(c, func(c appengine.Context) error {
+		var f FeedInfo
+		if err := datastore.Get(c, key, &f); err != nil {
+			return err
+		}
+		f.RefreshInterval = d
+		_, err := datastore.Put(c, key, &f)
+		return err
+	}, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	http.Redirect(w, r, "/list", http.StatusFound)
 }
 
+// ParseRefreshMinutes returns the refresh interval of a feed given in
+// minutes. It must be between minRefreshInterval and maxRefreshInterval,
+// or empty for the default, which is returned as zero.
+func parseRefreshMinutes(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	m, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad number of minutes: %s", s)
+	}
+	d := time.Duration(m) * time.Minute
+	if d < minRefreshInterval || d > maxRefreshInterval {
+		return 0, fmt.Errorf("the refresh interval must be between %d and %d minutes",
+			minRefreshInterval/time.Minute, maxRefreshInterval/time.Minute)
+	}
+	return d, nil
+}
+
+// ParseLatestHours returns the duration of a latest view given in hours.
+// It must be between minLatestDuration and maxLatestDuration.
+func parseLatestHours(s string) (time.Duration, error) {
+	h, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("bad number of hours: %s", s)
+	}
+	d := time.Duration(h) * time.Hour
+	if d < minLatestDuration || d > maxLatestDuration {
+		return 0, fmt.Errorf("the latest view must be between %d and %d hours",
+			minLatestDuration/time.Hour, maxLatestDuration/time.Hour)
+	}
+	return d, nil
+}
+
 func handleRefresh(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		http.NotFound(w, r)
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if !verifyCSRF(w, r, c) {
 		return
 	}
 
@@ -347,7 +922,6 @@ func handleRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	c := appengine.NewContext(r)
 	c.Debugf("refreshing %s\n", k)
 
 	var f FeedInfo
@@ -356,10 +930,14 @@ func handleRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	lastFetch := f.LastFetch
 	if err = f.ensureFresh(c); err != nil {
 		http.Error(w, f.Url+" failed to refresh: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !f.LastFetch.Equal(lastFetch) {
+		invalidateFeedLatest(c, k)
+	}
 
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusResetContent)
@@ -368,24 +946,231 @@ func handleRefresh(w http.ResponseWriter, r *http.Request) {
 func handleRefreshAll(w http.ResponseWriter, r *http.Request) {
 	var errs errorList
 	c := appengine.NewContext(r)
-	for it := datastore.NewQuery(feedKind).KeysOnly().Run(c); ; {
-		k, err := it.Next(nil)
+
+	var keys []*datastore.Key
+	for it := datastore.NewQuery(feedKind).Run(c); ; {
+		var f FeedInfo
+		k, err := it.Next(&f)
 		if err == datastore.Done {
 			break
 		} else if err != nil {
 			errs = append(errs, err)
 			continue
 		}
-
-		c.Debugf("adding a task to refresh %s\n", k)
-		t := taskqueue.NewPOSTTask("/refresh", map[string][]string{"feed": {k.Encode()}})
-		if _, err := taskqueue.Add(c, t, ""); err != nil {
-			errs = append(errs, err)
+		if !f.Disabled {
+			keys = append(keys, k)
 		}
 	}
 
+	errs = append(errs, addRefreshTasks(c, keys)...)
+
 	if len(errs) > 0 {
 		http.Error(w, errs.Error(), http.StatusInternalServerError)
 	}
 	return
 }
+
+// HandleCronRefresh adds tasks to refresh the feeds that have not been
+// fetched within their refresh interval and are not disabled. Only App Engine
+// cron requests are served.
+func handleCronRefresh(w http.ResponseWriter, r *http.Request) {
+	if !isCron(r) {
+		http.Error(w, "only cron requests are allowed", http.StatusForbidden)
+		return
+	}
+
+	c := appengine.NewContext(r)
+	var infos []FeedInfo
+	keys, err := datastore.NewQuery(feedKind).
+		Filter("LastFetch <", staleBefore(time.Now())).
+		GetAll(c, &infos)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	keys = refreshKeys(keys, infos, time.Now())
+	c.Debugf("%d stale feeds\n", len(keys))
+
+	if errs := addRefreshTasks(c, keys); len(errs) > 0 {
+		http.Error(w, errs.Error(), http.StatusInternalServerError)
+	}
+}
+
+// HandleCronReindex stores again the feeds that are missing from the
+// LastFetch index, because they were stored before LastFetch was indexed,
+// so that the cron refresh finds them. Once every feed is indexed, it
+// only queries keys. Only App Engine cron requests are served.
+func handleCronReindex(w http.ResponseWriter, r *http.Request) {
+	if !isCron(r) {
+		http.Error(w, "only cron requests are allowed", http.StatusForbidden)
+		return
+	}
+
+	c := appengine.NewContext(r)
+	all, err := datastore.NewQuery(feedKind).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	indexed, err := datastore.NewQuery(feedKind).Filter("LastFetch >=", time.Time{}).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	missing := missingKeys(all, indexed)
+	c.Debugf("%d feeds missing from the LastFetch index\n", len(missing))
+
+	var errs errorList
+	for _, k := range missing {
+		err := datastore.RunInTransaction(c, func(c appengine.Context) error {
+			var f FeedInfo
+			if err := datastore.Get(c, k, &f); err != nil {
+				return err
+			}
+			_, err := datastore.Put(c, k, &f)
+			return err
+		}, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", k.StringID(), err))
+		}
+	}
+	if len(errs) > 0 {
+		http.Error(w, errs.Error(), http.StatusInternalServerError)
+	}
+}
+
+// MissingKeys returns the keys in all that are not in some.
+func missingKeys(all, some []*datastore.Key) []*datastore.Key {
+	found := make(map[string]bool, len(some))
+	for _, k := range some {
+		found[k.String()] = true
+	}
+	var missing []*datastore.Key
+	for _, k := range all {
+		if !found[k.String()] {
+			missing = append(missing, k)
+		}
+	}
+	return missing
+}
+
+// HandleEnableFeed re-enables the disabled feed given by the feed form
+// value, which must be one of the current user's feeds, and refreshes it.
+func handleEnableFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if !verifyCSRF(w, r, c) {
+		return
+	}
+	uinfo, err := getUserInfo(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	key, err := datastore.DecodeKey(r.FormValue("feed"))
+	if err != nil || !uinfo.subscribed(key) {
+		http.Error(w, "bad feed key", http.StatusBadRequest)
+		return
+	}
+
+	var f FeedInfo
+	err = datastore.RunInTransaction(c, func(c appengine.Context) error {
+		if err := datastore.Get(c, key, &f); err != nil {
+			return err
+		}
+		f.enable()
+		_, err := datastore.Put(c, key, &f)
+		return err
+	}, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := f.ensureFresh(c); err != nil {
+		c.Errorf("%s: failed to refresh: %s", f.Url, err)
+	} else {
+		invalidateFeedLatest(c, key)
+	}
+	http.Redirect(w, r, "/list", http.StatusFound)
+}
+
+// IsCron returns whether the request was made by App Engine cron. App
+// Engine removes the X-Appengine-Cron header from external requests.
+func isCron(r *http.Request) bool {
+	return r.Header.Get("X-Appengine-Cron") == "true"
+}
+
+// IsTask returns whether the request was made by the App Engine task
+// queue, which App Engine does not allow other requests to claim.
+func isTask(r *http.Request) bool {
+	return r.Header.Get("X-AppEngine-QueueName") != ""
+}
+
+// StaleBefore returns the time at now before which feeds must have last
+// been fetched to need a refresh. Feeds fetched before it may still be
+// fresh, if their refresh interval is longer than minRefreshInterval.
+func staleBefore(now time.Time) time.Time {
+	return now.Add(-minRefreshInterval)
+}
+
+// AddRefreshTasks adds a task to refresh each of the feeds, delayed by
+// refreshDelays.
+func addRefreshTasks(c appengine.Context, keys []*datastore.Key) errorList {
+	var errs errorList
+	urls := make([]string, len(keys))
+	for i, k := range keys {
+		urls[i] = k.StringID()
+	}
+	delays := refreshDelays(urls)
+
+	for i, k := range keys {
+		if err := addRefreshTask(c, k, delays[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// AddRefreshTask adds a task to refresh the feed with the given key after
+// delay.
+func addRefreshTask(c appengine.Context, k *datastore.Key, delay time.Duration) error {
+	c.Debugf("adding a task to refresh %s in %s\n", k, delay)
+	t := taskqueue.NewPOSTTask("/refresh", map[string][]string{"feed": {k.Encode()}})
+	t.Delay = delay
+	_, err := taskqueue.Add(c, t, "")
+	return err
+}
+
+// RefreshDelays returns the delay before refreshing each of the feed URLs,
+// spreading out the refreshes of feeds on the same host so that no more
+// than maxHostRefreshes of them are fetched at the same time.
+func refreshDelays(urls []string) []time.Duration {
+	n := make(map[string]int)
+	delays := make([]time.Duration, len(urls))
+	for i, u := range urls {
+		h := feedHost(u)
+		delays[i] = time.Duration(n[h]/maxHostRefreshes) * hostRefreshDelay
+		n[h]++
+	}
+	return delays
+}
+
+// FeedHost returns the normalized host name of a feed URL, or the URL
+// itself if it cannot be parsed.
+func feedHost(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return rawurl
+	}
+	h := strings.ToLower(u.Host)
+	if host, port, err := net.SplitHostPort(h); err == nil &&
+		(u.Scheme == "http" && port == "80" || u.Scheme == "https" && port == "443") {
+		h = host
+	}
+	return strings.TrimPrefix(h, "www.")
+}
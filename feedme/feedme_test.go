@@ -0,0 +1,635 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFeedHost(t *testing.T) {
+	tests := []struct {
+		url, host string
+	}{
+		{"http://example.com/feed", "example.com"},
+		{"http://EXAMPLE.com/feed", "example.com"},
+		{"http://www.example.com/feed", "example.com"},
+		{"http://example.com:80/feed", "example.com"},
+		{"https://example.com:443/feed", "example.com"},
+		{"http://example.com:8080/feed", "example.com:8080"},
+		{"not a url", "not a url"},
+	}
+	for _, test := range tests {
+		if h := feedHost(test.url); h != test.host {
+			t.Errorf("Expected feedHost(%s) to be %s, got %s", test.url, test.host, h)
+		}
+	}
+}
+
+func TestRefreshDelays(t *testing.T) {
+	urls := []string{
+		"http://a.com/1",
+		"http://b.com/1",
+		"http://a.com/2",
+		"http://www.a.com/3",
+		"http://A.com/4",
+		"http://b.com/2",
+		"http://a.com/5",
+	}
+	d := hostRefreshDelay
+	exp := []time.Duration{0, 0, d, 2 * d, 3 * d, d, 4 * d}
+	if maxHostRefreshes != 1 {
+		t.Fatalf("Test assumes maxHostRefreshes is 1, got %d", maxHostRefreshes)
+	}
+	if delays := refreshDelays(urls); !reflect.DeepEqual(delays, exp) {
+		t.Errorf("Expected delays %v, got %v", exp, delays)
+	}
+}
+
+func TestParallel(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 50} {
+		const max = 3
+		var mu sync.Mutex
+		running, peak := 0, 0
+		calls := make([]int, n)
+		parallel(n, max, func(i int) {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			calls[i]++
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+		})
+		for i, c := range calls {
+			if c != 1 {
+				t.Errorf("n=%d: expected f(%d) to be called once, got %d", n, i, c)
+			}
+		}
+		if peak > max {
+			t.Errorf("n=%d: expected at most %d concurrent calls, got %d", n, max, peak)
+		}
+	}
+}
+
+// TestParallelOrder checks that results collected by index from parallel
+// calls are in the same order as sequential calls.
+func TestParallelOrder(t *testing.T) {
+	const n = 20
+	var seq []int
+	for i := 0; i < n; i++ {
+		seq = append(seq, i*i)
+	}
+	par := make([]int, n)
+	parallel(n, 4, func(i int) {
+		time.Sleep(time.Duration(n-i) * 100 * time.Microsecond)
+		par[i] = i * i
+	})
+	if !reflect.DeepEqual(seq, par) {
+		t.Errorf("Expected %v, got %v", seq, par)
+	}
+}
+
+func TestSplitMultiError(t *testing.T) {
+	failed := errors.New("datastore unavailable")
+	tests := []struct {
+		name string
+		err  error
+		errs []error
+	}{
+		{"success", nil, []error{nil, nil, nil}},
+		{"one missing", appengine.MultiError{nil, datastore.ErrNoSuchEntity, nil}, []error{nil, datastore.ErrNoSuchEntity, nil}},
+		{"failed", failed, []error{failed, failed, failed}},
+	}
+	for _, test := range tests {
+		if errs := splitMultiError(3, test.err); !reflect.DeepEqual(errs, test.errs) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.errs, errs)
+		}
+	}
+}
+
+func TestCronRefreshGuard(t *testing.T) {
+	tests := []struct {
+		header string
+		cron   bool
+	}{
+		{header: "", cron: false},
+		{header: "false", cron: false},
+		{header: "true", cron: true},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/cron/refresh", nil)
+		if test.header != "" {
+			r.Header.Set("X-Appengine-Cron", test.header)
+		}
+		if cron := isCron(r); cron != test.cron {
+			t.Errorf("Expected isCron with header %q to be %t, got %t", test.header, test.cron, cron)
+		}
+		if test.cron {
+			continue
+		}
+		w := httptest.NewRecorder()
+		handleCronRefresh(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d with header %q, got %d", http.StatusForbidden, test.header, w.Code)
+		}
+	}
+}
+
+func TestCronReindexGuard(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleCronReindex(w, httptest.NewRequest("GET", "/cron/reindex", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestMissingKeys(t *testing.T) {
+	key := func(url string) *datastore.Key {
+		return datastore.NewKey(nil, feedKind, url, 0, nil)
+	}
+	all := []*datastore.Key{key("http://a.com"), key("http://b.com"), key("http://c.com")}
+	indexed := []*datastore.Key{key("http://c.com"), key("http://a.com")}
+	missing := missingKeys(all, indexed)
+	if len(missing) != 1 || missing[0].StringID() != "http://b.com" {
+		t.Errorf("Expected only http://b.com to be missing, got %v", missing)
+	}
+	if missing := missingKeys(all, all); len(missing) != 0 {
+		t.Errorf("Expected no keys to be missing, got %v", missing)
+	}
+}
+
+func TestStaleBefore(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		lastFetch time.Time
+		stale     bool
+	}{
+		{lastFetch: time.Time{}, stale: true},
+		{lastFetch: now.Add(-2 * minRefreshInterval), stale: true},
+		{lastFetch: now.Add(-minRefreshInterval - time.Second), stale: true},
+		{lastFetch: now.Add(-minRefreshInterval + time.Second), stale: false},
+		{lastFetch: now, stale: false},
+	}
+	for _, test := range tests {
+		if stale := test.lastFetch.Before(staleBefore(now)); stale != test.stale {
+			t.Errorf("Expected a feed fetched %s ago to be stale=%t, got %t", now.Sub(test.lastFetch), test.stale, stale)
+		}
+	}
+}
+
+func TestPageArticles(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var articles Articles
+	for i := 0; i < 23; i++ {
+		articles = append(articles, Article{Title: fmt.Sprint(i), When: base.Add(-time.Duration(i) * time.Hour)})
+	}
+	// Two articles share a time at the boundary of the second page.
+	articles = append(articles[:10], append(Articles{{Title: "tie", When: articles[9].When}}, articles[10:]...)...)
+
+	seen := make(map[string]bool)
+	var last time.Time
+	cursor := ""
+	pages := 0
+	for {
+		page, next, err := pageArticles(articles, newestFirst, cursor, 10)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		pages++
+		for _, a := range page {
+			if seen[a.Title] {
+				t.Errorf("Expected disjoint pages, got %s twice", a.Title)
+			}
+			seen[a.Title] = true
+			if !last.IsZero() && a.When.After(last) {
+				t.Errorf("Expected newest first, got %s after %s", a.When, last)
+			}
+			last = a.When
+		}
+		if next == "" {
+			break
+		}
+		if pages > len(articles) {
+			t.Fatalf("Expected paging to end")
+		}
+		cursor = next
+	}
+	if len(seen) != len(articles) {
+		t.Errorf("Expected %d articles across pages, got %d", len(articles), len(seen))
+	}
+	if pages != 3 {
+		t.Errorf("Expected 3 pages, got %d", pages)
+	}
+
+	if _, _, err := pageArticles(articles, newestFirst, "bad", 10); err == nil {
+		t.Errorf("Expected an error for a bad cursor")
+	}
+}
+
+func TestSortedArticles(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	articles := Articles{
+		{Title: "b1", OriginTitle: "B", When: base.Add(1 * time.Hour)},
+		{Title: "a2", OriginTitle: "a", When: base.Add(2 * time.Hour)},
+		{Title: "b3", OriginTitle: "B", When: base.Add(3 * time.Hour)},
+		{Title: "a0", OriginTitle: "a", When: base},
+	}
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{order: "", want: []string{"b3", "a2", "b1", "a0"}},
+		{order: newestFirst, want: []string{"b3", "a2", "b1", "a0"}},
+		{order: oldestFirst, want: []string{"a0", "b1", "a2", "b3"}},
+		{order: byFeed, want: []string{"a2", "a0", "b3", "b1"}},
+	}
+	for _, test := range tests {
+		as := append(Articles{}, articles...)
+		sort.Sort(sortedArticles{as, test.order})
+		var got []string
+		for _, a := range as {
+			got = append(got, a.Title)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.order, test.want, got)
+		}
+	}
+}
+
+func TestPageArticlesOrders(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var articles Articles
+	for i := 0; i < 25; i++ {
+		// Articles in different feeds share times.
+		articles = append(articles, Article{
+			Title:       fmt.Sprint(i),
+			OriginTitle: fmt.Sprint("feed ", i%3),
+			When:        base.Add(time.Duration(i/2) * time.Hour),
+		})
+	}
+	for _, order := range []string{newestFirst, oldestFirst, byFeed} {
+		sort.Sort(sortedArticles{articles, order})
+		var got Articles
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > len(articles) {
+				t.Fatalf("%s: expected paging to end", order)
+			}
+			page, next, err := pageArticles(articles, order, cursor, 4)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %s", order, err)
+			}
+			got = append(got, page...)
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if !reflect.DeepEqual(got, articles) {
+			t.Errorf("%s: expected the pages to hold %v, got %v", order, articles, got)
+		}
+	}
+
+	if _, _, err := pageArticles(articles, byFeed, "2020-01-01T00:00:00Z", 4); err == nil {
+		t.Errorf("Expected an error for a cursor without a feed")
+	}
+}
+
+// ReadRange returns the articles of a feed in the range, and whether more
+// follow, as FeedInfo.articlesSince reads them from the datastore.
+func readRange(feed Articles, r articleRange) (Articles, bool) {
+	order := newestFirst
+	if r.order == oldestFirst {
+		order = oldestFirst
+	}
+	var as Articles
+	for _, a := range feed {
+		if r.after.IsZero() || articleLess(order, Article{When: r.after}, a) {
+			as = append(as, a)
+		}
+	}
+	sort.Sort(sortedArticles{as, order})
+	if r.limit <= 0 || len(as) <= r.limit {
+		return as, false
+	}
+	last := as[r.limit].When
+	end := r.limit + 1
+	for end < len(as) && as[end].When.Equal(last) {
+		end++
+	}
+	return as[:end], true
+}
+
+func TestMergePage(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	feed := func(title string, hours ...int) Articles {
+		var as Articles
+		for i, h := range hours {
+			as = append(as, Article{
+				Title:       fmt.Sprint(title, " ", i),
+				OriginTitle: title,
+				When:        base.Add(time.Duration(h) * time.Hour),
+			})
+		}
+		return as
+	}
+	// B has more articles at the same time than fit on a page.
+	b := feed("B", 5, 5, 5, 5, 5, 5, 5, 2)
+	tests := []map[string]Articles{
+		{
+			"A": feed("A", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10),
+			"B": b,
+			"c": feed("c", 3, 11, 12),
+			"D": nil,
+		},
+		{"B": b},
+	}
+
+	for _, feeds := range tests {
+		n := 0
+		for _, f := range feeds {
+			n += len(f)
+		}
+		for _, order := range []string{newestFirst, oldestFirst, byFeed} {
+			var got Articles
+			var last *Article
+			for pages := 0; ; pages++ {
+				if pages > n {
+					t.Fatalf("%s: expected paging to end", order)
+				}
+				var read Articles
+				more := false
+				for title, f := range feeds {
+					r, ok := feedPageRange(order, title, last, 4)
+					if !ok {
+						continue
+					}
+					as, m := readRange(f, r)
+					read = append(read, as...)
+					more = more || m
+				}
+				page, next := mergePage(read, order, more, 4)
+				got = append(got, page...)
+				if next == "" {
+					break
+				}
+				a, err := parseArticleCursor(order, next)
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %s", order, err)
+				}
+				last = &a
+			}
+
+			seen := make(map[string]bool)
+			for i, a := range got {
+				if seen[a.Title] {
+					t.Errorf("%s: expected disjoint pages, got %s twice", order, a.Title)
+				}
+				seen[a.Title] = true
+				if i > 0 && articleLess(order, a, got[i-1]) {
+					t.Errorf("%s: expected %s before %s", order, a.Title, got[i-1].Title)
+				}
+			}
+			if len(got) != n {
+				t.Errorf("%s: expected %d articles of %d feeds, got %d", order, n, len(feeds), len(got))
+			}
+		}
+	}
+}
+
+func TestParseSortOrder(t *testing.T) {
+	for _, s := range []string{"", newestFirst, oldestFirst, byFeed} {
+		if o, err := parseSortOrder(s); err != nil || o != s {
+			t.Errorf("Expected %s, got %s, %v", s, o, err)
+		}
+	}
+	if _, err := parseSortOrder("random"); err == nil {
+		t.Errorf("Expected an error for an unknown order")
+	}
+}
+
+func TestParseRefreshMinutes(t *testing.T) {
+	tests := []struct {
+		s   string
+		d   time.Duration
+		err bool
+	}{
+		{s: "", d: 0},
+		{s: " 60 ", d: time.Hour},
+		{s: "10", d: minRefreshInterval},
+		{s: "10080", d: maxRefreshInterval},
+		{s: "5", err: true},
+		{s: "20000", err: true},
+		{s: "an hour", err: true},
+	}
+	for _, test := range tests {
+		d, err := parseRefreshMinutes(test.s)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %s", test.s, d)
+			}
+			continue
+		}
+		if err != nil || d != test.d {
+			t.Errorf("%q: expected %s, got %s, %v", test.s, test.d, d, err)
+		}
+	}
+}
+
+func TestParseLatestHours(t *testing.T) {
+	tests := []struct {
+		hours string
+		d     time.Duration
+		err   bool
+	}{
+		{hours: "1", d: time.Hour},
+		{hours: " 48 ", d: 48 * time.Hour},
+		{hours: "720", d: 720 * time.Hour},
+		{hours: "0", err: true},
+		{hours: "721", err: true},
+		{hours: "-3", err: true},
+		{hours: "a day", err: true},
+		{hours: "", err: true},
+	}
+	for _, test := range tests {
+		d, err := parseLatestHours(test.hours)
+		if test.err {
+			if err == nil {
+				t.Errorf("Expected an error for %q hours, got %s", test.hours, d)
+			}
+			continue
+		}
+		if err != nil || d != test.d {
+			t.Errorf("Expected %q hours to be %s, got %s, %v", test.hours, test.d, d, err)
+		}
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"http://example.com/feed", "http://example.com/feed", true},
+		{"http://example.com/feed", "http://example.com/feed/", true},
+		{"http://example.com", "http://example.com/", true},
+		{"http://example.com/feed", "https://example.com/feed", true},
+		{"http://Example.COM/feed", "http://example.com/feed", true},
+		{"HTTP://example.com/feed", "http://example.com/feed", true},
+		{"http://example.com:80/feed", "http://example.com/feed", true},
+		{"https://example.com:443/feed", "https://example.com/feed", true},
+		{"http://example.com/feed?a=1", "http://example.com/feed/?a=1", true},
+		{"http://example.com:8080/feed", "http://example.com/feed", false},
+		{"http://example.com/Feed", "http://example.com/feed", false},
+		{"http://example.com/feed?a=1", "http://example.com/feed?a=2", false},
+		{"http://example.com/feed", "http://example.org/feed", false},
+		{"ftp://example.com/feed", "http://example.com/feed", false},
+	}
+	for _, test := range tests {
+		na, nb := normalizeURL(test.a), normalizeURL(test.b)
+		if (na == nb) != test.same {
+			t.Errorf("Expected %s and %s to be the same=%t, got [%s] and [%s]", test.a, test.b, test.same, na, nb)
+		}
+	}
+}
+
+func TestCollapseDuplicates(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	articles := Articles{
+		{Title: "Aggregated", OriginTitle: "Aggregator", Link: "https://Example.com/story/", When: base.Add(time.Hour)},
+		{Title: "Original", OriginTitle: "Site", Link: "http://example.com/story", When: base},
+		{Title: "Other", OriginTitle: "Site", Link: "http://example.com/other", When: base},
+		{Title: "Same ID", OriginTitle: "Mirror", Link: "http://mirror.com/x", EntryID: "tag:example.com,2020:x", When: base.Add(2 * time.Hour)},
+		{Title: "ID", OriginTitle: "Site", Link: "http://example.com/x", EntryID: "tag:example.com,2020:x", When: base.Add(time.Minute)},
+		{Title: "Serial 1", OriginTitle: "A", Link: "http://a.com/1", EntryID: "1", When: base},
+		{Title: "Serial 2", OriginTitle: "B", Link: "http://b.com/1", EntryID: "1", When: base},
+	}
+	got := collapseDuplicates(articles)
+
+	alsoIn := make(map[string][]string)
+	for _, a := range got {
+		alsoIn[a.Title] = a.AlsoIn
+	}
+	want := map[string][]string{
+		"Original": {"Aggregator"},
+		"Other":    nil,
+		"ID":       {"Mirror"},
+		"Serial 1": nil,
+		"Serial 2": nil,
+	}
+	if !reflect.DeepEqual(alsoIn, want) {
+		t.Errorf("Expected %v, got %v", want, alsoIn)
+	}
+	if len(articles) != 7 || articles[0].Title != "Aggregated" || articles[0].AlsoIn != nil {
+		t.Errorf("Expected the articles to be unchanged")
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	api := func(h func(http.ResponseWriter, *http.Request, appengine.Context, *datastore.Key)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { h(w, r, nil, nil) }
+	}
+	tests := []struct {
+		path    string
+		handler http.HandlerFunc
+		method  string
+		allow   string
+	}{
+		{"/list", handleList, "POST", "GET"},
+		{"/update", handleUpdate, "GET", "POST"},
+		{"/refresh", handleRefresh, "GET", "POST"},
+		{"/addopml", handleOpml, "GET", "POST"},
+		{"/importjson", handleImportJSON, "GET", "POST"},
+		{"/exportopml", handleExportOpml, "POST", "GET"},
+		{"/export.csv", handleExportCSV, "POST", "GET"},
+		{"/settings", handleSettings, "PUT", "GET, POST"},
+		{"/settings/tokens", handleTokens, "PUT", "GET, POST"},
+		{"/enable", handleEnableFeed, "GET", "POST"},
+		{"/rename", handleRenameFeed, "GET", "POST"},
+		{"/fetchfull", handleFetchFull, "GET", "POST"},
+		{"/fullcontent", handleFullContent, "GET", "POST"},
+		{"/refreshInterval", handleRefreshInterval, "GET", "POST"},
+		{"/restore", handleRestore, "GET", "POST"},
+		{"/markRead", handleMarkRead, "GET", "POST"},
+		{"/article/key", handleArticle, "POST", "GET"},
+		{"/markAllRead", handleMarkAllRead, "GET", "POST"},
+		{"/discover", handleDiscover, "DELETE", "GET, POST"},
+		{"/reparse", handleReparse, "GET", "POST"},
+		{"/import", handleImportReport, "POST", "GET"},
+		{"/preview", handlePreview, "POST", "GET"},
+		{"/websub", handleWebSubCallback, "PUT", "GET, POST"},
+		{"/subscribe", handleQuickSubscribe, "PUT", "GET, POST"},
+		{"/feed.atom/tok", handleAtom, "POST", "GET"},
+		{"/api/articles", api(handleAPIArticles), "POST", "GET"},
+		{"/api/feeds", api(handleAPIFeeds), "POST", "GET"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		test.handler(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: expected status %d, got %d", test.method, test.path, http.StatusMethodNotAllowed, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("%s %s: expected Allow [%s], got [%s]", test.method, test.path, test.allow, allow)
+		}
+	}
+}
+
+func TestHideRead(t *testing.T) {
+	tests := []struct {
+		path   string
+		pref   bool
+		unread string
+		hide   bool
+	}{
+		{"/", false, "", false},
+		{"/", true, "", true},
+		{"/", false, "1", true},
+		{"/", true, "0", false},
+		{"/new", true, "", true},
+		{"/feedkey", false, "1", true},
+		{"/all", true, "", false},
+		{"/all", false, "1", false},
+	}
+	for _, test := range tests {
+		if hide := hideRead(test.path, test.pref, test.unread); hide != test.hide {
+			t.Errorf("Expected hideRead(%q, %t, %q) to be %t, got %t", test.path, test.pref, test.unread, test.hide, hide)
+		}
+	}
+}
+
+func TestUnreadArticles(t *testing.T) {
+	articles := Articles{
+		{Title: "read", Read: true},
+		{Title: "unread"},
+		{Title: "also read", Read: true},
+		{Title: "also unread"},
+	}
+	var titles []string
+	for _, a := range unreadArticles(articles) {
+		titles = append(titles, a.Title)
+	}
+	if want := []string{"unread", "also unread"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("Expected %v, got %v", want, titles)
+	}
+	if len(articles) != 4 {
+		t.Errorf("Expected the articles to be unchanged, got %d", len(articles))
+	}
+	if as := unreadArticles(Articles{{Title: "read", Read: true}}); len(as) != 0 {
+		t.Errorf("Expected no articles, got %d", len(as))
+	}
+}
@@ -0,0 +1,368 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"appengine/taskqueue"
+	"appengine/urlfetch"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/velour/feedme/readability"
+	"github.com/velour/feedme/webfeed"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// MinFullContent is the length of an article's content below which
+	// it is taken to be a summary, and the full content is fetched from
+	// the article's page for feeds with FetchFull set.
+	minFullContent = 500
+
+	// FullContentTimeout is how long fetching an article's page or its
+	// site's robots.txt may take.
+	fullContentTimeout = 10 * time.Second
+
+	// MaxPageSize is the most of an article's page that is read.
+	maxPageSize = 2 << 20
+
+	// RobotsAgent is the name matched against the User-agent lines of
+	// robots.txt.
+	robotsAgent = "feedme"
+)
+
+// ErrRobotsDisallowed is returned when a site's robots.txt does not allow
+// an article's page to be fetched.
+var errRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+func init() {
+	http.HandleFunc("/fetchfull", handleFetchFull)
+	http.HandleFunc("/fullcontent", handleFullContent)
+}
+
+// HandleFetchFull sets whether the full content of the articles of the
+// feed given by the feed form value, which must be one of the current
+// user's feeds, is fetched from their pages. It is fetched if the
+// fetchfull form value is "1".
+func handleFetchFull(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if !verifyCSRF(w, r, c) {
+		return
+	}
+	uinfo, err := getUserInfo(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	key, err := datastore.DecodeKey(r.FormValue("feed"))
+	if err != nil || !uinfo.subscribed(key) {
+		http.Error(w, "bad feed key", http.StatusBadRequest)
+		return
+	}
+
+	err = datastore.RunInTransaction(c, func(c appengine.Context) error {
+		var f FeedInfo
+		if err := datastore.Get(c, key, &f); err != nil {
+			return err
+		}
+		f.FetchFull = r.FormValue("fetchfull") == "1"
+		_, err := datastore.Put(c, key, &f)
+		return err
+	}, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/list", http.StatusFound)
+}
+
+// AddFullContentTasks adds tasks to fetch the full content of those of
+// the articles, stored with the given keys, that have only a short
+// summary. Each task fetches at most maxNewArticles pages, so that it
+// finishes well within the task deadline. Fetching pages in tasks keeps
+// the slow fetches out of interactive requests and out of a feed's host
+// lease.
+func addFullContentTasks(c appengine.Context, keys []*datastore.Key, articles Articles) error {
+	var short []string
+	for i, a := range articles {
+		if needsFullContent(a) {
+			short = append(short, keys[i].Encode())
+		}
+	}
+	for i := 0; i < len(short); i += maxNewArticles {
+		j := i + maxNewArticles
+		if j > len(short) {
+			j = len(short)
+		}
+		t := taskqueue.NewPOSTTask("/fullcontent", map[string][]string{"article": short[i:j]})
+		if _, err := taskqueue.Add(c, t, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleFullContent fetches the full content of the articles whose
+// encoded keys are given by the article form values, and stores the
+// articles whose content was found. Only task queue requests are served.
+func handleFullContent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, "POST")
+		return
+	}
+	if !isTask(r) {
+		http.Error(w, "only task queue requests are allowed", http.StatusForbidden)
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var keys []*datastore.Key
+	for _, s := range r.Form["article"] {
+		k, err := datastore.DecodeKey(s)
+		if err != nil || k.Kind() != articleKind {
+			http.Error(w, "bad article key", http.StatusBadRequest)
+			return
+		}
+		keys = append(keys, k)
+	}
+
+	articles := make(Articles, len(keys))
+	var found Articles
+	for i, err := range splitMultiError(len(keys), datastore.GetMulti(c, keys, articles)) {
+		switch err {
+		case nil:
+			articles[i].Key = keys[i]
+			found = append(found, articles[i])
+		case datastore.ErrNoSuchEntity:
+			// The article was deleted after the task was added.
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	fetchFullContent(c, fullContentClient(c), found)
+	var fullKeys []*datastore.Key
+	var full Articles
+	for _, a := range found {
+		if a.FullContent {
+			fullKeys = append(fullKeys, a.Key)
+			full = append(full, a)
+		}
+	}
+	if err := putArticles(c, fullKeys, full); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	invalidated := make(map[string]bool)
+	for _, k := range fullKeys {
+		if feed := k.Parent(); !invalidated[feed.StringID()] {
+			invalidateFeedLatest(c, feed)
+			invalidated[feed.StringID()] = true
+		}
+	}
+}
+
+// FullContentClient returns an http.Client for fetching articles' pages
+// that gives up after fullContentTimeout.
+func fullContentClient(c appengine.Context) *http.Client {
+	return &http.Client{
+		Transport: &urlfetch.Transport{Context: c, Deadline: fullContentTimeout},
+	}
+}
+
+// FetchFullContent replaces the content of the articles that have only a
+// short summary with the main content of their pages, fetched using the
+// given http.Client, and sets their FullContent. Articles whose pages
+// cannot be fetched, or have no content that can be found, keep their
+// summary.
+func fetchFullContent(c appengine.Context, client *http.Client, articles Articles) {
+	robots := make(map[string]robotsRules)
+	for i := range articles {
+		a := &articles[i]
+		if !needsFullContent(*a) {
+			continue
+		}
+		content, err := fetchArticleContent(client, robots, a.Link)
+		if err != nil {
+			c.Debugf("%s: failed to fetch the full content: %s", a.Link, err)
+			continue
+		}
+		a.DescriptionData = content
+		a.FullContent = true
+	}
+}
+
+// NeedsFullContent returns whether the article has only a short summary
+// and a page from which its full content can be fetched.
+func needsFullContent(a Article) bool {
+	return !a.FullContent && len(a.DescriptionData) < minFullContent && a.Link != ""
+}
+
+// FetchArticleContent returns the sanitized main content of the page at
+// link, if the site's robots.txt allows it to be fetched. The robots.txt
+// rules of each host are fetched once and kept in robots.
+func fetchArticleContent(client *http.Client, robots map[string]robotsRules, link string) ([]byte, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("cannot fetch %s", link)
+	}
+	rules, ok := robots[u.Host]
+	if !ok {
+		rules = fetchRobots(client, u)
+		robots[u.Host] = rules
+	}
+	if !rules.allows(u.RequestURI()) {
+		return nil, errRobotsDisallowed
+	}
+
+	req, err := newFetchRequest(link)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") {
+		return nil, fmt.Errorf("not a web page: %s", ct)
+	}
+	content, err := readability.Extract(io.LimitReader(resp.Body, maxPageSize), resp.Request.URL.String())
+	if err != nil {
+		return nil, err
+	}
+	return webfeed.SanitizeHTML(content), nil
+}
+
+// RobotsRules are the Allow and Disallow rules of a robots.txt that
+// apply to robotsAgent.
+type robotsRules []robotsRule
+
+type robotsRule struct {
+	allow bool
+	path  string
+}
+
+// DisallowAll is the rule used when a site's robots.txt cannot be read
+// because of a server or network error.
+var disallowAll = robotsRules{{allow: false, path: "/"}}
+
+// FetchRobots returns the robots.txt rules of the site of u. A missing
+// robots.txt allows everything, but one that cannot be fetched allows
+// nothing.
+func fetchRobots(client *http.Client, u *url.URL) robotsRules {
+	req, err := newFetchRequest(u.Scheme + "://" + u.Host + "/robots.txt")
+	if err != nil {
+		return disallowAll
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return disallowAll
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500:
+		return disallowAll
+	case resp.StatusCode != http.StatusOK:
+		return nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return disallowAll
+	}
+	return parseRobots(body, robotsAgent)
+}
+
+// ParseRobots returns the rules of the robots.txt body for the given
+// agent: those of the groups naming the agent, or if there are none,
+// those of the groups for all agents.
+func parseRobots(body []byte, agent string) robotsRules {
+	var mine, all robotsRules
+	var named, star, foundMine bool
+	// InAgents is true while reading the User-agent lines at the
+	// start of a group.
+	inAgents := false
+	s := bufio.NewScanner(bytes.NewReader(body))
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		val := strings.TrimSpace(line[i+1:])
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				named, star = false, false
+				inAgents = true
+			}
+			if val == "*" {
+				star = true
+			} else if strings.Contains(strings.ToLower(agent), strings.ToLower(val)) {
+				named, foundMine = true, true
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if val == "" {
+				// An empty Disallow allows everything.
+				continue
+			}
+			r := robotsRule{allow: key == "allow", path: val}
+			if named {
+				mine = append(mine, r)
+			}
+			if star {
+				all = append(all, r)
+			}
+		default:
+			inAgents = false
+		}
+	}
+	if foundMine {
+		return mine
+	}
+	return all
+}
+
+// Allows returns whether the rules allow the path, which includes any
+// query, to be fetched. The longest matching rule applies, and Allow
+// wins a tie. The * and $ wildcards are not supported; rules using them
+// match only literally.
+func (rs robotsRules) allows(path string) bool {
+	allow, longest := true, -1
+	for _, r := range rs {
+		if !strings.HasPrefix(path, r.path) {
+			continue
+		}
+		if n := len(r.path); n > longest || n == longest && r.allow {
+			allow, longest = r.allow, n
+		}
+	}
+	return allow
+}
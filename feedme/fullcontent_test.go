@@ -0,0 +1,169 @@
+package feedme
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const robotsTxt = `# Robots welcome, mostly.
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+
+User-agent: BadBot
+User-agent: FeedMe
+Disallow: /drafts
+Disallow:
+
+User-agent: OtherBot
+Disallow: /
+`
+
+func TestParseRobots(t *testing.T) {
+	tests := []struct {
+		agent, path string
+		allowed     bool
+	}{
+		{agent: "feedme", path: "/2020/article.html", allowed: true},
+		{agent: "feedme", path: "/drafts/article.html", allowed: false},
+		{agent: "feedme", path: "/private/article.html", allowed: true},
+		{agent: "somebot", path: "/private/article.html", allowed: false},
+		{agent: "somebot", path: "/private/public/article.html", allowed: true},
+		{agent: "somebot", path: "/drafts/article.html", allowed: true},
+		{agent: "otherbot", path: "/anything", allowed: false},
+	}
+	for _, test := range tests {
+		rules := parseRobots([]byte(robotsTxt), test.agent)
+		if allowed := rules.allows(test.path); allowed != test.allowed {
+			t.Errorf("%s %s: expected %t, got %t", test.agent, test.path, test.allowed, allowed)
+		}
+	}
+	if !parseRobots(nil, robotsAgent).allows("/anything") {
+		t.Errorf("Expected an empty robots.txt to allow everything")
+	}
+}
+
+func TestFetchFullContent(t *testing.T) {
+	var articleFetches int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nDisallow: /private/\n"))
+	})
+	page := `<html><body>
+		<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+		<div class="content">
+		<p>The full text of the article, which is much longer than the summary that the feed gave for it.</p>
+		<p>It goes on, at length, with a second paragraph, so that it is clearly the main content of the page.</p>
+		<script>alert("hi")</script>
+		</div>
+		<div class="comments"><p>A comment that is not part of the article, and should not be shown.</p></div>
+		</body></html>`
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		articleFetches++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	})
+	mux.HandleFunc("/private/article", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected the page disallowed by robots.txt not to be fetched")
+		w.Write([]byte(page))
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	long := strings.Repeat("x", minFullContent)
+	articles := Articles{
+		{Title: "short", Link: s.URL + "/article", DescriptionData: []byte("Summary…")},
+		{Title: "long", Link: s.URL + "/article", DescriptionData: []byte(long)},
+		{Title: "disallowed", Link: s.URL + "/private/article", DescriptionData: []byte("Private…")},
+		{Title: "missing", Link: s.URL + "/missing", DescriptionData: []byte("Missing…")},
+		{Title: "no link", DescriptionData: []byte("No link…")},
+	}
+	fetchFullContent(logContext{}, s.Client(), articles)
+
+	got := string(articles[0].DescriptionData)
+	if !strings.Contains(got, "The full text of the article") || !strings.Contains(got, "a second paragraph") {
+		t.Errorf("Expected the full content, got [%s]", got)
+	}
+	for _, boilerplate := range []string{"Home", "About", "alert", "A comment"} {
+		if strings.Contains(got, boilerplate) {
+			t.Errorf("Expected [%s] to be stripped, got [%s]", boilerplate, got)
+		}
+	}
+	if !articles[0].FullContent {
+		t.Errorf("Expected the short article to have its full content")
+	}
+	want := []string{long, "Private…", "Missing…", "No link…"}
+	for i, a := range articles[1:] {
+		if string(a.DescriptionData) != want[i] || a.FullContent {
+			t.Errorf("%s: expected the content to be kept, got [%s]", a.Title, a.DescriptionData)
+		}
+	}
+	if articleFetches != 1 {
+		t.Errorf("Expected only the short article's page to be fetched, got %d fetches", articleFetches)
+	}
+}
+
+func TestFetchRobotsErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+	robots := make(map[string]robotsRules)
+	if _, err := fetchArticleContent(s.Client(), robots, s.URL+"/article"); err != errRobotsDisallowed {
+		t.Errorf("Expected %v when robots.txt fails, got %v", errRobotsDisallowed, err)
+	}
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	if _, err := fetchArticleContent(missing.Client(), robots, missing.URL+"/article"); err == errRobotsDisallowed {
+		t.Errorf("Expected a missing robots.txt to allow fetching")
+	}
+}
+
+func TestRefreshedFetchFull(t *testing.T) {
+	stored := FeedInfo{Url: "http://example.com/feed", FetchFull: true}
+	if f := refreshed(stored, FeedInfo{Url: stored.Url}, time.Now()); !f.FetchFull {
+		t.Errorf("Expected FetchFull to be kept, got %+v", f)
+	}
+}
+
+func TestFullContentTaskGuard(t *testing.T) {
+	r := httptest.NewRequest("POST", "/fullcontent", strings.NewReader("article=key"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if isTask(r) {
+		t.Errorf("Expected a request without a queue name not to be a task")
+	}
+	w := httptest.NewRecorder()
+	handleFullContent(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	r.Header.Set("X-AppEngine-QueueName", "default")
+	if !isTask(r) {
+		t.Errorf("Expected a request with a queue name to be a task")
+	}
+}
+
+func TestNeedsFullContent(t *testing.T) {
+	long := []byte(strings.Repeat("x", minFullContent))
+	tests := []struct {
+		a    Article
+		need bool
+	}{
+		{Article{Link: "http://a.com/1", DescriptionData: []byte("Summary…")}, true},
+		{Article{Link: "http://a.com/1", DescriptionData: long}, false},
+		{Article{DescriptionData: []byte("Summary…")}, false},
+		{Article{Link: "http://a.com/1", DescriptionData: []byte("Short page"), FullContent: true}, false},
+	}
+	for _, test := range tests {
+		if need := needsFullContent(test.a); need != test.need {
+			t.Errorf("Expected needsFullContent(%+v) to be %t, got %t", test.a, test.need, need)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package feedme
+
+import (
+	"appengine"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	http.HandleFunc("/importjson", handleImportJSON)
+}
+
+// A jsonSubscription is a subscription in the subscriptions.json exported
+// by Google Reader's Takeout and by the readers that copied its format.
+type jsonSubscription struct {
+	// ID is "feed/" followed by the feed's URL.
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	HtmlURL string `json:"htmlUrl"`
+	// FeedURL is the feed's URL. Google Reader did not give it, so the
+	// URL is taken from ID if it is missing.
+	FeedURL    string         `json:"feedUrl"`
+	Categories []jsonCategory `json:"categories"`
+}
+
+// A jsonCategory is a category, or folder, of a subscription. It is
+// either a string or a Google Reader label like
+// {"id": "user/1/label/News", "label": "News"}.
+type jsonCategory struct {
+	Label string
+}
+
+func (jc *jsonCategory) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &jc.Label); err == nil {
+		return nil
+	}
+	var label struct {
+		ID    string `json:"id"`
+		Label string `json:"label"`
+	}
+	if err := json.Unmarshal(b, &label); err != nil {
+		return err
+	}
+	jc.Label = label.Label
+	if jc.Label == "" {
+		jc.Label = label.ID[strings.LastIndex(label.ID, "/")+1:]
+	}
+	return nil
+}
+
+// HandleImportJSON subscribes the current user to the feeds of an
+// uploaded subscriptions.json file, putting each in its first category.
+func handleImportJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if !verifyCSRF(w, r, c) {
+		return
+	}
+
+	f, _, err := r.FormFile("json")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	outlines, err := readJSONSubscriptions(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.Debugf("Got %d URLs from JSON", len(outlines))
+	importAndReport(w, r, c, outlines)
+}
+
+// ReadJSONSubscriptions returns an outline for each subscription with a
+// feed URL in a subscriptions.json file. The file is either an array of
+// subscriptions or, as Google Reader wrote it, an object holding them in
+// its subscriptions field.
+func readJSONSubscriptions(r io.Reader) ([]*Outline, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	var subs []jsonSubscription
+	if err := json.Unmarshal(raw, &subs); err != nil {
+		var export struct {
+			Subscriptions []jsonSubscription `json:"subscriptions"`
+		}
+		if err := json.Unmarshal(raw, &export); err != nil {
+			return nil, err
+		}
+		subs = export.Subscriptions
+	}
+
+	var outlines []*Outline
+	for _, s := range subs {
+		url := strings.TrimSpace(s.FeedURL)
+		if url == "" && strings.HasPrefix(s.ID, "feed/") {
+			url = strings.TrimSpace(strings.TrimPrefix(s.ID, "feed/"))
+		}
+		if url == "" {
+			continue
+		}
+		o := &Outline{Title: s.Title, Text: s.Title, XmlURL: url, HtmlURL: s.HtmlURL}
+		if len(s.Categories) > 0 {
+			o.Category = strings.TrimSpace(s.Categories[0].Label)
+		}
+		outlines = append(outlines, o)
+	}
+	return outlines, nil
+}
@@ -0,0 +1,77 @@
+package feedme
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadJSONSubscriptionsTakeout(t *testing.T) {
+	f, err := os.Open("testdata/subscriptions.json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer f.Close()
+
+	outlines, err := readJSONSubscriptions(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	exp := []*Outline{
+		{Title: "The Go Blog", Text: "The Go Blog", XmlURL: "http://blog.golang.org/feed.atom", HtmlURL: "http://blog.golang.org/", Category: "Programming"},
+		{Title: "xkcd.com", Text: "xkcd.com", XmlURL: "http://xkcd.com/rss.xml", HtmlURL: "http://xkcd.com/", Category: "Comics"},
+		{Title: "Uncategorized News", Text: "Uncategorized News", XmlURL: "https://example.com/news.rss", HtmlURL: "https://example.com/"},
+	}
+	if !reflect.DeepEqual(outlines, exp) {
+		t.Errorf("Expected %v, got %v", outlineURLs(exp), outlineURLs(outlines))
+	}
+}
+
+func TestReadJSONSubscriptions(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		exp  []*Outline
+		err  bool
+	}{
+		{
+			name: "array",
+			json: `[{"id": "a", "title": "A", "htmlUrl": "http://a.com/", "feedUrl": "http://a.com/feed", "categories": ["News", "Daily"]},
+				{"title": "B", "feedUrl": " http://b.com/feed "}]`,
+			exp: []*Outline{
+				{Title: "A", Text: "A", XmlURL: "http://a.com/feed", HtmlURL: "http://a.com/", Category: "News"},
+				{Title: "B", Text: "B", XmlURL: "http://b.com/feed"},
+			},
+		},
+		{name: "no feed URL", json: `[{"id": "a", "title": "A"}]`},
+		{name: "empty", json: `[]`},
+		{name: "not JSON", json: `<opml/>`, err: true},
+		{name: "bad categories", json: `[{"feedUrl": "http://a.com/feed", "categories": [1]}]`, err: true},
+	}
+	for _, test := range tests {
+		outlines, err := readJSONSubscriptions(strings.NewReader(test.json))
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", test.name, outlineURLs(outlines))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(outlines, test.exp) {
+			t.Errorf("%s: expected %v, got %v", test.name, outlineURLs(test.exp), outlineURLs(outlines))
+		}
+	}
+}
+
+// OutlineURLs returns the feed URLs and categories of the outlines.
+func outlineURLs(outlines []*Outline) []string {
+	var urls []string
+	for _, o := range outlines {
+		urls = append(urls, o.XmlURL+" in "+o.Category)
+	}
+	return urls
+}
@@ -0,0 +1,61 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/memcache"
+	"time"
+)
+
+// HostLeaseTTL is how long a host's lease lasts if it is not released,
+// such as when the instance holding it dies. It is longer than a refresh
+// usually takes.
+const hostLeaseTTL = 5 * time.Minute
+
+// A hostLeases grants leases on hosts, so that only one feed on each host
+// is fetched at a time. Acquire returns false if the host is already
+// leased.
+type hostLeases interface {
+	acquire(host string) bool
+	release(host string)
+}
+
+// A memcacheLeases is a hostLeases using memcache. If memcache fails, the
+// lease is granted, so that feeds are still refreshed. Errors are logged.
+type memcacheLeases struct {
+	c appengine.Context
+}
+
+func (m memcacheLeases) acquire(host string) bool {
+	err := memcache.Add(m.c, &memcache.Item{Key: hostLeaseKey(host), Value: []byte{1}, Expiration: hostLeaseTTL})
+	if err == memcache.ErrNotStored {
+		return false
+	}
+	if err != nil {
+		m.c.Errorf("failed to lease %s: %s", host, err)
+	}
+	return true
+}
+
+func (m memcacheLeases) release(host string) {
+	err := memcache.Delete(m.c, hostLeaseKey(host))
+	if err != nil && err != memcache.ErrCacheMiss {
+		m.c.Errorf("failed to release the lease of %s: %s", host, err)
+	}
+}
+
+// HostLeaseKey returns the memcache key of the lease of a host.
+func hostLeaseKey(host string) string {
+	return "hostLease/" + host
+}
+
+// WithHostLease calls fetch while holding the lease of the host of the
+// feed URL, returning fetch's error. If the host is already leased, fetch
+// is not called and false is returned.
+func withHostLease(leases hostLeases, url string, fetch func() error) (bool, error) {
+	host := feedHost(url)
+	if !leases.acquire(host) {
+		return false, nil
+	}
+	defer leases.release(host)
+	return true, fetch()
+}
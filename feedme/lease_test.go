@@ -0,0 +1,105 @@
+package feedme
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A mapLeases is a hostLeases held in memory.
+type mapLeases struct {
+	sync.Mutex
+	leased map[string]bool
+}
+
+func (m *mapLeases) acquire(host string) bool {
+	m.Lock()
+	defer m.Unlock()
+	if m.leased[host] {
+		return false
+	}
+	m.leased[host] = true
+	return true
+}
+
+func (m *mapLeases) release(host string) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.leased, host)
+}
+
+func TestWithHostLease(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight, fetches := 0, 0, 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		fetches++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Write([]byte(`<rss version="2.0"><channel><title>Feed</title></channel></rss>`))
+	}))
+	defer s.Close()
+
+	leases := &mapLeases{leased: make(map[string]bool)}
+	start := make(chan bool)
+	var wg sync.WaitGroup
+	for _, u := range []string{s.URL + "/a.rss", s.URL + "/b.rss"} {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			<-start
+			// A feed whose host is leased is retried later, as the
+			// task that refreshes it is added again with a delay.
+			for {
+				ok, err := withHostLease(leases, u, func() error {
+					_, _, err := fetchBodyWith(s.Client(), u)
+					return err
+				})
+				if err != nil {
+					t.Errorf("%s: unexpected error: %s", u, err)
+				}
+				if ok {
+					return
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		}(u)
+	}
+	close(start)
+	wg.Wait()
+
+	if fetches != 2 {
+		t.Errorf("Expected both feeds to be fetched, got %d fetches", fetches)
+	}
+	if maxInFlight != 1 {
+		t.Errorf("Expected the feeds on the same host to be fetched one at a time, got %d at once", maxInFlight)
+	}
+	if len(leases.leased) != 0 {
+		t.Errorf("Expected the leases to be released, got %v", leases.leased)
+	}
+}
+
+func TestWithHostLeaseOtherHosts(t *testing.T) {
+	leases := &mapLeases{leased: make(map[string]bool)}
+	ok, _ := withHostLease(leases, "http://example.com/a.rss", func() error {
+		if ok, _ := withHostLease(leases, "http://www.example.com/b.rss", func() error { return nil }); ok {
+			t.Errorf("Expected the same host to be leased")
+		}
+		if ok, _ := withHostLease(leases, "http://example.org/c.rss", func() error { return nil }); !ok {
+			t.Errorf("Expected another host to be leased separately")
+		}
+		return nil
+	})
+	if !ok {
+		t.Errorf("Expected the lease to be acquired")
+	}
+}
@@ -0,0 +1,70 @@
+package feedme
+
+import (
+	"github.com/velour/feedme/webfeed"
+	"strings"
+	"unicode"
+)
+
+// ParseKeywords returns the non-empty, trimmed lines of s, without
+// case-insensitive duplicates.
+func parseKeywords(s string) []string {
+	var keywords []string
+	seen := make(map[string]bool)
+	for _, k := range strings.Split(s, "\n") {
+		k = strings.TrimSpace(k)
+		if k == "" || seen[strings.ToLower(k)] {
+			continue
+		}
+		seen[strings.ToLower(k)] = true
+		keywords = append(keywords, k)
+	}
+	return keywords
+}
+
+// Unmuted returns the articles that contain none of the user's muted
+// keywords in their title or description.
+func (u UserInfo) unmuted(articles Articles) Articles {
+	if len(u.MutedKeywords) == 0 {
+		return articles
+	}
+	var muted [][]string
+	for _, k := range u.MutedKeywords {
+		if ws := words(k); len(ws) > 0 {
+			muted = append(muted, ws)
+		}
+	}
+	var as Articles
+	for _, a := range articles {
+		text := words(a.Title + " " + webfeed.TextSummary(a.DescriptionData, 0))
+		if !containsAny(text, muted) {
+			as = append(as, a)
+		}
+	}
+	return as
+}
+
+// Words returns the lower-case words of s. Words are runs of letters and
+// numbers.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// ContainsAny returns whether any of the phrases appears in text as a
+// run of whole words.
+func containsAny(text []string, phrases [][]string) bool {
+	for _, p := range phrases {
+	next:
+		for i := 0; i+len(p) <= len(text); i++ {
+			for j, w := range p {
+				if text[i+j] != w {
+					continue next
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package feedme
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeywords(t *testing.T) {
+	got := parseKeywords("spoiler\n\n  Game of Thrones \r\nSPOILER\n")
+	exp := []string{"spoiler", "Game of Thrones"}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Expected %q, got %q", exp, got)
+	}
+}
+
+func TestUnmuted(t *testing.T) {
+	u := UserInfo{UserPrefs: UserPrefs{MutedKeywords: []string{"spoiler", "Game of Thrones", "ad"}}}
+	articles := Articles{
+		{Title: "Spoiler: the ending"},
+		{Title: "A review", DescriptionData: []byte("<p>Watching <b>game of thrones</b> tonight</p>")},
+		{Title: "Adding numbers"},
+		{Title: "Thrones of games"},
+		{Title: "An ad, sponsored"},
+		{Title: "Spoilers are fine", DescriptionData: []byte(`<a href="/spoiler">link</a>`)},
+		{Title: "Marketing", DescriptionData: []byte("<p>On <i>ad</i>vertising</p>")},
+	}
+	var titles []string
+	for _, a := range u.unmuted(articles) {
+		titles = append(titles, a.Title)
+	}
+	exp := []string{"Adding numbers", "Thrones of games", "Spoilers are fine", "Marketing"}
+	if !reflect.DeepEqual(titles, exp) {
+		t.Errorf("Expected %q, got %q", exp, titles)
+	}
+
+	if as := (UserInfo{}).unmuted(articles); len(as) != len(articles) {
+		t.Errorf("Expected no articles to be muted without keywords, got %d of %d", len(as), len(articles))
+	}
+}
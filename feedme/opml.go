@@ -0,0 +1,237 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpmlNamespace is the namespace of the feedme-specific attributes written
+// to OPML exports in full mode. Other readers ignore these attributes.
+const opmlNamespace = "https://github.com/velour/feedme"
+
+type Outline struct {
+	Text     string     `xml:"text,attr,omitempty"`
+	Title    string     `xml:"title,attr,omitempty"`
+	Type     string     `xml:"type,attr,omitempty"`
+	XmlURL   string     `xml:"xmlUrl,attr,omitempty"`
+	HtmlURL  string     `xml:"htmlUrl,attr,omitempty"`
+	Outlines []*Outline `xml:"outline"`
+
+	// Category is the feed's category; it is only written in full mode.
+	Category string `xml:"https://github.com/velour/feedme category,attr,omitempty"`
+}
+
+type opml struct {
+	XMLName xml.Name   `xml:"opml"`
+	Version string     `xml:"version,attr"`
+	Title   string     `xml:"head>title"`
+	Body    []*Outline `xml:"body>outline"`
+}
+
+func handleOpml(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if !verifyCSRF(w, r, c) {
+		return
+	}
+
+	f, _, err := r.FormFile("opml")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var b struct {
+		Body Outline `xml:"body"`
+	}
+	err = xml.NewDecoder(f).Decode(&b)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	outlines := opmlWalk(&b.Body, "", nil)
+
+	c.Debugf("Got %d URLs from OPML", len(outlines))
+	importAndReport(w, r, c, outlines)
+}
+
+// ImportAndReport subscribes the current user to the feeds of the
+// outlines, in their categories, and redirects to the report of the
+// import.
+func importAndReport(w http.ResponseWriter, r *http.Request, c appengine.Context, outlines []*Outline) {
+	u, err := getUserInfo(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	seen := make(map[string]bool)
+	for _, k := range u.Feeds {
+		seen[k.StringID()] = true
+	}
+
+	rep := importOutlines(outlines, seen, func(o *Outline) error {
+		c.Debugf("importing %s", o.XmlURL)
+		if err := validateFeedURL(o.XmlURL); err != nil {
+			return err
+		}
+		f, err := checkUrl(c, o.XmlURL)
+		if err != nil {
+			return errors.New("failed to check URL: " + err.Error())
+		}
+		if err = subscribe(c, f, o.Category); err != nil {
+			return errors.New("failed to subscribe: " + err.Error())
+		}
+		return nil
+	})
+	invalidateLatest(c)
+
+	reportURL, err := saveImportReport(c, &rep)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, reportURL, http.StatusFound)
+}
+
+// ImportOutlines calls sub for each outline whose URL is not yet seen,
+// and returns a report of the outcome. A failure to subscribe to one
+// outline is recorded in the report, and the import continues with the
+// rest.
+func importOutlines(outlines []*Outline, seen map[string]bool, sub func(*Outline) error) ImportReport {
+	var rep ImportReport
+	for _, o := range outlines {
+		url := o.XmlURL
+		if seen[url] {
+			rep.skip(url)
+			continue
+		}
+		seen[url] = true
+		if err := sub(o); err != nil {
+			rep.fail(url, err)
+			continue
+		}
+		rep.add(url)
+	}
+	return rep
+}
+
+// OpmlWalk returns the outlines in the tree rooted at r that have a feed
+// URL. Outlines without a feed URL are folders, and each feed without a
+// Category is given the name of the innermost folder that encloses it, or
+// folder if there is none.
+func opmlWalk(r *Outline, folder string, feeds []*Outline) []*Outline {
+	if r.XmlURL != "" {
+		if r.Category == "" {
+			r.Category = folder
+		}
+		feeds = append(feeds, r)
+	} else if name := strings.TrimSpace(r.Text); name != "" {
+		folder = name
+	} else if name := strings.TrimSpace(r.Title); name != "" {
+		folder = name
+	}
+	for _, kid := range r.Outlines {
+		feeds = opmlWalk(kid, folder, feeds)
+	}
+	return feeds
+}
+
+// HandleExportOpml writes the current user's subscriptions as an OPML
+// document. If the full form value is set, feedme-specific metadata,
+// such as each feed's category, is included so that importing the
+// document restores it.
+func handleExportOpml(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	u, err := getUserInfo(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	infos := make([]FeedInfo, len(u.Feeds))
+	err = datastore.GetMulti(c, u.Feeds, infos)
+	if me, ok := err.(appengine.MultiError); ok {
+		// A subscription whose feed information is missing is still
+		// exported, with just its URL, rather than failing the export.
+		for i, err := range me {
+			if err == datastore.ErrNoSuchEntity {
+				c.Errorf("missing feed %s", u.Feeds[i].StringID())
+				infos[i] = FeedInfo{Url: u.Feeds[i].StringID()}
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	full := r.FormValue("full") != "" && r.FormValue("full") != "0"
+
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="feedme.opml"`)
+	if err := writeOpml(w, u, infos, full); err != nil {
+		c.Errorf("failed to write OPML: %s", err)
+	}
+}
+
+// WriteOpml writes an OPML document with an outline for each of the
+// user's feeds, whose information is given by infos. Feeds with a
+// category are written in a folder outline named for the category. OPML
+// 2.0 requires the text attribute, so feeds without a title use their URL
+// as text.
+func writeOpml(w io.Writer, u UserInfo, infos []FeedInfo, full bool) error {
+	doc := opml{Version: "2.0", Title: "feedme subscriptions"}
+	folders := make(map[string]*Outline)
+	for i, f := range infos {
+		text := u.title(i, f.Title)
+		if text == "" {
+			text = f.Url
+		}
+		o := &Outline{
+			Text:    text,
+			Title:   f.Title,
+			Type:    "rss",
+			XmlURL:  f.Url,
+			HtmlURL: f.Link,
+		}
+		if full {
+			o.Category = u.category(i)
+		}
+		cat := u.category(i)
+		if cat == "" {
+			doc.Body = append(doc.Body, o)
+			continue
+		}
+		folder := folders[cat]
+		if folder == nil {
+			folder = &Outline{Text: cat, Title: cat}
+			folders[cat] = folder
+			doc.Body = append(doc.Body, folder)
+		}
+		folder.Outlines = append(folder.Outlines, o)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "\t")
+	return e.Encode(doc)
+}
@@ -0,0 +1,122 @@
+package feedme
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpmlRoundTrip(t *testing.T) {
+	u := UserInfo{Categories: []string{"news", ""}}
+	infos := []FeedInfo{
+		{Url: "http://a.com/feed", Title: "A", Link: "http://a.com"},
+		{Url: "http://b.com/feed", Title: "B", Link: "http://b.com"},
+		{Url: "http://c.com/feed", Title: "C"},
+		{Url: "http://d.com/feed"},
+	}
+
+	for _, full := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := writeOpml(&buf, u, infos, full); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if hasNS := strings.Contains(buf.String(), opmlNamespace); hasNS != full {
+			t.Errorf("full=%t: expected the feedme namespace %t, got:\n%s", full, full, buf.String())
+		}
+
+		var b struct {
+			Body Outline `xml:"body"`
+		}
+		if err := xml.NewDecoder(&buf).Decode(&b); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		outlines := opmlWalk(&b.Body, "", nil)
+		if len(outlines) != len(infos) {
+			t.Fatalf("full=%t: expected %d outlines, got %d", full, len(infos), len(outlines))
+		}
+		for i, o := range outlines {
+			if o.XmlURL != infos[i].Url || o.Title != infos[i].Title || o.HtmlURL != infos[i].Link {
+				t.Errorf("full=%t: expected outline %d to be %+v, got %+v", full, i, infos[i], o)
+			}
+			if o.Text == "" {
+				t.Errorf("full=%t: expected outline %d to have text", full, i)
+			}
+			// The category is kept by the folder even when not
+			// written as an attribute.
+			cat := u.category(i)
+			if o.Category != cat {
+				t.Errorf("full=%t: expected outline %d to have category [%s], got [%s]", full, i, cat, o.Category)
+			}
+		}
+	}
+}
+
+func TestOpmlWalk(t *testing.T) {
+	const data = `<opml version="1.0"><body>
+<outline text="folder">
+	<outline xmlUrl="http://a.com/feed"/>
+	<outline text="nested"><outline xmlUrl="http://b.com/feed"/></outline>
+</outline>
+<outline xmlUrl="http://c.com/feed"/>
+<outline title="titled"><outline xmlUrl="http://d.com/feed" xmlns:feedme="https://github.com/velour/feedme" feedme:category="explicit"/></outline>
+</body></opml>`
+
+	var b struct {
+		Body Outline `xml:"body"`
+	}
+	if err := xml.Unmarshal([]byte(data), &b); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	var urls, cats []string
+	for _, o := range opmlWalk(&b.Body, "", nil) {
+		urls = append(urls, o.XmlURL)
+		cats = append(cats, o.Category)
+	}
+	exp := "http://a.com/feed http://b.com/feed http://c.com/feed http://d.com/feed"
+	if s := strings.Join(urls, " "); s != exp {
+		t.Errorf("Expected [%s], got [%s]", exp, s)
+	}
+	if exp := []string{"folder", "nested", "", "explicit"}; !reflect.DeepEqual(cats, exp) {
+		t.Errorf("Expected categories %q, got %q", exp, cats)
+	}
+}
+
+func TestImportOutlines(t *testing.T) {
+	outlines := []*Outline{
+		{XmlURL: "http://a.com/feed"},
+		{XmlURL: "http://dead.com/feed"},
+		{XmlURL: "http://b.com/feed"},
+		{XmlURL: "http://a.com/feed"},
+		{XmlURL: "http://subscribed.com/feed"},
+		{XmlURL: "http://full.com/feed"},
+	}
+	seen := map[string]bool{"http://subscribed.com/feed": true}
+	var subscribed []string
+	rep := importOutlines(outlines, seen, func(o *Outline) error {
+		switch o.XmlURL {
+		case "http://dead.com/feed":
+			return errors.New("failed to check URL: 404")
+		case "http://full.com/feed":
+			return errors.New("failed to subscribe: too many feeds")
+		}
+		subscribed = append(subscribed, o.XmlURL)
+		return nil
+	})
+
+	exp := []string{"http://a.com/feed", "http://b.com/feed"}
+	if !reflect.DeepEqual(subscribed, exp) || !reflect.DeepEqual(rep.Added, exp) {
+		t.Errorf("Expected to subscribe to %v, subscribed to %v and added %v", exp, subscribed, rep.Added)
+	}
+	if exp := []string{"http://a.com/feed", "http://subscribed.com/feed"}; !reflect.DeepEqual(rep.Skipped, exp) {
+		t.Errorf("Expected to skip %v, got %v", exp, rep.Skipped)
+	}
+	if exp := []string{"http://dead.com/feed", "http://full.com/feed"}; !reflect.DeepEqual(rep.Failed, exp) {
+		t.Errorf("Expected %v to fail, got %v", exp, rep.Failed)
+	}
+	if exp := []string{"failed to check URL: 404", "failed to subscribe: too many feeds"}; !reflect.DeepEqual(rep.Errors, exp) {
+		t.Errorf("Expected errors %v, got %v", exp, rep.Errors)
+	}
+}
@@ -0,0 +1,82 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/urlfetch"
+	"bytes"
+	"github.com/velour/feedme/webfeed"
+	"net/http"
+)
+
+// PreviewEntries is the number of entry titles included in a preview.
+const previewEntries = 5
+
+func init() {
+	http.HandleFunc("/preview", handlePreview)
+}
+
+// A preview describes a feed without subscribing to it.
+type preview struct {
+	Url   string `json:"url"`
+	Title string `json:"title,omitempty"`
+	Link  string `json:"link,omitempty"`
+	// Entries holds the titles of the first entries of the feed.
+	Entries []string `json:"entries,omitempty"`
+	// Candidates holds the URLs of the feeds that a web page links
+	// to, if the URL is a web page rather than a feed.
+	Candidates []string `json:"candidates,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// HandlePreview writes a JSON preview of the feed at the URL given by the
+// url form value, without subscribing to it.
+func handlePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	url := r.FormValue("url")
+	if err := validateFeedURL(url); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c := appengine.NewContext(r)
+	writeJSON(w, previewWith(urlfetch.Client(c), url))
+}
+
+// PreviewWith returns a preview of the feed at a URL, fetched using the
+// given http.Client. If the URL is a web page, the preview lists the feeds
+// that it links to. Errors are reported in the preview's Error field.
+func previewWith(client *http.Client, url string) preview {
+	p := preview{Url: url}
+	body, final, err := fetchBodyWith(client, url)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	f, err := webfeed.Read(bytes.NewReader(body))
+	if err == webfeed.ErrNotAFeed {
+		p.Candidates, err = webfeed.Discover(bytes.NewReader(body), final)
+		if err == nil && len(p.Candidates) == 0 {
+			err = webfeed.ErrNotAFeed
+		}
+		if err != nil {
+			p.Error = err.Error()
+		}
+		return p
+	}
+	if _, ok := err.(webfeed.ErrBadTimes); err != nil && !ok {
+		p.Error = err.Error()
+		return p
+	}
+	p.Title = f.Title
+	p.Link = f.Link
+	for i, e := range f.Entries {
+		if i == previewEntries {
+			break
+		}
+		p.Entries = append(p.Entries, e.Title)
+	}
+	return p
+}
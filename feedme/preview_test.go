@@ -0,0 +1,81 @@
+package feedme
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<rss version="2.0"><channel><title>Feed</title><link>http://example.com/</link>
+			<item><title>1</title></item><item><title>2</title></item><item><title>3</title></item>
+			<item><title>4</title></item><item><title>5</title></item><item><title>6</title></item>
+			</channel></rss>`))
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head>
+			<link rel="alternate" type="application/rss+xml" href="/feed">
+			<link rel="alternate" type="application/atom+xml" href="/atom">
+			</head><body></body></html>`))
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body>No feeds here.</body></html>`))
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	closed := httptest.NewServer(mux)
+	closedURL := closed.URL + "/feed"
+	closed.Close()
+
+	tests := []struct {
+		name string
+		url  string
+		want preview
+		err  bool
+	}{
+		{
+			name: "feed",
+			url:  s.URL + "/feed",
+			want: preview{
+				Url:     s.URL + "/feed",
+				Title:   "Feed",
+				Link:    "http://example.com/",
+				Entries: []string{"1", "2", "3", "4", "5"},
+			},
+		},
+		{
+			name: "web page",
+			url:  s.URL + "/page",
+			want: preview{
+				Url:        s.URL + "/page",
+				Candidates: []string{s.URL + "/feed", s.URL + "/atom"},
+			},
+		},
+		{
+			name: "web page without feeds",
+			url:  s.URL + "/plain",
+			want: preview{Url: s.URL + "/plain"},
+			err:  true,
+		},
+		{
+			name: "fetch error",
+			url:  closedURL,
+			want: preview{Url: closedURL},
+			err:  true,
+		},
+	}
+	for _, test := range tests {
+		p := previewWith(s.Client(), test.url)
+		if (p.Error != "") != test.err {
+			t.Errorf("%s: expected error=%t, got [%s]", test.name, test.err, p.Error)
+		}
+		p.Error = ""
+		if !reflect.DeepEqual(p, test.want) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.want, p)
+		}
+	}
+}
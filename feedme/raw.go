@@ -0,0 +1,187 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"appengine/taskqueue"
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// MaxRawBody is the largest raw feed body that is stored.
+	// Datastore entities are limited to 1MB.
+	maxRawBody = 900 * 1024
+
+	rawKind = "RawFeed"
+)
+
+func init() {
+	http.HandleFunc("/reparse", handleReparse)
+}
+
+// RawFeed is the raw body of a feed as of its last fetch.
+type RawFeed struct {
+	Body      []byte `datastore:",noindex"`
+	FetchedAt time.Time
+}
+
+// StoreRawBody stores the raw body of the feed fetched from url,
+// replacing any previously stored body.
+func storeRawBody(c appengine.Context, url string, body []byte) error {
+	if len(body) > maxRawBody {
+		c.Debugf("%s: not storing a %d byte raw body", url, len(body))
+		return nil
+	}
+	key := datastore.NewKey(c, rawKind, url, 0, nil)
+	_, err := datastore.Put(c, key, &RawFeed{Body: body, FetchedAt: time.Now()})
+	return err
+}
+
+// Reparse re-parses the stored raw body of a feed and updates the fields of
+// its stored articles in place, as reparseArticles does. It returns the
+// number of articles that were updated.
+func (f FeedInfo) reparse(c appengine.Context) (int, error) {
+	var raw RawFeed
+	if err := datastore.Get(c, datastore.NewKey(c, rawKind, f.Url, 0, nil), &raw); err != nil {
+		return 0, err
+	}
+
+	key := datastore.NewKey(c, feedKind, f.Url, 0, nil)
+	var stored Articles
+	keys, err := datastore.NewQuery(articleKind).Ancestor(key).GetAll(c, &stored)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]string, len(keys))
+	for i, k := range keys {
+		ids[i] = k.StringID()
+	}
+	changed, err := reparseBody(c, f.Url, raw.Body, stored, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, i := range changed {
+		if _, err := datastore.Put(c, keys[i], &stored[i]); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ReparseBody parses the raw body of the feed fetched from url and
+// updates the stored articles from it, as reparseArticles does.
+func reparseBody(c appengine.Context, url string, body []byte, stored Articles, ids []string) ([]int, error) {
+	_, articles, err := parseFeed(c, url, body)
+	if err != nil {
+		return nil, err
+	}
+	return reparseArticles(stored, ids, articles), nil
+}
+
+// ReparseArticles updates the fields of the stored articles, whose keys
+// have the given string IDs, from the articles parsed again from the raw
+// body of their feed, and returns the indices of the stored articles that
+// changed. Articles are matched by their link, or by their key if they
+// have no link, so that articles whose key would change (for example,
+// because a time is now parsable) are not duplicated. Parsed articles
+// that match no stored article are ignored. The stored content is kept
+// if it was fetched from the article's page.
+func reparseArticles(stored Articles, ids []string, parsed Articles) []int {
+	index := make(map[string]int, len(stored))
+	for i, a := range stored {
+		index[a.Link] = i
+		index[ids[i]] = i
+	}
+
+	var changed []int
+	for _, a := range parsed {
+		id := a.Link
+		if id == "" {
+			id = a.StringID()
+		}
+		i, ok := index[id]
+		if !ok {
+			continue
+		}
+		s := &stored[i]
+		if s.FullContent {
+			a.DescriptionData = s.DescriptionData
+			a.FullContent = true
+		}
+		if s.Title == a.Title && s.Link == a.Link && s.When.Equal(a.When) &&
+			s.OriginTitle == a.OriginTitle && s.EntryID == a.EntryID && bytes.Equal(s.DescriptionData, a.DescriptionData) {
+			continue
+		}
+		a.InsertedAt = s.InsertedAt
+		*s = a
+		changed = append(changed, i)
+	}
+	return changed
+}
+
+// HandleReparse re-parses the stored raw body of the feed given by the
+// encoded key in the feed form value, or, if no feed is given, adds a task
+// to re-parse each feed.
+func handleReparse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+
+	if r.FormValue("feed") == "" {
+		var errs errorList
+		n := 0
+		for it := datastore.NewQuery(feedKind).KeysOnly().Run(c); ; {
+			k, err := it.Next(nil)
+			if err == datastore.Done {
+				break
+			} else if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			t := taskqueue.NewPOSTTask("/reparse", map[string][]string{"feed": {k.Encode()}})
+			if _, err := taskqueue.Add(c, t, ""); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			n++
+		}
+		if len(errs) > 0 {
+			http.Error(w, errs.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "added %d reparse tasks\n", n)
+		return
+	}
+
+	k, err := datastore.DecodeKey(r.FormValue("feed"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var f FeedInfo
+	if err = datastore.Get(c, k, &f); err != nil {
+		http.Error(w, k.StringID()+" failed to load from the datastore: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	n, err := f.reparse(c)
+	if err != nil {
+		http.Error(w, f.Url+" failed to reparse: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Debugf("%s: reparse updated %d articles\n", f.Url, n)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "updated %d articles\n", n)
+}
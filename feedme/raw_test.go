@@ -0,0 +1,136 @@
+package feedme
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReparseArticlesFullContent(t *testing.T) {
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	stored := Articles{
+		{Title: "Old title", Link: "http://a.com/1", When: when, DescriptionData: []byte("<p>The full page</p>"), FullContent: true},
+		{Title: "Summary", Link: "http://a.com/2", When: when, DescriptionData: []byte("Old summary")},
+	}
+	parsed := Articles{
+		{Title: "New title", Link: "http://a.com/1", When: when, DescriptionData: []byte("Short…")},
+		{Title: "Summary", Link: "http://a.com/2", When: when, DescriptionData: []byte("New summary")},
+	}
+	changed := reparseArticles(stored, []string{"id1", "id2"}, parsed)
+	if exp := []int{0, 1}; !reflect.DeepEqual(changed, exp) {
+		t.Errorf("Expected changed %v, got %v", exp, changed)
+	}
+	if stored[0].Title != "New title" {
+		t.Errorf("Expected the title to be updated, got [%s]", stored[0].Title)
+	}
+	if string(stored[0].DescriptionData) != "<p>The full page</p>" || !stored[0].FullContent {
+		t.Errorf("Expected the full content to be kept, got [%s]", stored[0].DescriptionData)
+	}
+	if string(stored[1].DescriptionData) != "New summary" {
+		t.Errorf("Expected the summary to be updated, got [%s]", stored[1].DescriptionData)
+	}
+
+	if changed := reparseArticles(stored, []string{"id1", "id2"}, parsed); len(changed) != 0 {
+		t.Errorf("Expected no changes reparsing again, got %v", changed)
+	}
+}
+
+// A debugContext is a logContext that records its debug messages.
+type debugContext struct {
+	logContext
+	debug *[]string
+}
+
+func (d debugContext) Debugf(format string, args ...interface{}) {
+	*d.debug = append(*d.debug, fmt.Sprintf(format, args...))
+}
+
+func TestStoreRawBodyLimit(t *testing.T) {
+	tests := []struct {
+		size   int
+		stored bool
+	}{
+		{0, true},
+		{maxRawBody, true},
+		{maxRawBody + 1, false},
+	}
+	for _, test := range tests {
+		var debug []string
+		err := storeRawBody(debugContext{debug: &debug}, "http://a.com/feed", make([]byte, test.size))
+		if err != nil {
+			t.Errorf("%d bytes: unexpected error: %s", test.size, err)
+		}
+		if stored := len(debug) == 0; stored != test.stored {
+			t.Errorf("Expected a %d byte body to be stored %t, got %t: %v", test.size, test.stored, stored, debug)
+		}
+	}
+}
+
+func TestReparseBody(t *testing.T) {
+	const body = `<rss version="2.0"><channel><title>Feed</title>
+		<item><title>One</title><link>http://a.com/1</link><pubDate>Thu, 02 Jan 2020 03:04:05 GMT</pubDate>
+			<description>First</description></item>
+		<item><title>Two</title><link>http://a.com/2</link><pubDate>Fri, 03 Jan 2020 03:04:05 GMT</pubDate>
+			<description>Second</description></item>
+		<item><title>Three</title><link>http://a.com/3</link><pubDate>Sat, 04 Jan 2020 03:04:05 GMT</pubDate>
+			<description>Third</description></item>
+		</channel></rss>`
+	_, parsed, err := parseFeed(logContext{}, "http://a.com/feed", []byte(body))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	byLink := make(map[string]Article)
+	for _, a := range parsed {
+		byLink[a.Link] = a
+	}
+
+	// One was stored before its time could be parsed, so its key no
+	// longer matches, and Two was stored with an older title. Three
+	// was never stored.
+	inserted := time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)
+	one := byLink["http://a.com/1"]
+	one.When = time.Time{}
+	one.InsertedAt = inserted
+	two := byLink["http://a.com/2"]
+	two.Title = "Old"
+	stored := Articles{one, two}
+	ids := []string{one.StringID(), two.StringID()}
+
+	changed, err := reparseBody(logContext{}, "http://a.com/feed", []byte(body), stored, ids)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if exp := []int{0, 1}; !reflect.DeepEqual(changed, exp) {
+		t.Errorf("Expected changed %v, got %v", exp, changed)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("Expected the 2 stored articles to be updated in place, got %d", len(stored))
+	}
+	if !stored[0].When.Equal(byLink["http://a.com/1"].When) || !stored[0].InsertedAt.Equal(inserted) {
+		t.Errorf("Expected One to get its time and keep its insertion time, got %+v", stored[0])
+	}
+	if stored[1].Title != "Two" {
+		t.Errorf("Expected Two to get its title, got [%s]", stored[1].Title)
+	}
+
+	if changed, _ := reparseBody(logContext{}, "http://a.com/feed", []byte(body), stored, ids); len(changed) != 0 {
+		t.Errorf("Expected no changes reparsing again, got %v", changed)
+	}
+	if _, err := reparseBody(logContext{}, "http://a.com/feed", []byte("not a feed"), stored, ids); err == nil {
+		t.Errorf("Expected an error reparsing a body that is not a feed")
+	}
+}
+
+func TestReparseBadFeed(t *testing.T) {
+	r := httptest.NewRequest("POST", "/reparse", strings.NewReader("feed=bad"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handleReparse(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,333 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RemovalDelay is how long after a user removes a feed they are finally
+// unsubscribed from it. Until then, the removal can be undone.
+const removalDelay = 10 * time.Minute
+
+func init() {
+	http.HandleFunc("/restore", handleRestore)
+	http.HandleFunc("/cron/removals", handleCronRemovals)
+}
+
+// A removedFeed is a feed pending removal, as shown on the feeds page.
+type removedFeed struct {
+	// Title is the user's own title for the feed, or its URL.
+	Title      string
+	EncodedKey string
+	// RemovedAt is when the feed was removed.
+	RemovedAt time.Time
+}
+
+// RemovedFeeds returns the user's feeds that are pending removal.
+func (u UserInfo) removedFeeds() []removedFeed {
+	removed := make([]removedFeed, len(u.PendingRemoval))
+	for j, k := range u.PendingRemoval {
+		removed[j] = removedFeed{
+			Title:      k.StringID(),
+			EncodedKey: k.Encode(),
+			RemovedAt:  u.PendingRemovalAt[j],
+		}
+		if u.PendingTitles[j] != "" {
+			removed[j].Title = u.PendingTitles[j]
+		}
+	}
+	return removed
+}
+
+// PendingIndex returns the index of the feed among the user's feeds
+// pending removal, or -1 if it is not pending removal.
+func (u UserInfo) pendingIndex(feed *datastore.Key) int {
+	for j, k := range u.PendingRemoval {
+		if k.Equal(feed) {
+			return j
+		}
+	}
+	return -1
+}
+
+// MarkRemoved moves the ith feed, with its category and title, from the
+// user's feeds to the feeds pending removal, as removed at now.
+func (u *UserInfo) markRemoved(i int, now time.Time) {
+	u.PendingRemoval = append(u.PendingRemoval, u.Feeds[i])
+	u.PendingRemovalAt = append(u.PendingRemovalAt, now)
+	u.PendingCategories = append(u.PendingCategories, u.category(i))
+	u.PendingTitles = append(u.PendingTitles, u.title(i, ""))
+
+	u.Feeds = append(u.Feeds[:i], u.Feeds[i+1:]...)
+	if i < len(u.Categories) {
+		u.Categories = append(u.Categories[:i], u.Categories[i+1:]...)
+	}
+	if i < len(u.Titles) {
+		u.Titles = append(u.Titles[:i], u.Titles[i+1:]...)
+	}
+	u.setNextRemoval()
+}
+
+// Restore moves the jth feed pending removal back to the user's feeds,
+// with the category and title that it had.
+func (u *UserInfo) restore(j int) error {
+	if len(u.Feeds) >= maxFeeds {
+		return fmt.Errorf("Too many feeds, max is %d", maxFeeds)
+	}
+	for len(u.Categories) < len(u.Feeds) {
+		u.Categories = append(u.Categories, "")
+	}
+	for len(u.Titles) < len(u.Feeds) {
+		u.Titles = append(u.Titles, "")
+	}
+	u.Feeds = append(u.Feeds, u.PendingRemoval[j])
+	u.Categories = append(u.Categories, u.PendingCategories[j])
+	u.Titles = append(u.Titles, u.PendingTitles[j])
+	u.dropPending(j)
+	return nil
+}
+
+// DropPending forgets the jth feed pending removal.
+func (u *UserInfo) dropPending(j int) {
+	u.PendingRemoval = append(u.PendingRemoval[:j], u.PendingRemoval[j+1:]...)
+	u.PendingRemovalAt = append(u.PendingRemovalAt[:j], u.PendingRemovalAt[j+1:]...)
+	u.PendingCategories = append(u.PendingCategories[:j], u.PendingCategories[j+1:]...)
+	u.PendingTitles = append(u.PendingTitles[:j], u.PendingTitles[j+1:]...)
+	u.setNextRemoval()
+}
+
+// SetNextRemoval sets NextRemoval to when the earliest of the feeds
+// pending removal is due, or to zero if there are none.
+func (u *UserInfo) setNextRemoval() {
+	u.NextRemoval = time.Time{}
+	for _, t := range u.PendingRemovalAt {
+		if due := t.Add(removalDelay); u.NextRemoval.IsZero() || due.Before(u.NextRemoval) {
+			u.NextRemoval = due
+		}
+	}
+}
+
+// RemovalDue returns whether the jth feed pending removal is due to be
+// finally removed at now.
+func (u UserInfo) removalDue(j int, now time.Time) bool {
+	return !now.Before(u.PendingRemovalAt[j].Add(removalDelay))
+}
+
+// DueRemovals returns the feeds pending removal that are due to be
+// finally removed at now.
+func (u UserInfo) dueRemovals(now time.Time) []*datastore.Key {
+	var due []*datastore.Key
+	for j, k := range u.PendingRemoval {
+		if u.removalDue(j, now) {
+			due = append(due, k)
+		}
+	}
+	return due
+}
+
+// RemoveFeed removes a feed from the current user's feed list. The user
+// is not unsubscribed from it until removalDelay has passed, so that the
+// removal can be undone, but it no longer counts toward the feed's
+// subscribers in the discover view.
+func removeFeed(c appengine.Context, feedKey *datastore.Key) error {
+	return datastore.RunInTransaction(c, func(c appengine.Context) error {
+		u, err := getUserInfo(c)
+		if err != nil {
+			return err
+		}
+		i := u.feedIndex(feedKey)
+		if i < 0 {
+			return nil
+		}
+		if !u.NoDiscover {
+			if err := addFeedSubscribers(c, feedKey, -1); err != nil {
+				return err
+			}
+		}
+		u.markRemoved(i, time.Now())
+		_, err = datastore.Put(c, userInfoKey(c), &u)
+		return err
+	}, &datastore.TransactionOptions{XG: true})
+}
+
+// RestoreFeed returns a feed pending removal to the current user's feed
+// list. It returns false if the feed is not pending removal.
+func restoreFeed(c appengine.Context, feedKey *datastore.Key) (bool, error) {
+	restored := false
+	err := datastore.RunInTransaction(c, func(c appengine.Context) error {
+		u, err := getUserInfo(c)
+		if err != nil {
+			return err
+		}
+		restored, err = restorePending(c, &u, feedKey)
+		if err != nil || !restored {
+			return err
+		}
+		_, err = datastore.Put(c, userInfoKey(c), &u)
+		return err
+	}, &datastore.TransactionOptions{XG: true})
+	return restored, err
+}
+
+// RestorePending returns a feed pending removal to the user's feed list
+// and counts it again among the feed's subscribers. The user is not
+// stored. It returns false if the feed is not pending removal. It must
+// be called in a cross-group transaction.
+func restorePending(c appengine.Context, u *UserInfo, feedKey *datastore.Key) (bool, error) {
+	j := u.pendingIndex(feedKey)
+	if j < 0 {
+		return false, nil
+	}
+	if err := u.restore(j); err != nil {
+		return false, err
+	}
+	if !u.NoDiscover {
+		if err := addFeedSubscribers(c, feedKey, 1); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// AddFeedSubscribers adds delta to the Subscribers of the feed.
+func addFeedSubscribers(c appengine.Context, feedKey *datastore.Key, delta int) error {
+	var f FeedInfo
+	if err := datastore.Get(c, feedKey, &f); err != nil {
+		return err
+	}
+	f.Subscribers += delta
+	if f.Subscribers < 0 {
+		f.Subscribers = 0
+	}
+	_, err := datastore.Put(c, feedKey, &f)
+	return err
+}
+
+// FinalizeRemovals unsubscribes the user with the given key from the
+// feeds whose removal is due at now.
+func finalizeRemovals(c appengine.Context, userKey *datastore.Key, now time.Time) error {
+	u, err := loadUserInfo(c, userKey)
+	if err != nil {
+		return err
+	}
+	var errs errorList
+	for _, k := range u.dueRemovals(now) {
+		if err := finalizeRemoval(c, userKey, k, now); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", k.StringID(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// FinalizeRemoval unsubscribes the user with the given key from a feed
+// pending removal, if it is still pending and is due at now. If the user
+// was the feed's last subscriber, the feed and its articles are deleted.
+// The articles are deleted after the feed, outside of the transaction.
+func finalizeRemoval(c appengine.Context, userKey, feedKey *datastore.Key, now time.Time) error {
+	var deleted FeedInfo
+	err := datastore.RunInTransaction(c, func(c appengine.Context) error {
+		deleted = FeedInfo{}
+		u, err := loadUserInfo(c, userKey)
+		if err != nil {
+			return err
+		}
+		j := u.pendingIndex(feedKey)
+		if j < 0 || !u.removalDue(j, now) {
+			// The removal was undone.
+			return nil
+		}
+
+		var f FeedInfo
+		if err := datastore.Get(c, feedKey, &f); err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		f.Refs--
+		if f.Refs <= 0 {
+			deleted = FeedInfo{Url: feedKey.StringID()}
+			if err := datastore.Delete(c, feedKey); err != nil && err != datastore.ErrNoSuchEntity {
+				return err
+			}
+			rawKey := datastore.NewKey(c, rawKind, feedKey.StringID(), 0, nil)
+			if err := datastore.Delete(c, rawKey); err != nil && err != datastore.ErrNoSuchEntity {
+				return err
+			}
+		} else if _, err := datastore.Put(c, feedKey, &f); err != nil {
+			return err
+		}
+
+		u.dropPending(j)
+		_, err = datastore.Put(c, userKey, &u)
+		return err
+	}, &datastore.TransactionOptions{XG: true})
+	if err != nil || deleted.Url == "" {
+		return err
+	}
+	return deleted.rmArticles(c)
+}
+
+// HandleRestore undoes the removal of the feed given by the feed form
+// value, if it is still pending.
+func handleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if !verifyCSRF(w, r, c) {
+		return
+	}
+	key, err := datastore.DecodeKey(r.FormValue("feed"))
+	if err != nil {
+		http.Error(w, "bad feed key", http.StatusBadRequest)
+		return
+	}
+	restored, err := restoreFeed(c, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !restored {
+		http.Error(w, "the feed has already been removed", http.StatusNotFound)
+		return
+	}
+	invalidateLatest(c)
+	http.Redirect(w, r, "/list", http.StatusFound)
+}
+
+// HandleCronRemovals finally unsubscribes users from the feeds that they
+// removed more than removalDelay ago. Only App Engine cron requests are
+// served.
+func handleCronRemovals(w http.ResponseWriter, r *http.Request) {
+	if !isCron(r) {
+		http.Error(w, "only cron requests are allowed", http.StatusForbidden)
+		return
+	}
+
+	c := appengine.NewContext(r)
+	now := time.Now()
+	users, err := datastore.NewQuery(userKind).
+		Filter("NextRemoval >", time.Time{}).
+		Filter("NextRemoval <=", now).
+		KeysOnly().
+		GetAll(c, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var errs errorList
+	for _, u := range users {
+		if err := finalizeRemovals(c, u, now); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", u.StringID(), err))
+		}
+	}
+	if len(errs) > 0 {
+		http.Error(w, errs.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,92 @@
+package feedme
+
+import (
+	"appengine/datastore"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// NewRemovalTestUser returns a user with three feeds, the second of which
+// has a category and a title.
+func newRemovalTestUser() UserInfo {
+	return UserInfo{
+		Feeds:      make([]*datastore.Key, 3),
+		Categories: []string{"", "news"},
+		Titles:     []string{"", "My News"},
+	}
+}
+
+func TestRemovalUndo(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	u := newRemovalTestUser()
+	u.markRemoved(1, now)
+
+	if len(u.Feeds) != 2 || len(u.Categories) != 1 || len(u.Titles) != 1 {
+		t.Errorf("Expected the feed to be removed from the feed list, got %d feeds, categories %v, titles %v",
+			len(u.Feeds), u.Categories, u.Titles)
+	}
+	if len(u.PendingRemoval) != 1 || u.PendingCategories[0] != "news" || u.PendingTitles[0] != "My News" {
+		t.Errorf("Expected the feed to be pending removal with its category and title, got %+v", u)
+	}
+	if want := now.Add(removalDelay); !u.NextRemoval.Equal(want) {
+		t.Errorf("Expected the next removal at %s, got %s", want, u.NextRemoval)
+	}
+	if due := u.dueRemovals(now.Add(removalDelay - time.Second)); len(due) != 0 {
+		t.Errorf("Expected no removals to be due within %s, got %d", removalDelay, len(due))
+	}
+
+	if err := u.restore(0); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(u.Feeds) != 3 || len(u.PendingRemoval) != 0 || !u.NextRemoval.IsZero() {
+		t.Errorf("Expected the feed to be restored, got %d feeds, %d pending, next removal %s",
+			len(u.Feeds), len(u.PendingRemoval), u.NextRemoval)
+	}
+	if u.category(2) != "news" || u.title(2, "") != "My News" {
+		t.Errorf("Expected the restored feed to keep its category and title, got [%s] [%s]", u.category(2), u.title(2, ""))
+	}
+	if !reflect.DeepEqual(u.Categories, []string{"", "", "news"}) || !reflect.DeepEqual(u.Titles, []string{"", "", "My News"}) {
+		t.Errorf("Expected the categories and titles to stay parallel to the feeds, got %v, %v", u.Categories, u.Titles)
+	}
+}
+
+func TestRemovalFinalize(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	u := newRemovalTestUser()
+	u.markRemoved(0, now)
+	u.markRemoved(0, now.Add(5*time.Minute))
+	if want := now.Add(removalDelay); !u.NextRemoval.Equal(want) {
+		t.Errorf("Expected the next removal at %s, got %s", want, u.NextRemoval)
+	}
+
+	later := now.Add(removalDelay)
+	due := u.dueRemovals(later)
+	if len(due) != 1 || !u.removalDue(0, later) || u.removalDue(1, later) {
+		t.Fatalf("Expected only the first removal to be due, got %d due", len(due))
+	}
+	u.dropPending(0)
+	if len(u.PendingRemoval) != 1 || len(u.Feeds) != 1 {
+		t.Errorf("Expected one feed left pending and one subscribed, got %d and %d", len(u.PendingRemoval), len(u.Feeds))
+	}
+	if want := now.Add(5*time.Minute + removalDelay); !u.NextRemoval.Equal(want) {
+		t.Errorf("Expected the next removal at %s, got %s", want, u.NextRemoval)
+	}
+
+	u.dropPending(0)
+	if len(u.PendingRemoval) != 0 || !u.NextRemoval.IsZero() {
+		t.Errorf("Expected no removals left, got %d pending, next removal %s", len(u.PendingRemoval), u.NextRemoval)
+	}
+}
+
+func TestRestoreTooManyFeeds(t *testing.T) {
+	u := UserInfo{Feeds: make([]*datastore.Key, maxFeeds+1)}
+	u.markRemoved(0, time.Now())
+	u.Feeds = append(u.Feeds, nil)
+	if err := u.restore(0); err == nil {
+		t.Errorf("Expected an error restoring beyond %d feeds", maxFeeds)
+	}
+	if len(u.PendingRemoval) != 1 {
+		t.Errorf("Expected the feed to stay pending removal")
+	}
+}
@@ -0,0 +1,133 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"appengine/user"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// ReportLifetime is how long import reports are kept.
+	reportLifetime = 24 * time.Hour
+
+	reportKind = "ImportReport"
+)
+
+func init() {
+	http.HandleFunc("/import", handleImportReport)
+}
+
+// An ImportReport records the outcome of subscribing to a batch of feeds,
+// either from an OPML file or from the subscription list.
+type ImportReport struct {
+	// User is the key of the UserInfo of the user that did the import.
+	User    *datastore.Key
+	Created time.Time
+
+	Added   []string `datastore:",noindex"`
+	Skipped []string `datastore:",noindex"`
+
+	// Failed holds the URLs that could not be subscribed to, and
+	// Errors holds the corresponding error messages.
+	Failed []string `datastore:",noindex"`
+	Errors []string `datastore:",noindex"`
+}
+
+func (r *ImportReport) add(url string) {
+	r.Added = append(r.Added, url)
+}
+
+func (r *ImportReport) skip(url string) {
+	r.Skipped = append(r.Skipped, url)
+}
+
+func (r *ImportReport) fail(url string, err error) {
+	r.Failed = append(r.Failed, url)
+	r.Errors = append(r.Errors, err.Error())
+}
+
+// Empty returns true if nothing was recorded in the report.
+func (r *ImportReport) empty() bool {
+	return len(r.Added) == 0 && len(r.Skipped) == 0 && len(r.Failed) == 0
+}
+
+// SaveImportReport stores a report for the current user and returns the
+// URL at which it can be viewed. Expired reports are deleted.
+func saveImportReport(c appengine.Context, r *ImportReport) (string, error) {
+	r.User = userInfoKey(c)
+	r.Created = time.Now()
+	key, err := datastore.Put(c, datastore.NewIncompleteKey(c, reportKind, nil), r)
+	if err != nil {
+		return "", err
+	}
+
+	q := datastore.NewQuery(reportKind).Filter("Created <", time.Now().Add(-reportLifetime)).KeysOnly()
+	if old, err := q.GetAll(c, nil); err != nil {
+		c.Errorf("failed to query expired import reports: %s", err)
+	} else if err := datastore.DeleteMulti(c, old); err != nil {
+		c.Errorf("failed to delete expired import reports: %s", err)
+	}
+
+	return "/import?id=" + strconv.FormatInt(key.IntID(), 10), nil
+}
+
+type reportFailure struct {
+	Url   string
+	Error string
+}
+
+func handleImportReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	c := appengine.NewContext(r)
+	var rep ImportReport
+	if err := datastore.Get(c, datastore.NewKey(c, reportKind, "", id, nil), &rep); err == datastore.ErrNoSuchEntity {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !rep.User.Equal(userInfoKey(c)) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var page struct {
+		Title   string
+		Logout  string
+		Added   []string
+		Skipped []string
+		Failed  []reportFailure
+	}
+	page.Title = "Import Report"
+	page.Added = rep.Added
+	page.Skipped = rep.Skipped
+	for i, url := range rep.Failed {
+		f := reportFailure{Url: url}
+		if i < len(rep.Errors) {
+			f.Error = rep.Errors[i]
+		}
+		page.Failed = append(page.Failed, f)
+	}
+
+	page.Logout, err = user.LogoutURL(c, "/")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	executeTemplate(w, c, "import.html", page)
+}
@@ -0,0 +1,107 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"appengine/user"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	http.HandleFunc("/settings", handleSettings)
+}
+
+// HandleSettings shows the current user's preferences. A POST sets all
+// of them at once from the form values.
+func handleSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		methodNotAllowed(w, "GET", "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if r.Method == "POST" {
+		if !verifyCSRF(w, r, c) {
+			return
+		}
+		r.ParseForm()
+		p, err := parsePrefs(r.PostForm)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := setPrefs(c, p); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		invalidateLatest(c)
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+
+	var page struct {
+		Title  string
+		Logout string
+		User   UserInfo
+	}
+	page.Title = "Settings"
+
+	var err error
+	page.User, err = getUserInfo(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page.Logout, err = user.LogoutURL(c, "/")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	executeTemplate(w, c, "settings.html", page)
+}
+
+// ParsePrefs returns the preferences given by the values of the settings
+// form. Unchecked checkboxes are missing from the form, so a missing
+// checkbox value is false.
+func parsePrefs(form url.Values) (UserPrefs, error) {
+	d, err := parseLatestHours(form.Get("hours"))
+	if err != nil {
+		return UserPrefs{}, err
+	}
+	order, err := parseSortOrder(form.Get("sort"))
+	if err != nil {
+		return UserPrefs{}, err
+	}
+	return UserPrefs{
+		DefaultCategory:    strings.TrimSpace(form.Get("category")),
+		NoDiscover:         form.Get("nodiscover") != "",
+		MutedKeywords:      parseKeywords(form.Get("keywords")),
+		CollapseDuplicates: form.Get("collapse") != "",
+		LatestDuration:     d,
+		HideRead:           form.Get("hideread") != "",
+		SortOrder:          order,
+	}, nil
+}
+
+// SetPrefs replaces the current user's preferences with p. If NoDiscover
+// changed, a task to recount the feeds' subscribers is added in the same
+// transaction.
+func setPrefs(c appengine.Context, p UserPrefs) error {
+	return datastore.RunInTransaction(c, func(c appengine.Context) error {
+		u, err := getUserInfo(c)
+		if err != nil {
+			return err
+		}
+		old := u.UserPrefs
+		u.UserPrefs = p
+		if _, err := datastore.Put(c, userInfoKey(c), &u); err != nil {
+			return err
+		}
+		if old.NoDiscover == p.NoDiscover {
+			return nil
+		}
+		return addRecountTask(c)
+	}, nil)
+}
@@ -0,0 +1,175 @@
+package feedme
+
+import (
+	"bytes"
+	"code.google.com/p/go.net/html"
+	"io"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUserPrefsDefaults(t *testing.T) {
+	p := (UserPrefs{}).withDefaults()
+	if p.LatestDuration != latestDuration {
+		t.Errorf("Expected the default latest duration %s, got %s", latestDuration, p.LatestDuration)
+	}
+	if p.SortOrder != newestFirst {
+		t.Errorf("Expected the default sort order %s, got %s", newestFirst, p.SortOrder)
+	}
+	set := UserPrefs{DefaultCategory: "news", LatestDuration: 3 * time.Hour, CollapseDuplicates: true, SortOrder: byFeed}
+	if p := set.withDefaults(); !reflect.DeepEqual(p, set) {
+		t.Errorf("Expected set preferences %+v to be kept, got %+v", set, p)
+	}
+}
+
+func TestParsePrefs(t *testing.T) {
+	tests := []struct {
+		name string
+		form url.Values
+		want UserPrefs
+		err  bool
+	}{
+		{
+			name: "defaults",
+			form: url.Values{"hours": {"18"}},
+			want: UserPrefs{LatestDuration: 18 * time.Hour},
+		},
+		{
+			name: "all",
+			form: url.Values{
+				"category":   {" news "},
+				"hours":      {"48"},
+				"collapse":   {"on"},
+				"nodiscover": {"on"},
+				"keywords":   {"spoiler\n\nGame of Thrones\nSPOILER\n"},
+				"sort":       {"oldest"},
+			},
+			want: UserPrefs{
+				DefaultCategory:    "news",
+				NoDiscover:         true,
+				MutedKeywords:      []string{"spoiler", "Game of Thrones"},
+				CollapseDuplicates: true,
+				LatestDuration:     48 * time.Hour,
+				SortOrder:          oldestFirst,
+			},
+		},
+		{name: "no hours", form: url.Values{}, err: true},
+		{name: "too many hours", form: url.Values{"hours": {"1000"}}, err: true},
+		{name: "bad sort", form: url.Values{"hours": {"18"}, "sort": {"random"}}, err: true},
+	}
+	for _, test := range tests {
+		p, err := parsePrefs(test.form)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %+v", test.name, p)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(p, test.want) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.want, p)
+		}
+	}
+}
+
+func TestPrefsRoundTrip(t *testing.T) {
+	var files []string
+	for _, f := range templateFiles {
+		files = append(files, "../"+f)
+	}
+	tmpl, err := parseTemplates(files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	tmpl, err = withFuncs(tmpl, csrfFuncs("tok"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	prefs := []UserPrefs{
+		UserPrefs{}.withDefaults(),
+		{
+			DefaultCategory:    "news & views",
+			NoDiscover:         true,
+			MutedKeywords:      []string{"spoiler", "<b>ad</b>"},
+			CollapseDuplicates: true,
+			LatestDuration:     6 * time.Hour,
+			HideRead:           true,
+			SortOrder:          byFeed,
+		},
+	}
+	for _, p := range prefs {
+		var page struct {
+			Title  string
+			Logout string
+			User   UserInfo
+		}
+		page.Title = "Settings"
+		page.User.UserPrefs = p
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, "settings.html", page); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		form, err := formValues(&buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if form.Get("csrf") != "tok" {
+			t.Errorf("Expected the form to have the CSRF token, got %v", form)
+		}
+		got, err := parsePrefs(form)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %v: %s", form, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, p) {
+			t.Errorf("Expected %+v, got %+v", p, got)
+		}
+	}
+}
+
+// FormValues returns the values that a browser would submit for the
+// inputs and textareas of an HTML document.
+func formValues(r io.Reader) (url.Values, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			attrs := make(map[string]string)
+			for _, a := range n.Attr {
+				attrs[a.Key] = a.Val
+			}
+			_, checked := attrs["checked"]
+			switch {
+			case n.Data == "input" && attrs["type"] == "checkbox":
+				if checked {
+					form.Add(attrs["name"], "on")
+				}
+			case n.Data == "input" && attrs["type"] == "radio":
+				if checked {
+					form.Add(attrs["name"], attrs["value"])
+				}
+			case n.Data == "input" && attrs["type"] != "submit":
+				form.Add(attrs["name"], attrs["value"])
+			case n.Data == "textarea" && n.FirstChild != nil:
+				form.Add(attrs["name"], n.FirstChild.Data)
+			case n.Data == "textarea":
+				form.Add(attrs["name"], "")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return form, nil
+}
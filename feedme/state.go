@@ -0,0 +1,399 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	articleStateKind = "ArticleState"
+	readCountKind    = "ReadCount"
+)
+
+func init() {
+	http.HandleFunc("/markRead", handleMarkRead)
+	http.HandleFunc("/markAllRead", handleMarkAllRead)
+}
+
+// An ArticleState records a user's state for an article. ArticleStates
+// are children of the user's UserInfo, keyed by the hash of the article's
+// key. An article without an ArticleState is unread.
+type ArticleState struct {
+	// Article is the key of the article.
+	Article *datastore.Key
+	// Read is the time at which the user read the article.
+	Read time.Time `datastore:",noindex"`
+}
+
+// ArticleStateKey returns the key of the given user's ArticleState for
+// an article.
+func articleStateKey(c appengine.Context, user, article *datastore.Key) *datastore.Key {
+	return datastore.NewKey(c, articleStateKind, articleStateID(article), 0, user)
+}
+
+// ArticleStateID returns the string ID of an ArticleState for an article.
+// Article keys can be longer than the datastore allows for a key name,
+// so their hash is used instead.
+func articleStateID(article *datastore.Key) string {
+	h := sha256.Sum256([]byte(article.String()))
+	return hex.EncodeToString(h[:])
+}
+
+// SetRead sets the Read field of each article to whether the given user
+// has read it.
+func setRead(c appengine.Context, user *datastore.Key, articles Articles) error {
+	return batches(len(articles), func(i, j int) error {
+		keys := make([]*datastore.Key, j-i)
+		for k := range keys {
+			keys[k] = articleStateKey(c, user, articles[i+k].Key)
+		}
+		read, err := readFlags(len(keys), datastore.GetMulti(c, keys, make([]ArticleState, len(keys))))
+		if err != nil {
+			return err
+		}
+		for k, r := range read {
+			articles[i+k].Read = r
+		}
+		return nil
+	})
+}
+
+// ReadFlags returns whether each of n ArticleStates exists, given the
+// error returned by the datastore.GetMulti call that loaded them.
+func readFlags(n int, err error) ([]bool, error) {
+	read := make([]bool, n)
+	if err == nil {
+		for i := range read {
+			read[i] = true
+		}
+		return read, nil
+	}
+	me, ok := err.(appengine.MultiError)
+	if !ok {
+		return nil, err
+	}
+	for i, err := range me {
+		switch err {
+		case nil:
+			read[i] = true
+		case datastore.ErrNoSuchEntity:
+		default:
+			return nil, err
+		}
+	}
+	return read, nil
+}
+
+// MarkRead records that the given user read the articles at time t, and
+// adds the articles that were not already read to the user's ReadCounts.
+// The ArticleStates and ReadCounts are all in the user's entity group, so
+// each batch is checked and counted in a single transaction, and
+// concurrent marks of the same article count it only once.
+func markRead(c appengine.Context, user *datastore.Key, articles []*datastore.Key, t time.Time) error {
+	return batches(len(articles), func(i, j int) error {
+		return datastore.RunInTransaction(c, func(c appengine.Context) error {
+			keys := make([]*datastore.Key, j-i)
+			states := make([]ArticleState, j-i)
+			for k, a := range articles[i:j] {
+				keys[k] = articleStateKey(c, user, a)
+				states[k] = ArticleState{Article: a, Read: t}
+			}
+			read, err := readFlags(len(keys), datastore.GetMulti(c, keys, make([]ArticleState, len(keys))))
+			if err != nil {
+				return err
+			}
+			if _, err := datastore.PutMulti(c, keys, states); err != nil {
+				return err
+			}
+			newlyRead := make(map[string]int)
+			feeds := make(map[string]*datastore.Key)
+			for k, a := range articles[i:j] {
+				if !read[k] && !containsKey(articles[i:i+k], a) {
+					id := a.Parent().Encode()
+					newlyRead[id]++
+					feeds[id] = a.Parent()
+				}
+			}
+			for id, n := range newlyRead {
+				if err := bumpReadCount(c, user, feeds[id], n); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil)
+	})
+}
+
+// ContainsKey returns whether k is in keys.
+func containsKey(keys []*datastore.Key, k *datastore.Key) bool {
+	for _, l := range keys {
+		if l.Equal(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// A ReadCount is the number of a feed's stored articles that a user has
+// read. ReadCounts are children of the user's UserInfo, keyed by the hash
+// of the feed's key. Together with the feed's ArticleCount, it gives the
+// number of unread articles without counting them.
+type ReadCount struct {
+	Feed  *datastore.Key
+	Count int `datastore:",noindex"`
+}
+
+// ReadCountKey returns the key of the given user's ReadCount for a feed.
+func readCountKey(c appengine.Context, user, feed *datastore.Key) *datastore.Key {
+	return datastore.NewKey(c, readCountKind, articleStateID(feed), 0, user)
+}
+
+// BumpReadCount adds n to the given user's ReadCount for a feed. A missing
+// ReadCount is left missing; it is initialized by cachedUnreadCount,
+// which counts every read article, including these. It must be called in
+// a transaction on the user's entity group.
+func bumpReadCount(c appengine.Context, user, feed *datastore.Key, n int) error {
+	key := readCountKey(c, user, feed)
+	var rc ReadCount
+	switch err := datastore.Get(c, key, &rc); {
+	case err == datastore.ErrNoSuchEntity:
+		return nil
+	case err != nil:
+		return err
+	}
+	rc.Count += n
+	if rc.Count < 0 {
+		rc.Count = 0
+	}
+	_, err := datastore.Put(c, key, &rc)
+	return err
+}
+
+// CachedUnreadCount returns the number of the feed's articles that the
+// given user has not read, from the feed's ArticleCount and the user's
+// ReadCount. If the user has no ReadCount for the feed, the unread
+// articles are counted and the ReadCount is created.
+func cachedUnreadCount(c appengine.Context, user *datastore.Key, f FeedInfo) (int, error) {
+	feed := datastore.NewKey(c, feedKind, f.Url, 0, nil)
+	key := readCountKey(c, user, feed)
+	var rc ReadCount
+	err := datastore.Get(c, key, &rc)
+	if err == nil {
+		return unread(f.ArticleCount, rc.Count), nil
+	} else if err != datastore.ErrNoSuchEntity {
+		return 0, err
+	}
+
+	n, total, err := countUnread(c, user, feed)
+	if err != nil {
+		return 0, err
+	}
+	if total == f.ArticleCount {
+		rc = ReadCount{Feed: feed, Count: total - n}
+		if _, err := datastore.Put(c, key, &rc); err != nil {
+			c.Errorf("%s: failed to store the read count: %s", f.Url, err)
+		}
+	}
+	return n, nil
+}
+
+// Unread returns the number of unread articles of a feed with the given
+// number of articles, of which read have been read.
+func unread(articles, read int) int {
+	if read > articles {
+		return 0
+	}
+	return articles - read
+}
+
+// ArticleCount returns the number of a feed's articles after adding
+// added articles to its stored articles and deleting deleted of them.
+func articleCount(stored, added, deleted int) int {
+	return stored + added - deleted
+}
+
+// SetArticleCount sets the ArticleCount of a feed.
+func setArticleCount(c appengine.Context, feed *datastore.Key, n int) error {
+	return datastore.RunInTransaction(c, func(c appengine.Context) error {
+		var f FeedInfo
+		if err := datastore.Get(c, feed, &f); err != nil {
+			return err
+		}
+		if f.ArticleCount == n {
+			return nil
+		}
+		f.ArticleCount = n
+		_, err := datastore.Put(c, feed, &f)
+		return err
+	}, nil)
+}
+
+// DeleteArticles deletes articles along with every user's ArticleStates
+// for them, removing the deleted read articles from the users'
+// ReadCounts. Each ArticleState is deleted in the same transaction that
+// updates its ReadCount, so that it is uncounted only once.
+func deleteArticles(c appengine.Context, articles []*datastore.Key) error {
+	for _, a := range articles {
+		states, err := datastore.NewQuery(articleStateKind).Filter("Article =", a).KeysOnly().GetAll(c, nil)
+		if err != nil {
+			return err
+		}
+		for _, s := range states {
+			if err := deleteArticleState(c, s, a.Parent()); err != nil {
+				return err
+			}
+		}
+	}
+	return batches(len(articles), func(i, j int) error {
+		return datastore.DeleteMulti(c, articles[i:j])
+	})
+}
+
+// DeleteArticleState deletes an ArticleState of an article of the given
+// feed, and removes the article from its user's ReadCount for the feed,
+// if the ArticleState has not already been deleted.
+func deleteArticleState(c appengine.Context, state, feed *datastore.Key) error {
+	return datastore.RunInTransaction(c, func(c appengine.Context) error {
+		switch err := datastore.Get(c, state, &ArticleState{}); {
+		case err == datastore.ErrNoSuchEntity:
+			return nil
+		case err != nil:
+			return err
+		}
+		if err := datastore.Delete(c, state); err != nil {
+			return err
+		}
+		return bumpReadCount(c, state.Parent(), feed, -1)
+	}, nil)
+}
+
+// CountUnread returns the number of the feed's articles that the given
+// user has not read, and the number of its articles, by reading the
+// user's ArticleState for each article.
+func countUnread(c appengine.Context, user, feed *datastore.Key) (int, int, error) {
+	keys, err := datastore.NewQuery(articleKind).Ancestor(feed).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	articles := make(Articles, len(keys))
+	for i, k := range keys {
+		articles[i].Key = k
+	}
+	if err := setRead(c, user, articles); err != nil {
+		return 0, 0, err
+	}
+	n := 0
+	for _, a := range articles {
+		if !a.Read {
+			n++
+		}
+	}
+	return n, len(articles), nil
+}
+
+// HandleMarkRead marks the article whose encoded key is given by the
+// article form value as read by the current user.
+func handleMarkRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if !verifyCSRF(w, r, c) {
+		return
+	}
+	key, err := datastore.DecodeKey(r.FormValue("article"))
+	if err != nil || key.Kind() != articleKind {
+		http.Error(w, "bad article key", http.StatusBadRequest)
+		return
+	}
+	if err := markRead(c, userInfoKey(c), []*datastore.Key{key}, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	redirectBack(w, r)
+}
+
+// HandleMarkAllRead marks articles as read by the current user. If the
+// feed form value is the encoded key of one of the user's feeds, all of
+// its articles are marked. Otherwise the articles of the view given by
+// the view form value, "latest" or "all", are marked.
+func handleMarkAllRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if !verifyCSRF(w, r, c) {
+		return
+	}
+	uinfo, err := getUserInfo(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var keys []*datastore.Key
+	if enc := r.FormValue("feed"); enc != "" {
+		feed, err := datastore.DecodeKey(enc)
+		if err != nil || !uinfo.subscribed(feed) {
+			http.Error(w, "bad feed key", http.StatusBadRequest)
+			return
+		}
+		keys, err = datastore.NewQuery(articleKind).Ancestor(feed).KeysOnly().GetAll(c, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		articles, errs := articlesSince(c, uinfo, viewSince(r.FormValue("view"), time.Now(), uinfo.latest()))
+		for _, err := range errs {
+			c.Errorf("%s", err)
+		}
+		keys = articleKeys(articles)
+	}
+
+	if err := markRead(c, userInfoKey(c), keys, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	redirectBack(w, r)
+}
+
+// ViewSince returns the time since which articles are shown in the named
+// view, at time now, for a user whose latest view goes back the given
+// duration. The zero time is returned for the "all" view.
+func viewSince(view string, now time.Time, latest time.Duration) time.Time {
+	if view == "all" {
+		return time.Time{}
+	}
+	return now.Add(-latest)
+}
+
+// ArticleKeys returns the keys of the articles.
+func articleKeys(articles Articles) []*datastore.Key {
+	keys := make([]*datastore.Key, len(articles))
+	for i, a := range articles {
+		keys[i] = a.Key
+	}
+	return keys
+}
+
+// RedirectBack redirects to the referring page, or to the latest
+// articles if there is no referrer. Only the path of the referrer is
+// used, so the redirect never leaves the site.
+func redirectBack(w http.ResponseWriter, r *http.Request) {
+	to := "/"
+	if u, err := url.Parse(r.Referer()); err == nil && strings.HasPrefix(u.Path, "/") && !strings.HasPrefix(u.Path, "//") {
+		to = u.RequestURI()
+	}
+	http.Redirect(w, r, to, http.StatusFound)
+}
@@ -0,0 +1,116 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadFlags(t *testing.T) {
+	other := errors.New("datastore unavailable")
+	tests := []struct {
+		name string
+		n    int
+		err  error
+		read []bool
+		fail bool
+	}{
+		{name: "all read", n: 2, read: []bool{true, true}},
+		{
+			name: "some unread",
+			n:    3,
+			err:  appengine.MultiError{nil, datastore.ErrNoSuchEntity, nil},
+			read: []bool{true, false, true},
+		},
+		{name: "failed", n: 2, err: other, fail: true},
+		{name: "one failed", n: 2, err: appengine.MultiError{datastore.ErrNoSuchEntity, other}, fail: true},
+	}
+	for _, test := range tests {
+		read, err := readFlags(test.n, test.err)
+		if (err != nil) != test.fail {
+			t.Errorf("%s: expected failure %t, got error %v", test.name, test.fail, err)
+			continue
+		}
+		if !test.fail && !reflect.DeepEqual(read, test.read) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.read, read)
+		}
+	}
+}
+
+func TestRedirectBack(t *testing.T) {
+	tests := []struct {
+		referer, location string
+	}{
+		{"", "/"},
+		{"http://feedme.example.com/all", "/all"},
+		{"http://feedme.example.com/new?x=1", "/new?x=1"},
+		{"http://evil.example.com//evil.example.com/", "/"},
+		{"not a url%", "/"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("POST", "/markRead", nil)
+		if test.referer != "" {
+			r.Header.Set("Referer", test.referer)
+		}
+		w := httptest.NewRecorder()
+		redirectBack(w, r)
+		if loc := w.Header().Get("Location"); loc != test.location {
+			t.Errorf("Referer [%s]: expected a redirect to [%s], got [%s]", test.referer, test.location, loc)
+		}
+	}
+}
+
+func TestViewSince(t *testing.T) {
+	now := time.Date(2014, 3, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		view   string
+		latest time.Duration
+		since  time.Time
+	}{
+		{"", UserInfo{}.latest(), now.Add(-latestDuration)},
+		{"latest", UserInfo{}.latest(), now.Add(-latestDuration)},
+		{"latest", UserInfo{UserPrefs: UserPrefs{LatestDuration: 3 * time.Hour}}.latest(), now.Add(-3 * time.Hour)},
+		{"all", UserInfo{UserPrefs: UserPrefs{LatestDuration: 3 * time.Hour}}.latest(), time.Time{}},
+	}
+	for _, test := range tests {
+		if since := viewSince(test.view, now, test.latest); !since.Equal(test.since) {
+			t.Errorf("View [%s]: expected %s, got %s", test.view, test.since, since)
+		}
+	}
+}
+
+func TestUnreadCounters(t *testing.T) {
+	// A feed's ArticleCount grows as articles are stored and its
+	// subscriber's ReadCount grows as the articles are marked read.
+	articles, read := 0, 0
+
+	articles = articleCount(articles, 3, 0)
+	if n := unread(articles, read); n != 3 {
+		t.Errorf("Expected 3 unread articles after storing 3, got %d", n)
+	}
+	articles = articleCount(articles, 2, 0)
+	if n := unread(articles, read); n != 5 {
+		t.Errorf("Expected 5 unread articles after storing 2 more, got %d", n)
+	}
+
+	read += 2
+	if n := unread(articles, read); n != 3 {
+		t.Errorf("Expected 3 unread articles after marking 2 read, got %d", n)
+	}
+
+	// Deleting a read and an unread article.
+	articles = articleCount(articles, 0, 2)
+	read--
+	if n := unread(articles, read); n != 2 {
+		t.Errorf("Expected 2 unread articles after deleting 2, got %d", n)
+	}
+
+	if n := unread(1, 4); n != 0 {
+		t.Errorf("Expected no unread articles when more are read than stored, got %d", n)
+	}
+}
@@ -0,0 +1,96 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"appengine/urlfetch"
+	"appengine/user"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	http.HandleFunc("/subscribe", handleQuickSubscribe)
+}
+
+// HandleQuickSubscribe subscribes the current user to the feed at the URL
+// given by the url form value, or, if the URL is a web page, to the first
+// feed that it links to. A GET, such as from the bookmarklet, only asks
+// the user to confirm. The confirmation is a POST with the user's CSRF
+// token, so other sites cannot subscribe the user.
+func handleQuickSubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		methodNotAllowed(w, "GET", "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	if r.Method == "POST" && !verifyCSRF(w, r, c) {
+		return
+	}
+
+	var page struct {
+		Title  string
+		Logout string
+		// Url is the URL that the user asked to subscribe to.
+		Url  string
+		Feed FeedInfo
+		// Subscribed is true if the user is already subscribed to
+		// the feed.
+		Subscribed bool
+		Error      string
+	}
+	page.Title = "Subscribe"
+	page.Url = strings.TrimSpace(r.FormValue("url"))
+
+	var err error
+	page.Logout, err = user.LogoutURL(c, "/")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page.Feed, err = quickSubscribeFeed(c, urlfetch.Client(c), page.Url)
+	if err != nil {
+		page.Error = err.Error()
+		executeTemplate(w, c, "subscribe.html", page)
+		return
+	}
+
+	if r.Method == "POST" {
+		if err := subscribe(c, page.Feed, ""); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		invalidateLatest(c)
+		http.Redirect(w, r, "/list?subscribed="+url.QueryEscape(page.Feed.Url), http.StatusFound)
+		return
+	}
+
+	uinfo, err := getUserInfo(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page.Subscribed = uinfo.subscribed(datastore.NewKey(c, feedKind, page.Feed.Url, 0, nil))
+	executeTemplate(w, c, "subscribe.html", page)
+}
+
+// QuickSubscribeFeed returns the FeedInfo of the feed to subscribe to for
+// a URL, fetched using the given http.Client. If the URL is a web page,
+// the first feed that it links to is used.
+func quickSubscribeFeed(c appengine.Context, client *http.Client, url string) (FeedInfo, error) {
+	if err := validateFeedURL(url); err != nil {
+		return FeedInfo{}, err
+	}
+	return checkUrlWith(c, client, url)
+}
+
+// Bookmarklet returns a javascript: URL that opens the quick subscribe
+// page of the site at host for the page being viewed.
+func bookmarklet(host string) template.URL {
+	return template.URL("javascript:location.href='https://" + template.JSEscapeString(host) +
+		"/subscribe?url='+encodeURIComponent(location.href)")
+}
@@ -0,0 +1,80 @@
+package feedme
+
+import (
+	"appengine"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A logContext is an appengine.Context that discards its log messages.
+// Its other methods are not implemented.
+type logContext struct {
+	appengine.Context
+}
+
+func (logContext) Debugf(format string, args ...interface{})    {}
+func (logContext) Infof(format string, args ...interface{})     {}
+func (logContext) Warningf(format string, args ...interface{})  {}
+func (logContext) Errorf(format string, args ...interface{})    {}
+func (logContext) Criticalf(format string, args ...interface{}) {}
+
+func TestQuickSubscribeFeed(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<rss version="2.0"><channel><title>Feed</title><link>http://example.com/</link>
+			<item><title>1</title></item></channel></rss>`))
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head>
+			<link rel="alternate" type="application/rss+xml" href="/feed">
+			</head><body></body></html>`))
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body>No feeds here.</body></html>`))
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	tests := []struct {
+		name  string
+		url   string
+		feed  string
+		title string
+		err   bool
+	}{
+		{name: "feed", url: s.URL + "/feed", feed: s.URL + "/feed", title: "Feed"},
+		{name: "autodiscovery", url: s.URL + "/page", feed: s.URL + "/feed", title: "Feed"},
+		{name: "no feed", url: s.URL + "/plain", err: true},
+		{name: "not a URL", url: "example.com/feed", err: true},
+		{name: "empty", url: "", err: true},
+	}
+	for _, test := range tests {
+		f, err := quickSubscribeFeed(logContext{}, s.Client(), test.url)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %+v", test.name, f)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if f.Url != test.feed || f.Title != test.title {
+			t.Errorf("%s: expected feed %s titled [%s], got %s titled [%s]", test.name, test.feed, test.title, f.Url, f.Title)
+		}
+	}
+}
+
+func TestBookmarklet(t *testing.T) {
+	b := string(bookmarklet("feed--me.appspot.com"))
+	want := "javascript:location.href='https://feed--me.appspot.com/subscribe?url='+encodeURIComponent(location.href)"
+	if b != want {
+		t.Errorf("Expected [%s], got [%s]", want, b)
+	}
+	if b := string(bookmarklet("evil.com'+alert(1)+'")); strings.Contains(b, "com'+alert") {
+		t.Errorf("Expected the host to be escaped, got [%s]", b)
+	}
+}
@@ -0,0 +1,136 @@
+package feedme
+
+import (
+	"appengine"
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+)
+
+var (
+	templateFiles = []string{
+		"tmplt/navbar.html",
+		"tmplt/feed.html",
+		"tmplt/manage.html",
+		"tmplt/article.html",
+		"tmplt/articles.html",
+		"tmplt/articlepage.html",
+		"tmplt/discover.html",
+		"tmplt/tokens.html",
+		"tmplt/import.html",
+		"tmplt/subscribe.html",
+		"tmplt/settings.html",
+	}
+
+	funcs = template.FuncMap{
+		"dateTime": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+		"stringEq": func(a, b string) bool { return a == b },
+		// CsrfField is replaced by csrfFuncs when a template is
+		// executed.
+		"csrfField": func() template.HTML { return "" },
+	}
+
+	// Templates holds every template that parsed successfully.
+	// TemplatesErr records the templates that failed to parse, if any.
+	templates, templatesErr = parseTemplates(templateFiles)
+
+	// ErrorPage is a built-in page served when a template is unavailable.
+	errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="Content-Type" content="text/html;charset=utf-8" >
+<title>Feed Me!</title>
+</head>
+<body>
+<h1>Something went wrong</h1>
+<p>The {{.Name}} page could not be displayed.</p>
+<pre>{{.Error}}</pre>
+</body>
+</html>
+`))
+)
+
+func init() {
+	if templatesErr != nil {
+		log.Printf("feedme: %s", templatesErr)
+	}
+}
+
+// ParseTemplates parses each template file separately, so that a single
+// missing or malformed file doesn't prevent the others from being used.
+// The returned error lists every file that failed to parse.
+func parseTemplates(files []string) (*template.Template, error) {
+	t := template.New("t").Funcs(funcs)
+	var errs errorList
+	for _, file := range files {
+		// Parse into a clone so that a failure doesn't leave a
+		// partially-defined template in t.
+		c, err := t.Clone()
+		if err == nil {
+			_, err = c.ParseFiles(file)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse template %s: %s", file, err))
+			continue
+		}
+		t = c
+	}
+	if len(errs) > 0 {
+		return t, errs
+	}
+	return t, nil
+}
+
+// ExecuteTemplate renders the named template to w, with the current
+// user's CSRF token in its forms. If the template is unavailable or fails
+// to execute, the built-in error page is served instead.
+func executeTemplate(w http.ResponseWriter, c appengine.Context, name string, data interface{}) {
+	if templates.Lookup(name) == nil {
+		err := templatesErr
+		if err == nil {
+			err = fmt.Errorf("no template named %s", name)
+		}
+		serveErrorPage(w, name, err)
+		return
+	}
+
+	tok, err := csrfToken(c)
+	if err != nil {
+		serveErrorPage(w, name, err)
+		return
+	}
+	t, err := withFuncs(templates, csrfFuncs(tok))
+	if err != nil {
+		serveErrorPage(w, name, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		serveErrorPage(w, name, err)
+		return
+	}
+	buf.WriteTo(w)
+}
+
+// WithFuncs returns a copy of t with its functions replaced by funcs. The
+// copy is executed instead of t, because a template cannot be copied once
+// it has been executed.
+func withFuncs(t *template.Template, funcs template.FuncMap) (*template.Template, error) {
+	c, err := t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return c.Funcs(funcs), nil
+}
+
+func serveErrorPage(w http.ResponseWriter, name string, err error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	errorPage.Execute(w, struct {
+		Name  string
+		Error error
+	}{name, err})
+}
@@ -0,0 +1,97 @@
+package feedme
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseTemplatesMissingFile(t *testing.T) {
+	files := []string{
+		"../tmplt/navbar.html",
+		"../tmplt/missing.html",
+		"../tmplt/feed.html",
+	}
+	tmpl, err := parseTemplates(files)
+	if err == nil {
+		t.Fatalf("Expected an error parsing %v", files)
+	}
+	if !strings.Contains(err.Error(), "missing.html") {
+		t.Errorf("Expected the error to name missing.html, got [%s]", err)
+	}
+	for _, name := range []string{"navbar.html", "feed.html"} {
+		if tmpl.Lookup(name) == nil {
+			t.Errorf("Expected %s to be parsed despite the error", name)
+		}
+	}
+}
+
+func TestParseTemplates(t *testing.T) {
+	var files []string
+	for _, f := range templateFiles {
+		files = append(files, "../"+f)
+	}
+	tmpl, err := parseTemplates(files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, f := range templateFiles {
+		name := f[strings.LastIndex(f, "/")+1:]
+		if tmpl.Lookup(name) == nil {
+			t.Errorf("Expected template %s", name)
+		}
+	}
+}
+
+// TestExecutedTemplatesParsed checks that every template named in a call
+// to executeTemplate is among the parsed templateFiles.
+func TestExecutedTemplatesParsed(t *testing.T) {
+	var files []string
+	for _, f := range templateFiles {
+		files = append(files, "../"+f)
+	}
+	tmpl, err := parseTemplates(files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	sources, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	call := regexp.MustCompile(`executeTemplate\(w, c, "([^"]+)"`)
+	n := 0
+	for _, src := range sources {
+		if strings.HasSuffix(src, "_test.go") {
+			continue
+		}
+		b, err := ioutil.ReadFile(src)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		for _, m := range call.FindAllSubmatch(b, -1) {
+			n++
+			if name := string(m[1]); tmpl.Lookup(name) == nil {
+				t.Errorf("Expected template %s, executed in %s, to be parsed", name, src)
+			}
+		}
+	}
+	if n == 0 {
+		t.Errorf("Expected calls to executeTemplate")
+	}
+}
+
+func TestServeErrorPage(t *testing.T) {
+	w := httptest.NewRecorder()
+	serveErrorPage(w, "manage.html", errorList{})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "manage.html") {
+		t.Errorf("Expected the error page to name the template, got [%s]", w.Body.String())
+	}
+}
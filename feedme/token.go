@@ -0,0 +1,250 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"appengine/user"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// TokenBytes is the number of random bytes in an API token.
+	tokenBytes = 20
+
+	tokenKind = "APIToken"
+)
+
+// ErrBadToken is returned when an API token is unknown or revoked.
+var errBadToken = errors.New("invalid API token")
+
+func init() {
+	http.HandleFunc("/settings/tokens", handleTokens)
+}
+
+// An APIToken allows a non-browser client to act on behalf of a user.
+// APITokens are keyed by the hash of the token; the token itself is
+// never stored.
+type APIToken struct {
+	// User is the key of the UserInfo of the token's owner.
+	User    *datastore.Key
+	Name    string    `datastore:",noindex"`
+	Created time.Time `datastore:",noindex"`
+}
+
+// NewToken returns a new random API token.
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashToken returns the hash under which a token is stored.
+func hashToken(tok string) string {
+	h := sha256.Sum256([]byte(tok))
+	return hex.EncodeToString(h[:])
+}
+
+// CreateToken creates and stores a new API token for the current user
+// and returns it.
+func createToken(c appengine.Context, name string) (string, error) {
+	tok, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	key := datastore.NewKey(c, tokenKind, hashToken(tok), 0, nil)
+	t := APIToken{User: userInfoKey(c), Name: name, Created: time.Now()}
+	if _, err := datastore.Put(c, key, &t); err != nil {
+		return "", err
+	}
+	return tok, nil
+}
+
+// RevokeToken deletes the current user's API token with the given hash.
+func revokeToken(c appengine.Context, hash string) error {
+	key := datastore.NewKey(c, tokenKind, hash, 0, nil)
+	return datastore.RunInTransaction(c, func(c appengine.Context) error {
+		var t APIToken
+		if err := datastore.Get(c, key, &t); err != nil {
+			return err
+		}
+		if !t.User.Equal(userInfoKey(c)) {
+			return datastore.ErrNoSuchEntity
+		}
+		return datastore.Delete(c, key)
+	}, nil)
+}
+
+// A tokenStore holds APITokens by the hashes of the tokens. Get returns
+// datastore.ErrNoSuchEntity if there is no such token, as when it has
+// been revoked.
+type tokenStore interface {
+	get(hash string) (APIToken, error)
+}
+
+// A datastoreTokens is a tokenStore using the datastore.
+type datastoreTokens struct {
+	c appengine.Context
+}
+
+func (d datastoreTokens) get(hash string) (APIToken, error) {
+	var t APIToken
+	err := datastore.Get(d.c, datastore.NewKey(d.c, tokenKind, hash, 0, nil), &t)
+	return t, err
+}
+
+// TokenUser returns the key of the UserInfo of the owner of an API token.
+func tokenUser(tokens tokenStore, tok string) (*datastore.Key, error) {
+	t, err := tokens.get(hashToken(tok))
+	switch {
+	case err == datastore.ErrNoSuchEntity:
+		return nil, errBadToken
+	case err != nil:
+		return nil, err
+	}
+	return t.User, nil
+}
+
+// BearerToken returns the token from a request's
+// "Authorization: Bearer <token>" header, if it has one.
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) < len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+		return "", false
+	}
+	tok := strings.TrimSpace(h[len(prefix):])
+	return tok, tok != ""
+}
+
+// ApiHandler returns an http.HandlerFunc that authenticates a request
+// either by its bearer token or by the logged in user and then calls h
+// with the key of the user's UserInfo.
+func apiHandler(h func(http.ResponseWriter, *http.Request, appengine.Context, *datastore.Key)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := appengine.NewContext(r)
+		authorizeAPI(w, r, c, datastoreTokens{c}, h)
+	}
+}
+
+// AuthorizeAPI calls h with the key of the UserInfo of the owner of the
+// request's bearer token, found in tokens, or else of the logged in user.
+// If there is neither, or the token is not in tokens, the request is
+// unauthorized.
+func authorizeAPI(w http.ResponseWriter, r *http.Request, c appengine.Context, tokens tokenStore, h func(http.ResponseWriter, *http.Request, appengine.Context, *datastore.Key)) {
+	var key *datastore.Key
+	if tok, ok := bearerToken(r); ok {
+		var err error
+		key, err = tokenUser(tokens, tok)
+		if err == errBadToken {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else if user.Current(c) != nil {
+		key = userInfoKey(c)
+	} else {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "authorization required", http.StatusUnauthorized)
+		return
+	}
+
+	h(w, r, c, key)
+}
+
+type tokenListEntry struct {
+	Name    string
+	Created time.Time
+	Hash    string
+}
+
+// tokenList is a type for sorting the tokens.
+type tokenList []tokenListEntry
+
+func (ts tokenList) Len() int {
+	return len(ts)
+}
+
+func (ts tokenList) Less(i, j int) bool {
+	return ts[i].Created.Before(ts[j].Created)
+}
+
+func (ts tokenList) Swap(i, j int) {
+	ts[i], ts[j] = ts[j], ts[i]
+}
+
+// HandleTokens lists the current user's API tokens. A POST creates a new
+// token, which is shown only once, or revokes the token with the given hash.
+func handleTokens(w http.ResponseWriter, r *http.Request) {
+	c := appengine.NewContext(r)
+	if r.Method == "POST" && !verifyCSRF(w, r, c) {
+		return
+	}
+
+	var page struct {
+		Title    string
+		Logout   string
+		NewToken string
+		Tokens   tokenList
+	}
+	page.Title = "API Tokens"
+
+	switch {
+	case r.Method == "POST" && r.FormValue("revoke") != "":
+		if err := revokeToken(c, r.FormValue("revoke")); err == datastore.ErrNoSuchEntity {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/settings/tokens", http.StatusFound)
+		return
+
+	case r.Method == "POST":
+		tok, err := createToken(c, strings.TrimSpace(r.FormValue("name")))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page.NewToken = tok
+
+	case r.Method != "GET":
+		methodNotAllowed(w, "GET", "POST")
+		return
+	}
+
+	var toks []APIToken
+	keys, err := datastore.NewQuery(tokenKind).Filter("User =", userInfoKey(c)).GetAll(c, &toks)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i, t := range toks {
+		page.Tokens = append(page.Tokens, tokenListEntry{
+			Name:    t.Name,
+			Created: t.Created,
+			Hash:    keys[i].StringID(),
+		})
+	}
+	sort.Sort(page.Tokens)
+
+	page.Logout, err = user.LogoutURL(c, "/")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	executeTemplate(w, c, "tokens.html", page)
+}
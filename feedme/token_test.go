@@ -0,0 +1,159 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewToken(t *testing.T) {
+	a, err := newToken()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	b, err := newToken()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(a) != 2*tokenBytes {
+		t.Errorf("Expected a %d character token, got [%s]", 2*tokenBytes, a)
+	}
+	if a == b {
+		t.Errorf("Expected distinct tokens, got [%s] twice", a)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	if hashToken("a") != hashToken("a") {
+		t.Errorf("Expected hashToken to be deterministic")
+	}
+	if hashToken("a") == hashToken("b") {
+		t.Errorf("Expected distinct tokens to have distinct hashes")
+	}
+	if h := hashToken("a"); h == "a" {
+		t.Errorf("Expected the hash to differ from the token")
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		tok    string
+		ok     bool
+	}{
+		{"", "", false},
+		{"Bearer", "", false},
+		{"Bearer ", "", false},
+		{"Basic abc", "", false},
+		{"Bearer abc", "abc", true},
+		{"bearer abc ", "abc", true},
+	}
+	for _, test := range tests {
+		r, err := http.NewRequest("GET", "/api/feeds", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		tok, ok := bearerToken(r)
+		if tok != test.tok || ok != test.ok {
+			t.Errorf("Expected bearerToken with [%s] to be [%s], %t, got [%s], %t",
+				test.header, test.tok, test.ok, tok, ok)
+		}
+	}
+}
+
+// A memTokens is a tokenStore backed by a map.
+type memTokens map[string]APIToken
+
+func (m memTokens) get(hash string) (APIToken, error) {
+	t, ok := m[hash]
+	if !ok {
+		return APIToken{}, datastore.ErrNoSuchEntity
+	}
+	return t, nil
+}
+
+// A brokenTokens is a tokenStore that always fails.
+type brokenTokens struct{}
+
+func (brokenTokens) get(string) (APIToken, error) {
+	return APIToken{}, errors.New("datastore unavailable")
+}
+
+func TestTokenUser(t *testing.T) {
+	owner := &datastore.Key{}
+	tok, err := newToken()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	tokens := memTokens{hashToken(tok): {User: owner, Name: "cli"}}
+
+	if key, err := tokenUser(tokens, tok); err != nil || key != owner {
+		t.Errorf("Expected the token's owner, got %v, %v", key, err)
+	}
+	if _, err := tokenUser(tokens, hashToken(tok)); err != errBadToken {
+		t.Errorf("Expected %v for the token's hash, got %v", errBadToken, err)
+	}
+	delete(tokens, hashToken(tok))
+	if _, err := tokenUser(tokens, tok); err != errBadToken {
+		t.Errorf("Expected %v for a revoked token, got %v", errBadToken, err)
+	}
+}
+
+func TestAuthorizeAPI(t *testing.T) {
+	owner := &datastore.Key{}
+	tok, err := newToken()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	revoked, err := newToken()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	tokens := memTokens{
+		hashToken(tok):     {User: owner},
+		hashToken(revoked): {User: owner},
+	}
+	delete(tokens, hashToken(revoked))
+
+	tests := []struct {
+		name   string
+		header string
+		tokens tokenStore
+		code   int
+		auth   string
+	}{
+		{name: "valid", header: "Bearer " + tok, tokens: tokens, code: http.StatusOK},
+		{name: "revoked", header: "Bearer " + revoked, tokens: tokens, code: http.StatusUnauthorized, auth: `Bearer error="invalid_token"`},
+		{name: "unknown", header: "Bearer nope", tokens: tokens, code: http.StatusUnauthorized, auth: `Bearer error="invalid_token"`},
+		{name: "store error", header: "Bearer " + tok, tokens: brokenTokens{}, code: http.StatusInternalServerError},
+		{name: "no token", tokens: tokens, code: http.StatusUnauthorized, auth: "Bearer"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/api/feeds", nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		w := httptest.NewRecorder()
+		var got *datastore.Key
+		called := false
+		authorizeAPI(w, r, logContext{}, test.tokens, func(w http.ResponseWriter, r *http.Request, c appengine.Context, key *datastore.Key) {
+			called, got = true, key
+		})
+		if w.Code != test.code {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.code, w.Code)
+		}
+		if ok := test.code == http.StatusOK; called != ok || ok && got != owner {
+			t.Errorf("%s: expected the handler to be called (%t) for the owner, got %t for %v", test.name, ok, called, got)
+		}
+		if a := w.Header().Get("WWW-Authenticate"); !strings.HasPrefix(a, test.auth) || (test.auth == "") != (a == "") {
+			t.Errorf("%s: expected WWW-Authenticate [%s], got [%s]", test.name, test.auth, a)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"appengine/taskqueue"
 	"appengine/user"
 	"fmt"
+	"time"
 )
 
 const (
@@ -17,10 +18,148 @@ const (
 
 type UserInfo struct {
 	Feeds []*datastore.Key `datastore:",noindex"`
+
+	// Categories holds the category of each feed: Categories[i] is the
+	// category of Feeds[i]. It may be shorter than Feeds for records
+	// stored before categories were added.
+	Categories []string `datastore:",noindex"`
+
+	// Titles holds the user's own title for each feed: Titles[i] is the
+	// title of Feeds[i], or empty if the user has not renamed it. It may
+	// be shorter than Feeds.
+	Titles []string `datastore:",noindex"`
+
+	// LastVisit is the last time that the user loaded an article view.
+	LastVisit time.Time `datastore:",noindex"`
+
+	// PendingRemoval holds the feeds that the user has removed but is
+	// not yet unsubscribed from, so that the removal can be undone.
+	// PendingRemovalAt[j] is when PendingRemoval[j] was removed, and
+	// PendingCategories[j] and PendingTitles[j] are the category and
+	// title that it had.
+	PendingRemoval    []*datastore.Key `datastore:",noindex"`
+	PendingRemovalAt  []time.Time      `datastore:",noindex"`
+	PendingCategories []string         `datastore:",noindex"`
+	PendingTitles     []string         `datastore:",noindex"`
+
+	// NextRemoval is when the earliest of the feeds pending removal is
+	// due to be finally removed, or zero if none are pending. It is
+	// indexed so that the users with removals due can be queried.
+	NextRemoval time.Time
+
+	// UserPrefs is embedded so that its fields are stored under the
+	// same names as before they were grouped.
+	UserPrefs
+}
+
+// UserPrefs are the preferences that a user sets on the settings page.
+type UserPrefs struct {
+	// DefaultCategory is the category given to newly subscribed feeds.
+	DefaultCategory string `datastore:",noindex"`
+
+	// NoDiscover is true if the user's subscriptions are not counted
+	// in the discover view.
+	NoDiscover bool `datastore:",noindex"`
+
+	// MutedKeywords are words and phrases that hide the articles
+	// containing them.
+	MutedKeywords []string `datastore:",noindex"`
+
+	// CollapseDuplicates is true if articles carried by more than one of
+	// the user's feeds are shown only once.
+	CollapseDuplicates bool `datastore:",noindex"`
+
+	// LatestDuration is how far back the latest view goes. If it is
+	// zero, latestDuration is used.
+	LatestDuration time.Duration `datastore:",noindex"`
+
+	// HideRead is true if read articles are hidden from every view but
+	// the all view.
+	HideRead bool `datastore:",noindex"`
+
+	// SortOrder is the order in which articles are shown: newestFirst,
+	// oldestFirst or byFeed. If it is empty, newestFirst is used.
+	SortOrder string `datastore:",noindex"`
+}
+
+// WithDefaults returns the preferences with the defaults in place of the
+// zero values of those that have defaults.
+func (p UserPrefs) withDefaults() UserPrefs {
+	if p.LatestDuration == 0 {
+		p.LatestDuration = latestDuration
+	}
+	if p.SortOrder == "" {
+		p.SortOrder = newestFirst
+	}
+	return p
+}
+
+// SortedBy returns whether the user's articles are shown in the given
+// order.
+func (p UserPrefs) SortedBy(order string) bool {
+	return p.withDefaults().SortOrder == order
+}
+
+// Latest returns how far back the user's latest view goes.
+func (u UserInfo) latest() time.Duration {
+	if u.LatestDuration == 0 {
+		return latestDuration
+	}
+	return u.LatestDuration
+}
+
+// LatestHours returns how far back the user's latest view goes, in hours.
+func (u UserInfo) LatestHours() int {
+	return int(u.latest() / time.Hour)
+}
+
+// Category returns the category of the ith feed.
+func (u UserInfo) category(i int) string {
+	if i < len(u.Categories) {
+		return u.Categories[i]
+	}
+	return ""
+}
+
+// Title returns the title that the user sees for the ith feed, whose
+// publisher's title is title. If i is negative, title is returned.
+func (u UserInfo) title(i int, title string) string {
+	if i >= 0 && i < len(u.Titles) && u.Titles[i] != "" {
+		return u.Titles[i]
+	}
+	return title
+}
+
+// SetTitle sets the user's own title for the ith feed. An empty title
+// clears it, so the publisher's title is shown again.
+func (u *UserInfo) setTitle(i int, title string) {
+	for len(u.Titles) <= i {
+		u.Titles = append(u.Titles, "")
+	}
+	u.Titles[i] = title
+}
+
+// FeedIndex returns the index of the feed in the user's feeds, or -1 if
+// the user is not subscribed to it.
+func (u UserInfo) feedIndex(feed *datastore.Key) int {
+	for i, k := range u.Feeds {
+		if k.Equal(feed) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Subscribed returns whether the user is subscribed to the feed.
+func (u UserInfo) subscribed(feed *datastore.Key) bool {
+	return u.feedIndex(feed) >= 0
 }
 
 // Subscribe adds a feed to the user's feed list if it is not already there.
-func subscribe(c appengine.Context, f FeedInfo) error {
+// The feed is put in the given category, or in the user's default category
+// if the given category is empty. If the feed is pending removal, it is
+// restored instead, keeping the category and title that it had.
+func subscribe(c appengine.Context, f FeedInfo, category string) error {
 	key := datastore.NewKey(c, feedKind, f.Url, 0, nil)
 	err := datastore.RunInTransaction(c, func(c appengine.Context) error {
 		u, err := getUserInfo(c)
@@ -37,17 +176,35 @@ func subscribe(c appengine.Context, f FeedInfo) error {
 				return nil
 			}
 		}
+		restored, err := restorePending(c, &u, key)
+		if err != nil {
+			return err
+		}
+		if restored {
+			_, err = datastore.Put(c, userInfoKey(c), &u)
+			return err
+		}
 
 		if err := datastore.Get(c, key, &f); err != nil && err != datastore.ErrNoSuchEntity {
 			return err
 		}
 
 		f.Refs++
+		if !u.NoDiscover {
+			f.Subscribers++
+		}
 		if _, err := datastore.Put(c, key, &f); err != nil {
 			return err
 		}
 
+		if category == "" {
+			category = u.DefaultCategory
+		}
+		for len(u.Categories) < len(u.Feeds) {
+			u.Categories = append(u.Categories, "")
+		}
 		u.Feeds = append(u.Feeds, key)
+		u.Categories = append(u.Categories, category)
 		_, err = datastore.Put(c, userInfoKey(c), &u)
 		return err
 	}, &datastore.TransactionOptions{XG: true})
@@ -60,56 +217,52 @@ func subscribe(c appengine.Context, f FeedInfo) error {
 	return err
 }
 
-// Unsubscribe removes a feed from the user's feed list.
-func unsubscribe(c appengine.Context, feedKey *datastore.Key) error {
+// RenameFeed sets the current user's own title for one of their feeds.
+// An empty title clears it.
+func renameFeed(c appengine.Context, feed *datastore.Key, title string) error {
 	return datastore.RunInTransaction(c, func(c appengine.Context) error {
 		u, err := getUserInfo(c)
 		if err != nil {
 			return err
 		}
-
-		i := 0
-		var k *datastore.Key
-		for i, k = range u.Feeds {
-			if feedKey.Equal(k) {
-				break
-			}
-		}
-		if i >= len(u.Feeds) {
-			return nil
-		}
-
-		var f FeedInfo
-		if err := datastore.Get(c, feedKey, &f); err != nil {
-			return err
+		i := u.feedIndex(feed)
+		if i < 0 {
+			return fmt.Errorf("not subscribed to %s", feed.StringID())
 		}
+		u.setTitle(i, title)
+		_, err = datastore.Put(c, userInfoKey(c), &u)
+		return err
+	}, nil)
+}
 
-		f.Refs--
-		if f.Refs <= 0 {
-			if err := f.rmArticles(c); err != nil {
-				return err
-			}
-			if err := datastore.Delete(c, feedKey); err != nil {
-				return err
-			}
-		} else if _, err := datastore.Put(c, feedKey, &f); err != nil {
+// SetLastVisit records t as the time of the current user's last visit.
+func setLastVisit(c appengine.Context, t time.Time) error {
+	return datastore.RunInTransaction(c, func(c appengine.Context) error {
+		u, err := getUserInfo(c)
+		if err != nil {
 			return err
 		}
-
-		u.Feeds = append(u.Feeds[:i], u.Feeds[i+1:]...)
+		u.LastVisit = t
 		_, err = datastore.Put(c, userInfoKey(c), &u)
 		return err
-	}, &datastore.TransactionOptions{XG: true})
+	}, nil)
 }
 
 // getUserInfo returns the UserInfo for the currently logged in user.
 // This function assumes that a user is loged in, otherwise it will panic.
 func getUserInfo(c appengine.Context) (UserInfo, error) {
+	return loadUserInfo(c, userInfoKey(c))
+}
+
+// LoadUserInfo returns the UserInfo with the given key. Preferences that
+// have not been set have their defaults.
+func loadUserInfo(c appengine.Context, key *datastore.Key) (UserInfo, error) {
 	var uinfo UserInfo
-	err := datastore.Get(c, userInfoKey(c), &uinfo)
+	err := datastore.Get(c, key, &uinfo)
 	if err != nil && err != datastore.ErrNoSuchEntity {
 		return UserInfo{}, err
 	}
+	uinfo.UserPrefs = uinfo.UserPrefs.withDefaults()
 	return uinfo, nil
 }
 
@@ -0,0 +1,40 @@
+package feedme
+
+import "testing"
+
+func TestUserTitle(t *testing.T) {
+	u := UserInfo{Titles: []string{"", "Mine"}}
+	tests := []struct {
+		i            int
+		title, shown string
+	}{
+		{i: 0, title: "Publisher", shown: "Publisher"},
+		{i: 1, title: "Publisher", shown: "Mine"},
+		{i: 2, title: "Publisher", shown: "Publisher"},
+		{i: -1, title: "Publisher", shown: "Publisher"},
+	}
+	for _, test := range tests {
+		if shown := u.title(test.i, test.title); shown != test.shown {
+			t.Errorf("Expected feed %d to be shown as [%s], got [%s]", test.i, test.shown, shown)
+		}
+	}
+}
+
+func TestSetTitle(t *testing.T) {
+	var u UserInfo
+	u.setTitle(2, "Mine")
+	if len(u.Titles) != 3 {
+		t.Fatalf("Expected 3 titles, got %d", len(u.Titles))
+	}
+	if shown := u.title(2, "Publisher"); shown != "Mine" {
+		t.Errorf("Expected the set title [Mine], got [%s]", shown)
+	}
+	if shown := u.title(0, "Publisher"); shown != "Publisher" {
+		t.Errorf("Expected the publisher's title for an unrenamed feed, got [%s]", shown)
+	}
+
+	u.setTitle(2, "")
+	if shown := u.title(2, "Publisher"); shown != "Publisher" {
+		t.Errorf("Expected clearing the title to show the publisher's title, got [%s]", shown)
+	}
+}
@@ -0,0 +1,263 @@
+package feedme
+
+import (
+	"appengine"
+	"appengine/datastore"
+	"appengine/urlfetch"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// WebSubLease is the lease requested when subscribing to a hub.
+	webSubLease = 7 * 24 * time.Hour
+
+	// WebSubRenewal is how long before a subscription expires that it
+	// is renewed.
+	webSubRenewal = 24 * time.Hour
+)
+
+// ErrBadSignature is returned for pushed content whose signature does not
+// match the feed's WebSub secret.
+var errBadSignature = errors.New("bad signature")
+
+func init() {
+	http.HandleFunc("/websub", handleWebSubCallback)
+}
+
+// NeedsWebSub returns whether, at time now, the feed has a hub to which
+// it is not subscribed, or its subscription is about to expire.
+func (f FeedInfo) needsWebSub(now time.Time) bool {
+	return f.Hub != "" && f.WebSubExpires.Before(now.Add(webSubRenewal))
+}
+
+// SubscribeWebSub asks the feed's hub to push the feed's content to the
+// WebSub callback. The subscription is not active until the hub verifies
+// it with the callback.
+func (f *FeedInfo) subscribeWebSub(c appengine.Context) error {
+	key := datastore.NewKey(c, feedKind, f.Url, 0, nil)
+	err := datastore.RunInTransaction(c, func(c appengine.Context) error {
+		if err := datastore.Get(c, key, f); err != nil {
+			return err
+		}
+		if f.WebSubSecret != "" {
+			return nil
+		}
+		secret, err := newToken()
+		if err != nil {
+			return err
+		}
+		f.WebSubSecret = secret
+		_, err = datastore.Put(c, key, f)
+		return err
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	callback := "https://" + appengine.DefaultVersionHostname(c) + "/websub?feed=" + url.QueryEscape(key.Encode())
+	return subscribeWith(urlfetch.Client(c), f.Hub, webSubRequest("subscribe", f.HubTopic, callback, f.WebSubSecret, webSubLease))
+}
+
+// WebSubRequest returns the form of a subscription request to a hub.
+func webSubRequest(mode, topic, callback, secret string, lease time.Duration) url.Values {
+	return url.Values{
+		"hub.mode":          {mode},
+		"hub.topic":         {topic},
+		"hub.callback":      {callback},
+		"hub.secret":        {secret},
+		"hub.lease_seconds": {strconv.Itoa(int(lease / time.Second))},
+	}
+}
+
+// SubscribeWith posts a subscription request to a hub using the given
+// client. Hubs accept requests with a 2xx status.
+func subscribeWith(client *http.Client, hub string, form url.Values) error {
+	resp, err := client.PostForm(hub, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// HandleWebSubCallback is the callback of the WebSub subscriptions of the
+// feed whose encoded key is given by the feed query value. GET requests
+// are the hub verifying a subscription, and POST requests are the hub
+// pushing the feed's new content.
+func handleWebSubCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		methodNotAllowed(w, "GET", "POST")
+		return
+	}
+
+	c := appengine.NewContext(r)
+	key, err := datastore.DecodeKey(r.URL.Query().Get("feed"))
+	if err != nil || key.Kind() != feedKind {
+		http.Error(w, "bad feed key", http.StatusBadRequest)
+		return
+	}
+	var f FeedInfo
+	if err := datastore.Get(c, key, &f); err == datastore.ErrNoSuchEntity {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == "GET" {
+		verifyWebSub(w, r, c, key, f)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	articles, err := pushedArticles(c, f, r.Header.Get("X-Hub-Signature"), body)
+	if err == errBadSignature {
+		// Hubs must not learn whether the signature was accepted.
+		c.Warningf("%s: ignoring pushed content with a bad signature", f.Url)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := f.storeArticles(c, articles, false); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	invalidateFeedLatest(c, key)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// VerifyWebSub answers a hub's verification of the WebSub subscription of
+// the feed f, which has the given key, recording when the subscription
+// expires.
+func verifyWebSub(w http.ResponseWriter, r *http.Request, c appengine.Context, key *datastore.Key, f FeedInfo) {
+	q := r.URL.Query()
+	if q.Get("hub.mode") == "denied" {
+		c.Warningf("%s: the hub denied the subscription: %s", f.Url, q.Get("hub.reason"))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	now := time.Now()
+	challenge, expires, ok := verifyChallenge(q, f, now)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if !expires.IsZero() {
+		err := datastore.RunInTransaction(c, func(c appengine.Context) error {
+			var f FeedInfo
+			if err := datastore.Get(c, key, &f); err != nil {
+				return err
+			}
+			f.WebSubExpires = expires
+			_, err := datastore.Put(c, key, &f)
+			return err
+		}, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, challenge)
+}
+
+// VerifyChallenge checks the query of a hub's verification request for
+// the feed f at time now. If the request is for a subscription that the
+// feed wants, the hub's challenge is returned with true, along with when
+// the subscription expires, or the zero time for an unsubscription.
+func verifyChallenge(q url.Values, f FeedInfo, now time.Time) (string, time.Time, bool) {
+	challenge := q.Get("hub.challenge")
+	if challenge == "" || f.HubTopic == "" || q.Get("hub.topic") != f.HubTopic {
+		return "", time.Time{}, false
+	}
+	switch q.Get("hub.mode") {
+	case "subscribe":
+		if f.Hub == "" || f.WebSubSecret == "" {
+			return "", time.Time{}, false
+		}
+		lease := webSubLease
+		if s, err := strconv.Atoi(q.Get("hub.lease_seconds")); err == nil && s > 0 {
+			lease = time.Duration(s) * time.Second
+		}
+		return challenge, now.Add(lease), true
+	case "unsubscribe":
+		if f.Hub != "" {
+			return "", time.Time{}, false
+		}
+		return challenge, time.Time{}, true
+	}
+	return "", time.Time{}, false
+}
+
+// PushedArticles returns the articles of content that a hub pushed for
+// the feed f, with the given X-Hub-Signature header. Content that is not
+// signed with the feed's secret returns errBadSignature.
+func pushedArticles(c appengine.Context, f FeedInfo, signature string, body []byte) (Articles, error) {
+	if f.WebSubSecret == "" || !validSignature(f.WebSubSecret, signature, body) {
+		return nil, errBadSignature
+	}
+	_, articles, err := parseFeed(c, f.Url, body)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(articles)
+	if len(articles) > maxNewArticles {
+		articles = articles[:maxNewArticles]
+	}
+	return articles, nil
+}
+
+// ValidSignature returns whether signature, of the form method=hex, is
+// the HMAC of body with the secret, using one of the hash methods that
+// WebSub allows.
+func validSignature(secret, signature string, body []byte) bool {
+	i := strings.Index(signature, "=")
+	if i < 0 {
+		return false
+	}
+	var h func() hash.Hash
+	switch signature[:i] {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	case "sha384":
+		h = sha512.New384
+	case "sha512":
+		h = sha512.New
+	default:
+		return false
+	}
+	sum, err := hex.DecodeString(signature[i+1:])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), sum)
+}
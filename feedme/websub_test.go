@@ -0,0 +1,205 @@
+package feedme
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVerifyChallenge(t *testing.T) {
+	now := time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC)
+	subscribed := FeedInfo{Url: "http://example.com/feed", Hub: "http://hub.example.com/", HubTopic: "http://example.com/self", WebSubSecret: "secret"}
+	dropped := FeedInfo{Url: "http://example.com/feed", HubTopic: "http://example.com/self"}
+	query := func(mode, topic, challenge, lease string) url.Values {
+		return url.Values{"hub.mode": {mode}, "hub.topic": {topic}, "hub.challenge": {challenge}, "hub.lease_seconds": {lease}}
+	}
+
+	tests := []struct {
+		name      string
+		q         url.Values
+		f         FeedInfo
+		challenge string
+		expires   time.Time
+		ok        bool
+	}{
+		{
+			name:      "subscribe",
+			q:         query("subscribe", "http://example.com/self", "abc", "3600"),
+			f:         subscribed,
+			challenge: "abc",
+			expires:   now.Add(time.Hour),
+			ok:        true,
+		},
+		{
+			name:      "no lease",
+			q:         query("subscribe", "http://example.com/self", "abc", ""),
+			f:         subscribed,
+			challenge: "abc",
+			expires:   now.Add(webSubLease),
+			ok:        true,
+		},
+		{
+			name: "wrong topic",
+			q:    query("subscribe", "http://example.com/other", "abc", "3600"),
+			f:    subscribed,
+		},
+		{
+			name: "no challenge",
+			q:    query("subscribe", "http://example.com/self", "", "3600"),
+			f:    subscribed,
+		},
+		{
+			name: "never requested",
+			q:    query("subscribe", "http://example.com/self", "abc", "3600"),
+			f:    FeedInfo{Url: "http://example.com/feed", Hub: "http://hub.example.com/", HubTopic: "http://example.com/self"},
+		},
+		{
+			name: "no longer wanted",
+			q:    query("subscribe", "http://example.com/self", "abc", "3600"),
+			f:    dropped,
+		},
+		{
+			name:      "unsubscribe",
+			q:         query("unsubscribe", "http://example.com/self", "abc", ""),
+			f:         dropped,
+			challenge: "abc",
+			ok:        true,
+		},
+		{
+			name: "unsubscribe wanted feed",
+			q:    query("unsubscribe", "http://example.com/self", "abc", ""),
+			f:    subscribed,
+		},
+		{
+			name: "unknown mode",
+			q:    query("publish", "http://example.com/self", "abc", ""),
+			f:    subscribed,
+		},
+	}
+	for _, test := range tests {
+		challenge, expires, ok := verifyChallenge(test.q, test.f, now)
+		if challenge != test.challenge || !expires.Equal(test.expires) || ok != test.ok {
+			t.Errorf("%s: expected [%s], %s, %t, got [%s], %s, %t", test.name,
+				test.challenge, test.expires, test.ok, challenge, expires, ok)
+		}
+	}
+}
+
+func TestSubscribeWith(t *testing.T) {
+	var got url.Values
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r.PostForm
+		if r.PostForm.Get("hub.topic") == "http://example.com/refused" {
+			http.Error(w, "refused", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer s.Close()
+
+	form := webSubRequest("subscribe", "http://example.com/self", "https://feedme.example.com/websub?feed=k", "secret", time.Hour)
+	if err := subscribeWith(http.DefaultClient, s.URL, form); err != nil {
+		t.Errorf("Expected no error, got %s", err)
+	}
+	want := url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {"http://example.com/self"},
+		"hub.callback":      {"https://feedme.example.com/websub?feed=k"},
+		"hub.secret":        {"secret"},
+		"hub.lease_seconds": {"3600"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the hub to get %v, got %v", want, got)
+	}
+
+	form = webSubRequest("subscribe", "http://example.com/refused", "https://feedme.example.com/websub?feed=k", "secret", time.Hour)
+	if err := subscribeWith(http.DefaultClient, s.URL, form); err == nil {
+		t.Errorf("Expected an error for a refused subscription")
+	}
+}
+
+func TestNeedsWebSub(t *testing.T) {
+	now := time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		f    FeedInfo
+		want bool
+	}{
+		{FeedInfo{}, false},
+		{FeedInfo{Hub: "http://hub.example.com/"}, true},
+		{FeedInfo{Hub: "http://hub.example.com/", WebSubExpires: now.Add(time.Hour)}, true},
+		{FeedInfo{Hub: "http://hub.example.com/", WebSubExpires: now.Add(webSubLease)}, false},
+	}
+	for _, test := range tests {
+		if got := test.f.needsWebSub(now); got != test.want {
+			t.Errorf("Expected needsWebSub(%v) to be %t, got %t", test.f.WebSubExpires, test.want, got)
+		}
+	}
+}
+
+func TestPushedArticles(t *testing.T) {
+	body := []byte(`<?xml version="1.0"?>
+		<feed xmlns="http://www.w3.org/2005/Atom">
+		<title>Feed</title>
+		<link rel="hub" href="http://hub.example.com/"/>
+		<entry><title>New</title><link href="http://example.com/new"/><id>tag:example.com,2014:new</id>
+		<updated>2014-03-01T12:00:00Z</updated></entry>
+		</feed>`)
+	sign := func(h func() hash.Hash, method, secret string) string {
+		mac := hmac.New(h, []byte(secret))
+		mac.Write(body)
+		return method + "=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	f := FeedInfo{Url: "http://example.com/feed", Hub: "http://hub.example.com/", WebSubSecret: "secret"}
+
+	tests := []struct {
+		name      string
+		f         FeedInfo
+		signature string
+		err       error
+	}{
+		{name: "sha1", f: f, signature: sign(sha1.New, "sha1", "secret")},
+		{name: "sha256", f: f, signature: sign(sha256.New, "sha256", "secret")},
+		{name: "wrong secret", f: f, signature: sign(sha1.New, "sha1", "other"), err: errBadSignature},
+		{name: "wrong method", f: f, signature: sign(sha1.New, "sha256", "secret"), err: errBadSignature},
+		{name: "unsigned", f: f, err: errBadSignature},
+		{name: "not subscribed", f: FeedInfo{Url: f.Url}, signature: sign(sha1.New, "sha1", ""), err: errBadSignature},
+	}
+	for _, test := range tests {
+		articles, err := pushedArticles(nil, test.f, test.signature, body)
+		if err != test.err {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(articles) != 1 || articles[0].Title != "New" || articles[0].Link != "http://example.com/new" {
+			t.Errorf("%s: expected the pushed article, got %v", test.name, articles)
+		}
+	}
+}
+
+func TestRefreshedWebSub(t *testing.T) {
+	expires := time.Date(2014, 3, 8, 12, 0, 0, 0, time.UTC)
+	stored := FeedInfo{Url: "http://example.com/feed", Hub: "http://hub.example.com/", HubTopic: "http://example.com/feed",
+		WebSubSecret: "secret", WebSubExpires: expires, ArticleCount: 3}
+
+	f := refreshed(stored, FeedInfo{Url: stored.Url, Hub: stored.Hub, HubTopic: stored.HubTopic}, time.Now())
+	if f.WebSubSecret != "secret" || !f.WebSubExpires.Equal(expires) || f.ArticleCount != 3 {
+		t.Errorf("Expected the subscription and article count to be kept, got %+v", f)
+	}
+
+	f = refreshed(stored, FeedInfo{Url: stored.Url, Hub: "http://other.example.com/", HubTopic: stored.HubTopic}, time.Now())
+	if f.WebSubSecret != "" || !f.WebSubExpires.IsZero() {
+		t.Errorf("Expected the subscription to be dropped for a new hub, got %+v", f)
+	}
+}
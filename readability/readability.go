@@ -0,0 +1,326 @@
+// Package readability extracts the main content from the web page of an
+// article, leaving out the navigation, comments, sharing buttons and other
+// boilerplate around it.
+//
+// The content is found by scoring the elements that contain paragraphs of
+// text, much like Arc90's Readability: the element whose paragraphs have
+// the most text, in the fewest links, is taken to be the article.
+package readability
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"code.google.com/p/go.net/html"
+)
+
+// ErrNoContent is returned by Extract when the page has no element that
+// looks like the content of an article.
+var ErrNoContent = errors.New("readability: no article content found")
+
+const (
+	// MinParagraphLength is the length of text below which a paragraph
+	// does not count toward the score of its ancestors.
+	minParagraphLength = 25
+
+	// MinContentLength is the length of text, outside of links, below
+	// which the best candidate is not taken to be an article.
+	minContentLength = 140
+
+	// ClassWeight is added to the score of an element whose class or id
+	// suggests content, and subtracted if it suggests boilerplate.
+	classWeight = 25
+)
+
+var (
+	// Unlikely matches the classes and ids of boilerplate elements,
+	// which are removed unless they also match maybe.
+	unlikely = regexp.MustCompile(`(?i)ad-break|advert|agegate|banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|header|menu|modal|nav|newsletter|pager|pagination|popup|promo|related|remark|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|tweet|twitter|widget`)
+	maybe    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+
+	// Positive and negative match the classes and ids that raise or
+	// lower the score of a candidate.
+	positive = regexp.MustCompile(`(?i)article|body|content|entry|h-entry|main|page|post|story|text|blog`)
+	negative = regexp.MustCompile(`(?i)hidden|banner|combx|comment|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+)
+
+// BoilerplateElements are removed, along with their contents, before the
+// page is scored.
+var boilerplateElements = map[string]bool{
+	"aside":    true,
+	"button":   true,
+	"embed":    true,
+	"footer":   true,
+	"form":     true,
+	"header":   true,
+	"iframe":   true,
+	"input":    true,
+	"link":     true,
+	"meta":     true,
+	"nav":      true,
+	"noscript": true,
+	"object":   true,
+	"script":   true,
+	"select":   true,
+	"style":    true,
+	"svg":      true,
+	"textarea": true,
+}
+
+// Extract returns the HTML of the main content of the web page read from
+// r, with its relative URLs resolved against base, the URL of the page.
+// ErrNoContent is returned if no part of the page looks like an article.
+func Extract(r io.Reader, base string) ([]byte, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	body := findElement(doc, "body")
+	if body == nil {
+		return nil, ErrNoContent
+	}
+	prune(body)
+
+	top := topCandidate(body)
+	if top == nil {
+		return nil, ErrNoContent
+	}
+	clean(top)
+	if float64(textLength(top))*(1-linkDensity(top)) < minContentLength {
+		return nil, ErrNoContent
+	}
+	if b, err := url.Parse(base); err == nil && b.IsAbs() {
+		resolve(b, top)
+	}
+
+	var buf bytes.Buffer
+	for k := top.FirstChild; k != nil; k = k.NextSibling {
+		if err := html.Render(&buf, k); err != nil {
+			return nil, err
+		}
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
+// FindElement returns the first element with the given tag in a
+// depth-first walk of n, or nil if there is none.
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for k := n.FirstChild; k != nil; k = k.NextSibling {
+		if e := findElement(k, tag); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// Prune removes the boilerplate elements and comments below n, along
+// with the elements whose class or id marks them as unlikely to be part
+// of the content.
+func prune(n *html.Node) {
+	for k := n.FirstChild; k != nil; {
+		next := k.NextSibling
+		switch {
+		case k.Type == html.CommentNode,
+			k.Type == html.ElementNode && boilerplateElements[k.Data],
+			k.Type == html.ElementNode && k.Data != "article" && isUnlikely(k):
+			n.RemoveChild(k)
+		default:
+			prune(k)
+		}
+		k = next
+	}
+}
+
+// IsUnlikely returns whether the class or id of n marks it as
+// boilerplate.
+func isUnlikely(n *html.Node) bool {
+	s := classAndID(n)
+	return unlikely.MatchString(s) && !maybe.MatchString(s)
+}
+
+// ClassAndID returns the class and id attributes of n, separated by a
+// space.
+func classAndID(n *html.Node) string {
+	return attr(n, "class") + " " + attr(n, "id")
+}
+
+// Attr returns the value of the attribute of n with the given key, or
+// the empty string if n has no such attribute.
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// TopCandidate returns the element below body with the highest score, or
+// nil if no element contains a paragraph of text.
+func topCandidate(body *html.Node) *html.Node {
+	scores := make(map[*html.Node]float64)
+	var order []*html.Node
+	add := func(n *html.Node, s float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(n)
+			order = append(order, n)
+		}
+		scores[n] += s
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "p" || n.Data == "pre" || n.Data == "td") {
+			text := innerText(n)
+			l := utf8.RuneCountInString(text)
+			if l >= minParagraphLength {
+				s := 1 + float64(strings.Count(text, ","))
+				if l >= 300 {
+					s += 3
+				} else {
+					s += float64(l / 100)
+				}
+				add(n.Parent, s)
+				if n.Parent != nil {
+					add(n.Parent.Parent, s/2)
+				}
+			}
+		}
+		for k := n.FirstChild; k != nil; k = k.NextSibling {
+			walk(k)
+		}
+	}
+	walk(body)
+
+	var top *html.Node
+	var best float64
+	for _, n := range order {
+		s := scores[n] * (1 - linkDensity(n))
+		if top == nil || s > best {
+			top, best = n, s
+		}
+	}
+	return top
+}
+
+// InitialScore returns the score of n before the paragraphs it contains
+// are counted, from its tag and its class and id.
+func initialScore(n *html.Node) float64 {
+	var s float64
+	switch n.Data {
+	case "article":
+		s = 10
+	case "div":
+		s = 5
+	case "blockquote", "pre", "td":
+		s = 3
+	case "address", "dd", "dl", "dt", "li", "ol", "ul":
+		s = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		s = -5
+	}
+	c := classAndID(n)
+	if positive.MatchString(c) {
+		s += classWeight
+	}
+	if negative.MatchString(c) {
+		s -= classWeight
+	}
+	return s
+}
+
+// Clean removes the elements below the content n that are mostly links,
+// such as lists of tags or of related articles that were not marked by
+// their class.
+func clean(n *html.Node) {
+	for k := n.FirstChild; k != nil; {
+		next := k.NextSibling
+		if k.Type == html.ElementNode {
+			switch k.Data {
+			case "div", "ul", "ol", "table", "section":
+				if linkDensity(k) > 0.5 {
+					n.RemoveChild(k)
+					break
+				}
+				clean(k)
+			default:
+				clean(k)
+			}
+		}
+		k = next
+	}
+}
+
+// InnerText returns the text below n with its runs of white space
+// collapsed.
+func innerText(n *html.Node) string {
+	var buf bytes.Buffer
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+			buf.WriteByte(' ')
+		}
+		for k := n.FirstChild; k != nil; k = k.NextSibling {
+			walk(k)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// TextLength returns the number of characters of text below n.
+func textLength(n *html.Node) int {
+	return utf8.RuneCountInString(innerText(n))
+}
+
+// LinkDensity returns the fraction of the text below n that is the text
+// of links.
+func linkDensity(n *html.Node) float64 {
+	total := textLength(n)
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			links += textLength(n)
+			return
+		}
+		for k := n.FirstChild; k != nil; k = k.NextSibling {
+			walk(k)
+		}
+	}
+	walk(n)
+	return float64(links) / float64(total)
+}
+
+// Resolve resolves the href and src attributes of n and its descendants
+// against base.
+func resolve(base *url.URL, n *html.Node) {
+	if n.Type == html.ElementNode {
+		for i, a := range n.Attr {
+			if a.Key != "href" && a.Key != "src" {
+				continue
+			}
+			if u, err := base.Parse(strings.TrimSpace(a.Val)); err == nil {
+				n.Attr[i].Val = u.String()
+			}
+		}
+	}
+	for k := n.FirstChild; k != nil; k = k.NextSibling {
+		resolve(base, k)
+	}
+}
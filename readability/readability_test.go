@@ -0,0 +1,95 @@
+package readability
+
+import (
+	"strings"
+	"testing"
+)
+
+const articlePage = `<!DOCTYPE html>
+<html><head>
+<title>Why Gophers Dig | The Burrow</title>
+<style>body { font-family: serif; }</style>
+<script>trackPageView();</script>
+</head><body>
+<header class="site-header">
+	<a href="/">The Burrow</a>
+	<nav><ul><li><a href="/news">News</a></li><li><a href="/about">About</a></li></ul></nav>
+</header>
+<div id="cookie-banner">We use cookies. <button>OK</button></div>
+<div class="layout">
+	<div class="sidebar">
+		<h3>Popular</h3>
+		<p><a href="/a">A much more popular article about moles and their tunnels</a></p>
+		<p><a href="/b">Another popular article, about prairie dogs, that people read</a></p>
+	</div>
+	<article class="post">
+		<h1>Why Gophers Dig</h1>
+		<p>Gophers dig because their burrows keep them safe from predators, from the heat of summer, and from the cold of winter.</p>
+		<p>A single gopher can move a ton of soil in a year, building tunnels that run for hundreds of feet, with chambers for food, nests, and <a href="/latrines">latrines</a>.</p>
+		<p><img src="images/tunnel.png" alt="A tunnel"></p>
+		<p>Farmers, of course, are less enthusiastic about all of this digging than the gophers are.</p>
+		<div class="share-buttons"><a href="https://twitter.com/share">Tweet</a> <a href="https://facebook.com/share">Share</a></div>
+		<ul><li><a href="/tags/gophers">gophers</a></li><li><a href="/tags/digging">digging</a></li></ul>
+	</article>
+	<div id="comments">
+		<h3>3 Comments</h3>
+		<p>Great article, thanks for writing it, I learned a lot about gophers today!</p>
+	</div>
+</div>
+<footer><p>Copyright 2020 The Burrow, all rights reserved, no gophers were harmed.</p></footer>
+<!-- analytics -->
+</body></html>`
+
+func TestExtract(t *testing.T) {
+	out, err := Extract(strings.NewReader(articlePage), "https://example.com/2020/gophers.html")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got := string(out)
+
+	for _, want := range []string{
+		"Gophers dig because their burrows keep them safe",
+		"A single gopher can move a ton of soil",
+		"Farmers, of course, are less enthusiastic",
+		`<a href="https://example.com/latrines">latrines</a>`,
+		`<img src="https://example.com/2020/images/tunnel.png" alt="A tunnel"/>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected the content to contain [%s], got [%s]", want, got)
+		}
+	}
+	for _, boilerplate := range []string{
+		"The Burrow",
+		"About",
+		"cookies",
+		"Popular",
+		"Tweet",
+		"/tags/",
+		"Comments",
+		"Great article",
+		"Copyright",
+		"trackPageView",
+		"font-family",
+		"analytics",
+	} {
+		if strings.Contains(got, boilerplate) {
+			t.Errorf("Expected [%s] to be stripped, got [%s]", boilerplate, got)
+		}
+	}
+}
+
+func TestExtractNoContent(t *testing.T) {
+	pages := []string{
+		``,
+		`<html><body><nav><a href="/">Home</a></nav></body></html>`,
+		`<html><body><p>Too short to be an article, though it is a paragraph.</p></body></html>`,
+		`<html><body><div><p><a href="/1">A paragraph that is nothing but a link to another page</a></p>
+			<p><a href="/2">And another paragraph that is nothing but a link to a page</a></p>
+			<p><a href="/3">And a third paragraph that is nothing but a link to a page</a></p></div></body></html>`,
+	}
+	for _, page := range pages {
+		if out, err := Extract(strings.NewReader(page), "https://example.com/"); err != ErrNoContent {
+			t.Errorf("Expected ErrNoContent for [%s], got [%s], %v", page, out, err)
+		}
+	}
+}
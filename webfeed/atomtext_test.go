@@ -0,0 +1,58 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAtomTextTypes(t *testing.T) {
+	tests := []struct {
+		name, elm string
+		out       string
+	}{
+		{"text", `type="text">Tom &amp; Jerry &lt;3`, "Tom &amp; Jerry &lt;3"},
+		{"default", `>&lt;p&gt;Tom &amp;amp; Jerry&lt;/p&gt;`, "<p>Tom &amp; Jerry</p>"},
+		{"html", `type="html">&lt;p&gt;Tom &amp;amp; Jerry&lt;/p&gt;`, "<p>Tom &amp; Jerry</p>"},
+		{"html CDATA", `type="html"><![CDATA[<p>Hello</p>]]>`, "<p>Hello</p>"},
+		{"xhtml", `type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Hi <b>there</b></p></div>`,
+			`<div xmlns="http://www.w3.org/1999/xhtml"><p>Hi <b>there</b></p></div>`},
+	}
+
+	for _, test := range tests {
+		for _, tag := range []string{"summary", "content"} {
+			data := `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title><entry><title>E</title>` +
+				"<" + tag + " " + test.elm + "</" + tag + "></entry></feed>"
+			f, err := Read(strings.NewReader(data))
+			if err != nil {
+				t.Errorf("%s %s: unexpected error: %s", test.name, tag, err)
+				continue
+			}
+			o := f.Entries[0].Summary
+			if tag == "content" {
+				o = f.Entries[0].Content
+			}
+			if string(o) != test.out {
+				t.Errorf("%s %s: expected [%s], got [%s]", test.name, tag, test.out, o)
+			}
+		}
+	}
+}
+
+func TestAtomTitleDefaultType(t *testing.T) {
+	const data = `<feed xmlns="http://www.w3.org/2005/Atom"><title>Tom &amp; Jerry</title>
+<entry><title>Using &lt;video&gt; tags</title><content>&lt;p&gt;Body&lt;/p&gt;</content></entry></feed>`
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Title != "Tom & Jerry" {
+		t.Errorf("Expected the feed title [Tom & Jerry], got [%s]", f.Title)
+	}
+	e := f.Entries[0]
+	if e.Title != "Using <video> tags" {
+		t.Errorf("Expected the untyped title to be text, got [%s]", e.Title)
+	}
+	if string(e.Content) != "<p>Body</p>" {
+		t.Errorf("Expected the untyped content to be HTML, got [%s]", e.Content)
+	}
+}
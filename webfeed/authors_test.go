@@ -0,0 +1,65 @@
+package webfeed
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAuthors(t *testing.T) {
+	tests := []struct {
+		name, data string
+		feed       []string
+		entries    [][]string
+	}{
+		{
+			name: "atom",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title>
+<author><name>Feed Author</name></author>
+<entry><title>One</title><author><name>Alice</name></author><author><name> Bob </name></author></entry>
+<entry><title>None</title></entry>
+</feed>`,
+			feed:    []string{"Feed Author"},
+			entries: [][]string{{"Alice", "Bob"}, nil},
+		},
+		{
+			name: "rss",
+			data: `<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel><title>T</title>
+<dc:creator>Channel Creator</dc:creator>
+<item><title>Author</title><author>alice@example.com (Alice)</author></item>
+<item><title>Creators</title><dc:creator>Alice</dc:creator><dc:creator>Bob</dc:creator><dc:creator>Alice</dc:creator></item>
+<item><title>Both</title><author>bob@example.com</author><dc:creator>Bob</dc:creator></item>
+<item><title>None</title></item>
+</channel></rss>`,
+			feed:    []string{"Channel Creator"},
+			entries: [][]string{{"alice@example.com (Alice)"}, {"Alice", "Bob"}, {"bob@example.com", "Bob"}, nil},
+		},
+		{
+			name: "json",
+			data: `{"version": "https://jsonfeed.org/version/1.1", "authors": [{"name": "Feed Author"}],
+"items": [{"id": "1", "authors": [{"name": "Alice"}, {"name": "Bob"}]}, {"id": "2", "author": {"name": "Carol"}}, {"id": "3"}]}`,
+			feed:    []string{"Feed Author"},
+			entries: [][]string{{"Alice", "Bob"}, {"Carol"}, nil},
+		},
+	}
+
+	for _, test := range tests {
+		f, err := ReadAny(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(f.Authors, test.feed) {
+			t.Errorf("%s: expected feed authors %v, got %v", test.name, test.feed, f.Authors)
+		}
+		if len(f.Entries) != len(test.entries) {
+			t.Errorf("%s: expected %d entries, got %d", test.name, len(test.entries), len(f.Entries))
+			continue
+		}
+		for i, e := range f.Entries {
+			if !reflect.DeepEqual(e.Authors, test.entries[i]) {
+				t.Errorf("%s: expected entry %d authors %v, got %v", test.name, i, test.entries[i], e.Authors)
+			}
+		}
+	}
+}
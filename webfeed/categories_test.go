@@ -0,0 +1,53 @@
+package webfeed
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCategories(t *testing.T) {
+	tests := []struct {
+		name, data string
+		entries    [][]string
+	}{
+		{
+			name: "rss",
+			data: `<rss version="2.0"><channel><title>T</title>
+<item><title>Tagged</title><category>Go</category><category domain="http://example.com/tags">Web</category><category>go</category></item>
+<item><title>None</title></item>
+</channel></rss>`,
+			entries: [][]string{{"Go", "Web"}, nil},
+		},
+		{
+			name: "atom",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title>
+<entry><title>Tagged</title><category term="Go"/><category term="GO" label="Go!"/><category term=" Web "/></entry>
+<entry><title>None</title></entry>
+</feed>`,
+			entries: [][]string{{"Go", "Web"}, nil},
+		},
+		{
+			name:    "json",
+			data:    `{"version": "https://jsonfeed.org/version/1.1", "items": [{"id": "1", "tags": ["Go", "web", "Web"]}, {"id": "2"}]}`,
+			entries: [][]string{{"Go", "web"}, nil},
+		},
+	}
+
+	for _, test := range tests {
+		f, err := ReadAny(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if len(f.Entries) != len(test.entries) {
+			t.Errorf("%s: expected %d entries, got %d", test.name, len(test.entries), len(f.Entries))
+			continue
+		}
+		for i, e := range f.Entries {
+			if !reflect.DeepEqual(e.Categories, test.entries[i]) {
+				t.Errorf("%s: expected entry %d categories %v, got %v", test.name, i, test.entries[i], e.Categories)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package webfeed
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// A charmapReader transcodes a single-byte character set into UTF-8.
+type charmapReader struct {
+	r *bufio.Reader
+	// High maps the bytes 0x80–0x9F to runes. If high is nil, each byte
+	// is the rune of the same value, as in ISO-8859-1.
+	high    *[32]rune
+	pending []byte
+}
+
+func newCharmapReader(r io.Reader, high *[32]rune) *charmapReader {
+	return &charmapReader{r: bufio.NewReader(r), high: high}
+}
+
+func (c *charmapReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(c.pending) > 0 {
+			k := copy(p[n:], c.pending)
+			c.pending = c.pending[k:]
+			n += k
+			continue
+		}
+		b, err := c.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if b < utf8.RuneSelf {
+			p[n] = b
+			n++
+			continue
+		}
+		r := rune(b)
+		if c.high != nil && b < 0xA0 {
+			r = c.high[b-0x80]
+		}
+		var buf [utf8.UTFMax]byte
+		k := utf8.EncodeRune(buf[:], r)
+		c.pending = append(c.pending[:0], buf[:k]...)
+	}
+	return n, nil
+}
+
+// Windows1252 maps the bytes 0x80–0x9F of Windows-1252 to runes. The
+// five undefined bytes map to the C1 control characters of the same value.
+var windows1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡',
+	'ˆ', '‰', 'Š', '‹', 'Œ', '\u008D', 'Ž', '\u008F',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—',
+	'˜', '™', 'š', '›', 'œ', '\u009D', 'ž', 'Ÿ',
+}
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+)
+
+// ErrUTF16 is returned when reading a document that begins with a UTF-16
+// byte-order mark. UTF-16 documents are not supported.
+var ErrUTF16 = errors.New("UTF-16 encoded feeds are not supported")
+
+// SkipBOM returns a reader for r that skips a leading UTF-8 byte-order
+// mark. An error is returned if r begins with a UTF-16 byte-order mark.
+func skipBOM(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	b, err := br.Peek(len(utf8BOM))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(b, utf8BOM):
+		br.Discard(len(utf8BOM))
+	case bytes.HasPrefix(b, utf16BEBOM), bytes.HasPrefix(b, utf16LEBOM):
+		return nil, ErrUTF16
+	}
+	return br, nil
+}
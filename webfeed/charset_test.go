@@ -0,0 +1,92 @@
+package webfeed
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestLatin1Feed(t *testing.T) {
+	data := []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+		"<rss version=\"2.0\"><channel><title>Caf\xe9</title>" +
+		"<item><title>Cr\xe8me br\xfbl\xe9e</title></item>" +
+		"</channel></rss>")
+
+	f, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Title != "Café" {
+		t.Errorf("Expected title [Café], got [%s]", f.Title)
+	}
+	if f.Entries[0].Title != "Crème brûlée" {
+		t.Errorf("Expected entry title [Crème brûlée], got [%s]", f.Entries[0].Title)
+	}
+}
+
+func TestWindows1252Feed(t *testing.T) {
+	data := []byte("<?xml version=\"1.0\" encoding=\"windows-1252\"?>\n" +
+		"<rss version=\"2.0\"><channel><title>\x93Quoted\x94 \x80 caf\xe9</title></channel></rss>")
+
+	f, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if exp := "“Quoted” € café"; f.Title != exp {
+		t.Errorf("Expected title [%s], got [%s]", exp, f.Title)
+	}
+}
+
+func TestCharmapReader(t *testing.T) {
+	in := []byte("a\xe9\x80\xff")
+	tests := []struct {
+		high *[32]rune
+		out  string
+	}{
+		{nil, "aé\u0080ÿ"},
+		{&windows1252, "aé€ÿ"},
+	}
+	for _, test := range tests {
+		o, err := ioutil.ReadAll(newCharmapReader(bytes.NewReader(in), test.high))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if string(o) != test.out {
+			t.Errorf("Expected [%q], got [%q]", test.out, o)
+		}
+	}
+}
+
+func TestUnsupportedCharset(t *testing.T) {
+	data := []byte("<?xml version=\"1.0\" encoding=\"KOI8-R\"?><rss><channel><title>T</title></channel></rss>")
+	if _, err := Read(bytes.NewReader(data)); err == nil {
+		t.Errorf("Expected an error for an unsupported charset")
+	}
+}
+
+func TestUTF8BOM(t *testing.T) {
+	data := []byte("\xef\xbb\xbf<?xml version=\"1.0\" encoding=\"UTF-8\"?>\r\n" +
+		"<rss version=\"2.0\"><channel><title>Notepad</title>" +
+		"<item><title>Saved with a BOM</title></item>" +
+		"</channel></rss>\r\n")
+
+	f, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Title != "Notepad" {
+		t.Errorf("Expected title [Notepad], got [%s]", f.Title)
+	}
+	if len(f.Entries) != 1 || f.Entries[0].Title != "Saved with a BOM" {
+		t.Errorf("Expected one entry [Saved with a BOM], got %v", f.Entries)
+	}
+}
+
+func TestUTF16BOM(t *testing.T) {
+	for _, bom := range []string{"\xfe\xff", "\xff\xfe"} {
+		data := []byte(bom + "<\x00r\x00s\x00s\x00")
+		if _, err := Read(bytes.NewReader(data)); err != ErrUTF16 {
+			t.Errorf("Expected ErrUTF16 for BOM %q, got %v", bom, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package webfeed
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// A cancellingReader cancels a context once it has read past a point.
+type cancellingReader struct {
+	r      io.Reader
+	n      int
+	cancel context.CancelFunc
+}
+
+func (c *cancellingReader) Read(p []byte) (int, error) {
+	if len(p) > 16 {
+		p = p[:16]
+	}
+	n, err := c.r.Read(p)
+	c.n -= n
+	if c.n <= 0 {
+		c.cancel()
+	}
+	return n, err
+}
+
+func TestReadContext(t *testing.T) {
+	data := `<rss version="2.0"><channel><title>T</title>` +
+		strings.Repeat("<item><title>I</title></item>", 1000) +
+		`</channel></rss>`
+
+	f, err := ReadContext(context.Background(), strings.NewReader(data))
+	if err != nil || len(f.Entries) != 1000 {
+		t.Fatalf("Expected 1000 entries, got %d and error %v", len(f.Entries), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ReadContext(ctx, strings.NewReader(data)); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	r := &cancellingReader{r: strings.NewReader(data), n: len(data) / 2, cancel: cancel}
+	if _, err := ReadContext(ctx, r); err != context.Canceled {
+		t.Errorf("Expected context.Canceled part way through, got %v", err)
+	}
+	if r.n < -len(data)/4 {
+		t.Errorf("Expected reading to stop soon after cancellation, read %d bytes too many", -r.n)
+	}
+}
@@ -0,0 +1,271 @@
+package webfeed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// A Decoder reads the entries of a feed one at a time, without holding
+// the entire feed in memory. It reads RSS, RSS 1.0, and Atom feeds.
+type Decoder struct {
+	d   *xml.Decoder
+	err error
+
+	// Root is the root element of the document, or nil if it has not
+	// yet been read.
+	root *xml.StartElement
+	// Channel is the RSS channel element if the decoder is reading
+	// its children, or nil if not. SawChannel is true if the decoder
+	// has read an RSS channel.
+	channel    *xml.StartElement
+	sawChannel bool
+
+	// Meta holds the feed-level elements read so far. Its Entries and
+	// Items are always empty.
+	meta feed
+	// Header is the Feed for meta, and headerErr its BadTime, if any.
+	// They are recomputed when stale.
+	header    Feed
+	headerErr error
+	stale     bool
+
+	// Newest is the newest time of the entries read so far.
+	newest time.Time
+}
+
+// NewDecoder returns a new Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	d, err := newXMLDecoder(r)
+	return &Decoder{d: d, err: err, stale: true}
+}
+
+// Next returns the next entry of the feed. At the end of the feed, Next
+// returns io.EOF. If the document ends before the end of the feed, or
+// has no root element at all, Next returns io.ErrUnexpectedEOF. If the
+// root element is <html>, Next returns ErrNotAFeed.
+//
+// As with Read, if the entry's time is unparsable, Next returns the entry
+// along with the non-fatal error ErrBadTimes.
+func (d *Decoder) Next() (Entry, error) {
+	if d.err != nil {
+		return Entry{}, d.err
+	}
+	if err := d.readRoot(); err != nil {
+		d.err = err
+		return Entry{}, err
+	}
+	if isHTML(d.root.Name) {
+		d.err = ErrNotAFeed
+		return Entry{}, ErrNotAFeed
+	}
+	for {
+		t, err := d.d.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			d.err = err
+			return Entry{}, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			e, ok, err := d.element(t)
+			if err != nil {
+				d.err = err
+				return Entry{}, err
+			}
+			if ok {
+				return d.entry(e)
+			}
+		case xml.EndElement:
+			if d.channel != nil {
+				d.channel = nil
+				continue
+			}
+			d.err = io.EOF
+			return Entry{}, io.EOF
+		}
+	}
+}
+
+// Feed returns the feed-level information read so far, without entries.
+// Feed-level elements that follow the first entry are only included once
+// Next has read past them. If the feed gives no updated time, Updated is
+// the newest time of the entries read so far.
+func (d *Decoder) Feed() Feed {
+	f, _ := d.feed()
+	return f
+}
+
+// Format returns the format of the feed, or FormatUnknown if it is not
+// yet known.
+func (d *Decoder) Format() Format {
+	switch {
+	case d.root == nil:
+		return FormatUnknown
+	case d.root.Name.Local == "rss":
+		return FormatRSS
+	case d.root.Name.Local == "feed":
+		return FormatAtom
+	case d.root.Name.Local == "RDF":
+		return FormatRDF
+	case d.sawChannel:
+		return FormatRSS
+	}
+	return FormatUnknown
+}
+
+// ReadRoot reads up to the root element of the document, if it has not
+// already been read.
+func (d *Decoder) readRoot() error {
+	for d.root == nil {
+		t, err := d.d.Token()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		} else if err != nil {
+			return err
+		}
+		if se, ok := t.(xml.StartElement); ok {
+			se = se.Copy()
+			d.root = &se
+			d.meta.XMLName = se.Name
+			for _, a := range se.Attr {
+				switch a.Name.Local {
+				case "base":
+					d.meta.XmlBase = a.Value
+				case "lang":
+					d.meta.XmlLang = a.Value
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Element handles a child of the root or of the RSS channel. If the child
+// is an entry, it is returned with true.
+func (d *Decoder) element(se xml.StartElement) (interface{}, bool, error) {
+	format := d.Format()
+	switch {
+	case se.Name.Local == "item":
+		var it rssItem
+		err := d.d.DecodeElement(&it, &se)
+		return it, err == nil, err
+
+	case d.channel == nil && se.Name.Local == "entry" && format != FormatRSS && format != FormatRDF:
+		var ent atomEntry
+		err := d.d.DecodeElement(&ent, &se)
+		return ent, err == nil, err
+
+	case d.channel == nil && se.Name.Local == "channel" && format == FormatRDF:
+		// RSS 1.0 items are siblings of the channel, so the
+		// channel can be read all at once.
+		d.stale = true
+		return nil, false, d.d.DecodeElement(&d.meta.Rss, &se)
+
+	case d.channel == nil && se.Name.Local == "channel" && format != FormatAtom:
+		se = se.Copy()
+		d.channel = &se
+		d.sawChannel = true
+		d.stale = true
+		return nil, false, nil
+
+	case d.channel != nil:
+		d.stale = true
+		return nil, false, d.decodeInto(&d.meta.Rss, *d.channel, se)
+	}
+	d.stale = true
+	return nil, false, d.decodeInto(&d.meta, *d.root, se)
+}
+
+// DecodeInto decodes the element that begins with se into v as if it
+// were a child of the element parent. It is used to decode the
+// feed-level elements one at a time.
+func (d *Decoder) decodeInto(v interface{}, parent, se xml.StartElement) error {
+	toks := []xml.Token{xml.StartElement{Name: parent.Name, Attr: nonNamespaceAttrs(parent.Attr)}, se.Copy()}
+	for depth := 1; depth > 0; {
+		t, err := d.d.Token()
+		if err != nil {
+			return err
+		}
+		switch t.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+		toks = append(toks, xml.CopyToken(t))
+	}
+	toks = append(toks, xml.EndElement{Name: parent.Name})
+	return xml.NewTokenDecoder(&tokenReader{toks: toks}).Decode(v)
+}
+
+// NonNamespaceAttrs returns the attributes that are not namespace
+// declarations. The names of the decoder's tokens are already resolved,
+// so the declarations are not needed to decode them again.
+func nonNamespaceAttrs(attrs []xml.Attr) []xml.Attr {
+	var as []xml.Attr
+	for _, a := range attrs {
+		if a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns" {
+			continue
+		}
+		as = append(as, a)
+	}
+	return as
+}
+
+// A tokenReader is an xml.TokenReader that returns a slice of tokens.
+type tokenReader struct {
+	toks []xml.Token
+}
+
+func (r *tokenReader) Token() (xml.Token, error) {
+	if len(r.toks) == 0 {
+		return nil, io.EOF
+	}
+	t := r.toks[0]
+	r.toks = r.toks[1:]
+	return t, nil
+}
+
+// Feed returns the feed-level information read so far, along with a
+// BadTime error if the feed's time is unparsable.
+func (d *Decoder) feed() (Feed, error) {
+	if d.stale {
+		switch d.Format() {
+		case FormatRSS:
+			d.header, d.headerErr = rssHeader(d.meta.Rss)
+		case FormatRDF:
+			d.header, d.headerErr = rssHeader(d.meta.rdfChannel())
+		default:
+			d.header, d.headerErr = atomHeader(d.meta), nil
+		}
+		d.stale = false
+	}
+	f := d.header
+	if f.Updated.IsZero() {
+		f.Updated = d.newest
+	}
+	return f, d.headerErr
+}
+
+// Entry returns the Entry for an rssItem or atomEntry.
+func (d *Decoder) entry(v interface{}) (Entry, error) {
+	f, _ := d.feed()
+	var e Entry
+	var err error
+	switch v := v.(type) {
+	case rssItem:
+		e, err = rssEntry(v, f.Link)
+	case atomEntry:
+		e = v.entry(xmlBase(nil, d.meta.XmlBase), f.Link)
+	}
+	if e.When.After(d.newest) {
+		d.newest = e.When
+	}
+	if err != nil {
+		return e, ErrBadTimes{err.(BadTime)}
+	}
+	return e, nil
+}
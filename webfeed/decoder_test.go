@@ -0,0 +1,124 @@
+package webfeed
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const streamData = `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+	<title>Stream</title>
+	<link>http://example.com/</link>
+	<atom:link rel="self" href="http://example.com/feed"/>
+	<item><title>Newest</title><link>/3</link><pubDate>Mon, 03 Mar 2014 00:00:00 +0000</pubDate></item>
+	<item><title>Bad time</title><pubDate>yesterday</pubDate></item>
+	<item><title>Oldest</title><pubDate>Sat, 01 Mar 2014 00:00:00 +0000</pubDate></item>
+	<pubDate>Tue, 04 Mar 2014 00:00:00 +0000</pubDate>
+</channel>
+</rss>`
+
+func TestDecoderNext(t *testing.T) {
+	d := NewDecoder(strings.NewReader(streamData))
+	e, err := d.Next()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if e.Title != "Newest" || e.Link != "/3" {
+		t.Errorf("Expected the newest entry, got [%s] [%s]", e.Title, e.Link)
+	}
+	if d.Format() != FormatRSS {
+		t.Errorf("Expected format %s, got %s", FormatRSS, d.Format())
+	}
+	f := d.Feed()
+	if f.Title != "Stream" || f.Link != "http://example.com/" || f.Self != "http://example.com/feed" {
+		t.Errorf("Unexpected feed header %+v", f)
+	}
+	if f.Entries != nil {
+		t.Errorf("Expected no entries in the header, got %d", len(f.Entries))
+	}
+	// The channel's pubDate has not been read yet.
+	if exp := time.Date(2014, 3, 3, 0, 0, 0, 0, time.UTC); !f.Updated.Equal(exp) {
+		t.Errorf("Expected updated %s from the entries, got %s", exp, f.Updated)
+	}
+
+	e, err = d.Next()
+	if _, ok := err.(ErrBadTimes); !ok {
+		t.Errorf("Expected ErrBadTimes, got %v", err)
+	}
+	if e.Title != "Bad time" || !e.When.IsZero() {
+		t.Errorf("Expected the bad time entry with a zero time, got [%s] %s", e.Title, e.When)
+	}
+
+	if e, err = d.Next(); err != nil || e.Title != "Oldest" {
+		t.Errorf("Expected the oldest entry, got [%s] %v", e.Title, err)
+	}
+	if _, err = d.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+	if _, err = d.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF again, got %v", err)
+	}
+	if exp := time.Date(2014, 3, 4, 0, 0, 0, 0, time.UTC); !d.Feed().Updated.Equal(exp) {
+		t.Errorf("Expected updated %s from the channel, got %s", exp, d.Feed().Updated)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name, data string
+	}{
+		{"empty", ""},
+		{"truncated", `<rss version="2.0"><channel><title>T</title><item><title>I`},
+		{"unclosed", `<rss version="2.0"><channel><title>T</title><item><title>I</title></item>`},
+	}
+	for _, test := range tests {
+		d := NewDecoder(strings.NewReader(test.data))
+		var err error
+		for err == nil {
+			_, err = d.Next()
+		}
+		if err == io.EOF {
+			t.Errorf("%s: expected an error, got io.EOF", test.name)
+		}
+		if _, err := Read(strings.NewReader(test.data)); err == nil {
+			t.Errorf("%s: expected Read to return an error", test.name)
+		}
+	}
+}
+
+// TestReadMatchesReadAll checks that Read, which streams, gives the same
+// result as ReadAll, which does not.
+func TestReadMatchesReadAll(t *testing.T) {
+	docs := []string{
+		streamData,
+		podcastData,
+		applePodcastData,
+		rdfFeed,
+		atomDoc1,
+		atomDoc2,
+		`<feed xmlns="http://www.w3.org/2005/Atom" xml:base="http://example.com/"><title>T</title>
+<link href="/"/><author><name>A</name></author>
+<entry xml:base="blog/"><title>E</title><link href="1"/><summary type="html">&lt;img src="i.png"&gt;</summary></entry></feed>`,
+	}
+	for i, doc := range docs {
+		f, err := Read(strings.NewReader(doc))
+		if _, ok := err.(ErrBadTimes); err != nil && !ok {
+			t.Errorf("%d: unexpected error: %s", i, err)
+			continue
+		}
+		fs, allErr := ReadAll(strings.NewReader(doc))
+		if len(fs) != 1 {
+			t.Errorf("%d: expected one feed from ReadAll, got %d: %v", i, len(fs), allErr)
+			continue
+		}
+		if !reflect.DeepEqual(f, fs[0]) {
+			t.Errorf("%d: expected Read to give\n%+v\ngot\n%+v", i, fs[0], f)
+		}
+		if !reflect.DeepEqual(err, allErr) {
+			t.Errorf("%d: expected Read to return %v, got %v", i, allErr, err)
+		}
+	}
+}
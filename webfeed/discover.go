@@ -0,0 +1,86 @@
+package webfeed
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"code.google.com/p/go.net/html"
+)
+
+// FeedTypes are the MIME types of the feed formats that can be read.
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/rdf+xml":   true,
+	"application/feed+json": true,
+}
+
+// Discover returns the URLs of the feeds advertised by an HTML page with
+// <link rel="alternate"> (or <a rel="alternate">) elements, in document
+// order and without duplicates. Relative URLs are resolved against the
+// page's <base> element, if it has one, and base, which should be the URL
+// of the page.
+func Discover(r io.Reader, base string) ([]string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	if e := findElement(doc, "base"); e != nil {
+		if href, ok := attr(e, "href"); ok {
+			b = xmlBase(b, href)
+		}
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "link" || n.Data == "a") && isFeedLink(n) {
+			href, _ := attr(n, "href")
+			u := resolveURL(b, strings.TrimSpace(href))
+			if u != "" && !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+		for k := n.FirstChild; k != nil; k = k.NextSibling {
+			walk(k)
+		}
+	}
+	walk(doc)
+	return urls, nil
+}
+
+// IsFeedLink returns true if the element is an alternate link to a feed.
+func isFeedLink(n *html.Node) bool {
+	rel, _ := attr(n, "rel")
+	typ, _ := attr(n, "type")
+	if i := strings.Index(typ, ";"); i >= 0 {
+		typ = typ[:i]
+	}
+	if !feedTypes[strings.ToLower(strings.TrimSpace(typ))] {
+		return false
+	}
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		if r == "alternate" {
+			return true
+		}
+	}
+	return false
+}
+
+// Attr returns the value of the named attribute of an element, and
+// whether it was present.
+func attr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
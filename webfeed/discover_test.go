@@ -0,0 +1,56 @@
+package webfeed
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name, page, base string
+		urls             []string
+	}{
+		{
+			name: "multiple",
+			page: `<!DOCTYPE html><html><head>
+<title>A Blog</title>
+<link rel="stylesheet" href="/style.css" type="text/css">
+<link rel="alternate" type="application/rss+xml" title="RSS" href="/feed.rss">
+<link rel="alternate" type="application/atom+xml" title="Atom" href="https://example.com/feed.atom">
+<link rel="alternate" type="application/rss+xml" title="Comments" href="comments/feed/">
+<link rel="alternate" type="text/html" hreflang="fr" href="/fr/">
+<link rel="Alternate" type="Application/RSS+XML; charset=utf-8" href="/feed.rss">
+</head><body><p>Hello</p></body></html>`,
+			base: "https://example.com/blog/",
+			urls: []string{
+				"https://example.com/feed.rss",
+				"https://example.com/feed.atom",
+				"https://example.com/blog/comments/feed/",
+			},
+		},
+		{
+			name: "base element",
+			page: `<html><head><base href="https://cdn.example.com/site/">
+<link rel="alternate" type="application/atom+xml" href="atom.xml"></head></html>`,
+			base: "https://example.com/",
+			urls: []string{"https://cdn.example.com/site/atom.xml"},
+		},
+		{
+			name: "none",
+			page: `<html><head><title>No feeds</title><link rel="icon" href="/favicon.ico"></head><body></body></html>`,
+			base: "https://example.com/",
+		},
+	}
+
+	for _, test := range tests {
+		urls, err := Discover(strings.NewReader(test.page), test.base)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(urls, test.urls) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.urls, urls)
+		}
+	}
+}
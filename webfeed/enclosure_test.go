@@ -0,0 +1,99 @@
+package webfeed
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRssEnclosures(t *testing.T) {
+	const data = `<rss version="2.0"><channel><title>Podcast</title>
+<item>
+	<title>Episode 1</title>
+	<enclosure url="http://x/1.mp3" type="audio/mpeg" length="12345678"/>
+</item>
+<item>
+	<title>Episode 2</title>
+	<enclosure url="http://x/2.mp3" type="audio/mpeg" length=""/>
+	<enclosure url="http://x/2.ogg" type="audio/ogg" length="bogus"/>
+</item>
+<item>
+	<title>No enclosure</title>
+</item>
+</channel></rss>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	exp := [][]Enclosure{
+		{{URL: "http://x/1.mp3", Type: "audio/mpeg", Length: 12345678}},
+		{{URL: "http://x/2.mp3", Type: "audio/mpeg"}, {URL: "http://x/2.ogg", Type: "audio/ogg"}},
+		nil,
+	}
+	for i, e := range f.Entries {
+		if !reflect.DeepEqual(e.Enclosures, exp[i]) {
+			t.Errorf("Expected entry %d to have enclosures %v, got %v", i, exp[i], e.Enclosures)
+		}
+	}
+}
+
+func TestAtomEnclosures(t *testing.T) {
+	const data = `<feed xmlns="http://www.w3.org/2005/Atom"><title>Podcast</title>
+<entry>
+	<title>Episode 1</title>
+	<link rel="enclosure" href="http://x/1.mp3" type="audio/mpeg" length="1234"/>
+	<link rel="alternate" href="http://x/1"/>
+</entry>
+<entry>
+	<title>Episode 2</title>
+	<link href="http://x/2"/>
+</entry>
+</feed>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	e := f.Entries[0]
+	if e.Link != "http://x/1" {
+		t.Errorf("Expected link [http://x/1], got [%s]", e.Link)
+	}
+	exp := []Enclosure{{URL: "http://x/1.mp3", Type: "audio/mpeg", Length: 1234}}
+	if !reflect.DeepEqual(e.Enclosures, exp) {
+		t.Errorf("Expected enclosures %v, got %v", exp, e.Enclosures)
+	}
+
+	e = f.Entries[1]
+	if e.Link != "http://x/2" {
+		t.Errorf("Expected link [http://x/2], got [%s]", e.Link)
+	}
+	if e.Enclosures != nil {
+		t.Errorf("Expected no enclosures, got %v", e.Enclosures)
+	}
+}
+
+func TestAtomEntryLink(t *testing.T) {
+	tests := []struct {
+		name  string
+		links string
+		link  string
+	}{
+		{"alternate", `<link rel="related" href="http://x/related"/><link rel="alternate" href="http://x/1"/>`, "http://x/1"},
+		{"no rel", `<link rel="related" href="http://x/related"/><link href="http://x/1"/>`, "http://x/1"},
+		{"only related", `<link rel="enclosure" href="http://x/1.mp3"/><link rel="related" href="http://x/related"/>`, "http://x/related"},
+		{"only enclosure", `<link rel="enclosure" href="http://x/1.mp3"/>`, "http://x/1.mp3"},
+		{"none", ``, ""},
+	}
+	for _, test := range tests {
+		data := `<feed xmlns="http://www.w3.org/2005/Atom"><title>Feed</title><entry><title>1</title>` +
+			test.links + `</entry></feed>`
+		f, err := Read(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+		if l := f.Entries[0].Link; l != test.link {
+			t.Errorf("%s: expected link [%s], got [%s]", test.name, test.link, l)
+		}
+	}
+}
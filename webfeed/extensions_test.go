@@ -0,0 +1,98 @@
+package webfeed
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRssExtensions(t *testing.T) {
+	const data = `<?xml version="1.0"?>
+<rss version="2.0"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:slash="http://purl.org/rss/1.0/modules/slash/"
+	xmlns:custom="http://example.com/custom">
+<channel>
+<title>Extensions</title>
+<item>
+	<title>Item</title>
+	<link>http://example.com/item</link>
+	<comments>http://example.com/item#comments</comments>
+	<content:encoded>&lt;p&gt;Content&lt;/p&gt;</content:encoded>
+	<slash:department>stuff-that-matters</slash:department>
+	<slash:comments>42</slash:comments>
+	<custom:tag>one</custom:tag>
+	<custom:tag>two</custom:tag>
+</item>
+<item>
+	<title>Plain</title>
+</item>
+</channel>
+</rss>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	exp := map[string][]string{
+		"slash:department":              {"stuff-that-matters"},
+		"slash:comments":                {"42"},
+		"http://example.com/custom:tag": {"one", "two"},
+	}
+	if ext := f.Entries[0].Extensions; !reflect.DeepEqual(ext, exp) {
+		t.Errorf("Expected extensions %v, got %v", exp, ext)
+	}
+	if c := string(f.Entries[0].Content); c != "<p>Content</p>" {
+		t.Errorf("Expected content [<p>Content</p>], got [%s]", c)
+	}
+	if ext := f.Entries[1].Extensions; ext != nil {
+		t.Errorf("Expected no extensions, got %v", ext)
+	}
+}
+
+func TestAtomExtensions(t *testing.T) {
+	const data = `<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<title>Extensions</title>
+<entry>
+	<title>Entry</title>
+	<rights>Not an extension</rights>
+	<dc:subject>Go</dc:subject>
+</entry>
+</feed>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	exp := map[string][]string{"dc:subject": {"Go"}}
+	if ext := f.Entries[0].Extensions; !reflect.DeepEqual(ext, exp) {
+		t.Errorf("Expected extensions %v, got %v", exp, ext)
+	}
+}
+
+func TestNestedExtensions(t *testing.T) {
+	const data = `<?xml version="1.0"?>
+<rss version="2.0" xmlns:custom="http://example.com/custom">
+<channel>
+<title>Extensions</title>
+<item>
+	<title>Item</title>
+	<custom:rating><custom:value>5</custom:value> stars</custom:rating>
+	<custom:note><![CDATA[<b>bold</b>]]></custom:note>
+</item>
+</channel>
+</rss>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	exp := map[string][]string{
+		"http://example.com/custom:rating": {"5 stars"},
+		"http://example.com/custom:note":   {"<b>bold</b>"},
+	}
+	if ext := f.Entries[0].Extensions; !reflect.DeepEqual(ext, exp) {
+		t.Errorf("Expected extensions %v, got %v", exp, ext)
+	}
+}
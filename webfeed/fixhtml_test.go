@@ -1,6 +1,9 @@
 package webfeed
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestFixHtml(t *testing.T) {
 	tests := []struct {
@@ -11,6 +14,11 @@ func TestFixHtml(t *testing.T) {
 		{"<div></div></div>", "<div></div>"},
 		{"</foo>", ""},
 		{"</foo>bar<div>baz</div>", "bar<div>baz</div>"},
+		{"before</body>after", "beforeafter"},
+		{"<p>one</p></body></html><p>two</p>", "<p>one</p><p>two</p>"},
+		{"<body><p>nested</p><body>again</body></body>", "<p>nested</p>again"},
+		{"<pre>&lt;/body&gt; is literal</pre>", "<pre>&lt;/body&gt; is literal</pre>"},
+		{`<p>a</p><script>document.write("</body>")</script><p>kept</p>`, `<p>a</p><script>document.write("</body>")</script><p>kept</p>`},
 	}
 
 	for _, test := range tests {
@@ -20,3 +28,17 @@ func TestFixHtml(t *testing.T) {
 		}
 	}
 }
+
+func TestStrayCloseBody(t *testing.T) {
+	const data = `<rss version="2.0"><channel><title>T</title><item><title>I</title>
+<description><![CDATA[<p>Use <code>&lt;/body&gt;</code> to close.</p><script>var s = "</body>";</script><p>The rest of the post.</p>]]></description>
+</item></channel></rss>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if s := string(f.Entries[0].Summary); !strings.Contains(s, "<p>The rest of the post.</p>") {
+		t.Errorf("Expected the content after the </body> literal to be kept, got [%s]", s)
+	}
+}
@@ -0,0 +1,110 @@
+package webfeed
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHubs(t *testing.T) {
+	tests := []struct {
+		name, data string
+		hubs       []string
+	}{
+		{
+			name: "RSS",
+			data: `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>T</title>
+<link>http://example.com/</link>
+<atom:link rel="self" href="http://example.com/feed"/>
+<atom:link rel="hub" href="http://pubsubhubbub.appspot.com/"/>
+</channel></rss>`,
+			hubs: []string{"http://pubsubhubbub.appspot.com/"},
+		},
+		{
+			name: "RSS 1.0",
+			data: `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel rdf:about="http://example.com/"><title>T</title><link>http://example.com/</link>
+<atom:link rel="hub" href="http://hub.example.com/"/></channel>
+</rdf:RDF>`,
+			hubs: []string{"http://hub.example.com/"},
+		},
+		{
+			name: "Atom",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title>
+<link rel="self" href="http://example.com/feed"/>
+<link rel="hub" href="http://hub.example.com/"/>
+<link rel="hub" href="http://other.example.com/hub"/>
+<link rel="hub" href="http://hub.example.com/"/>
+</feed>`,
+			hubs: []string{"http://hub.example.com/", "http://other.example.com/hub"},
+		},
+		{
+			name: "Atom relative",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom" xml:base="http://example.com/blog/"><title>T</title>
+<link rel="hub" href="../hub"/>
+</feed>`,
+			hubs: []string{"http://example.com/hub"},
+		},
+		{
+			name: "JSON",
+			data: `{"version": "https://jsonfeed.org/version/1.1", "title": "T",
+"hubs": [{"type": "WebSub", "url": "http://hub.example.com/"}, {"type": "rssCloud", "url": "http://cloud.example.com/"}]}`,
+			hubs: []string{"http://hub.example.com/"},
+		},
+		{
+			name: "none",
+			data: `<rss version="2.0"><channel><title>T</title></channel></rss>`,
+		},
+	}
+	for _, test := range tests {
+		f, err := ReadAny(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(f.Hubs, test.hubs) {
+			t.Errorf("%s: expected hubs %v, got %v", test.name, test.hubs, f.Hubs)
+		}
+	}
+}
+
+func TestDecoderHubs(t *testing.T) {
+	const data = `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title>
+<link rel="hub" href="http://hub.example.com/"/>
+<entry><title>1</title></entry>
+</feed>`
+	d := NewDecoder(strings.NewReader(data))
+	if _, err := d.Next(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if hubs := d.Feed().Hubs; !reflect.DeepEqual(hubs, []string{"http://hub.example.com/"}) {
+		t.Errorf("Expected hubs [http://hub.example.com/], got %v", hubs)
+	}
+}
+
+func TestWriteHubs(t *testing.T) {
+	f := Feed{Title: "T", Link: "http://example.com/", Self: "http://example.com/feed", Hubs: []string{"http://hub.example.com/"}}
+	writers := []struct {
+		name  string
+		write func(Feed, *bytes.Buffer) error
+	}{
+		{"Atom", func(f Feed, b *bytes.Buffer) error { return f.WriteAtom(b) }},
+		{"RSS", func(f Feed, b *bytes.Buffer) error { return f.WriteRSS(b) }},
+	}
+	for _, w := range writers {
+		var buf bytes.Buffer
+		if err := w.write(f, &buf); err != nil {
+			t.Errorf("%s: unexpected error: %s", w.name, err)
+			continue
+		}
+		g, err := Read(&buf)
+		if err != nil {
+			t.Errorf("%s: unexpected error rereading: %s", w.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(g.Hubs, f.Hubs) {
+			t.Errorf("%s: expected hubs %v, got %v", w.name, f.Hubs, g.Hubs)
+		}
+	}
+}
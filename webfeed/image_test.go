@@ -0,0 +1,62 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFeedImage(t *testing.T) {
+	tests := []struct {
+		name, data  string
+		image, icon string
+	}{
+		{
+			name: "RSS",
+			data: `<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><channel>
+<title>T</title><link>http://example.com/</link>
+<itunes:image href="http://example.com/artwork.png"/>
+<image><url> http://example.com/logo.png </url><title>T</title><link>http://example.com/</link></image>
+<item><title>I</title></item>
+</channel></rss>`,
+			image: "http://example.com/logo.png",
+		},
+		{
+			name: "RSS 1.0",
+			data: `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/">
+<channel rdf:about="http://example.com/"><title>T</title><image rdf:resource="http://example.com/logo.png"/></channel>
+<image rdf:about="http://example.com/logo.png"><url>http://example.com/logo.png</url></image>
+<item rdf:about="http://example.com/1"><title>I</title></item>
+</rdf:RDF>`,
+			image: "http://example.com/logo.png",
+		},
+		{
+			name: "Atom",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom" xml:base="http://example.com/">
+<title>T</title><logo>/logo.png</logo><icon>favicon.ico</icon>
+<entry><title>E</title></entry>
+</feed>`,
+			image: "http://example.com/logo.png",
+			icon:  "http://example.com/favicon.ico",
+		},
+		{
+			name: "none",
+			data: `<rss version="2.0"><channel><title>T</title><item><title>I</title></item></channel></rss>`,
+		},
+	}
+	for _, test := range tests {
+		f, err := Read(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if f.Image != test.image {
+			t.Errorf("%s: expected image [%s], got [%s]", test.name, test.image, f.Image)
+		}
+		if f.Icon != test.icon {
+			t.Errorf("%s: expected icon [%s], got [%s]", test.name, test.icon, f.Icon)
+		}
+		if fs, _ := ReadAll(strings.NewReader(test.data)); len(fs) != 1 || fs[0].Image != test.image {
+			t.Errorf("%s: expected ReadAll to give image [%s], got %+v", test.name, test.image, fs)
+		}
+	}
+}
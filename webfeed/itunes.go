@@ -0,0 +1,68 @@
+package webfeed
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Podcast holds the iTunes podcast information of a feed or an entry.
+// Episode, Season, and Duration are only given for entries.
+type Podcast struct {
+	// Title is the iTunes title, which for an episode usually omits
+	// the episode number.
+	Title  string
+	Author string
+	// Image is the URL of the artwork for the podcast or episode.
+	Image string
+	// Episode and Season are the episode and season numbers, or 0 if
+	// they are not given.
+	Episode, Season int
+	// Duration is the length of the episode, or 0 if it is unknown.
+	Duration time.Duration
+}
+
+// ItunesElements are the iTunes elements that can appear in a channel
+// or an item. It is embedded in rss and rssItem.
+type itunesElements struct {
+	ItunesTitle    string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd title"`
+	ItunesAuthor   string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+	ItunesImage    itunesImage `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+	ItunesEpisode  string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	ItunesSeason   string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+	ItunesDuration string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+func (it itunesElements) podcast() Podcast {
+	return Podcast{
+		Title:    strings.TrimSpace(it.ItunesTitle),
+		Author:   strings.TrimSpace(it.ItunesAuthor),
+		Image:    strings.TrimSpace(it.ItunesImage.Href),
+		Episode:  parseNonNegInt(it.ItunesEpisode),
+		Season:   parseNonNegInt(it.ItunesSeason),
+		Duration: parseItunesDuration(it.ItunesDuration),
+	}
+}
+
+// ParseItunesDuration returns the duration given by an itunes:duration
+// element, either as a number of seconds or as HH:MM:SS or MM:SS. Zero
+// is returned if the duration is missing or malformed.
+func parseItunesDuration(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	var secs float64
+	for _, f := range strings.Split(s, ":") {
+		n, err := strconv.ParseFloat(f, 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+		secs = secs*60 + n
+	}
+	return time.Duration(secs * float64(time.Second))
+}
@@ -0,0 +1,138 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const podcastData = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel>
+<title>A Finished Podcast</title>
+<link>http://example.com/podcast</link>
+<itunes:block>Yes</itunes:block>
+<itunes:complete>yes</itunes:complete>
+<item>
+	<title>The Last Episode</title>
+	<link>http://example.com/podcast/last</link>
+	<pubDate>Mon, 2 Jan 2006 15:04:05 -0700</pubDate>
+</item>
+</channel>
+</rss>`
+
+func TestItunesBlockComplete(t *testing.T) {
+	f, err := Read(strings.NewReader(podcastData))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !f.Blocked {
+		t.Errorf("Expected the feed to be blocked")
+	}
+	if !f.Complete {
+		t.Errorf("Expected the feed to be complete")
+	}
+
+	f, err = Read(strings.NewReader(strings.Replace(podcastData, "<itunes:complete>yes", "<itunes:complete>no", 1)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Complete {
+		t.Errorf("Expected the feed not to be complete")
+	}
+}
+
+const applePodcastData = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel>
+	<title>Hiking Treks</title>
+	<link>https://www.apple.com/itunes/podcasts/</link>
+	<itunes:author>The Sunset Explorers</itunes:author>
+	<itunes:image href="https://applehosted.podcasts.apple.com/hiking_treks/artwork.png"/>
+	<itunes:type>serial</itunes:type>
+	<item>
+		<itunes:episodeType>trailer</itunes:episodeType>
+		<itunes:title>Hiking Treks Trailer</itunes:title>
+		<title>S1 Trailer: Hiking Treks</title>
+		<enclosure length="498537" type="audio/mpeg" url="http://example.com/podcasts/everything/AllAboutEverythingEpisode4.mp3"/>
+		<guid>D03EEC9B-B1B4-475B-92C8-54F853FA2A22</guid>
+		<pubDate>Tue, 8 Jan 2019 01:15:00 GMT</pubDate>
+		<itunes:duration>1079</itunes:duration>
+		<itunes:explicit>false</itunes:explicit>
+	</item>
+	<item>
+		<itunes:episodeType>full</itunes:episodeType>
+		<itunes:episode>4</itunes:episode>
+		<itunes:season>2</itunes:season>
+		<title>S02 EP04 Mt. Hood, Oregon</title>
+		<itunes:author>Sunset Explorers Guest</itunes:author>
+		<itunes:image href="https://applehosted.podcasts.apple.com/hiking_treks/ep4.png"/>
+		<guid>22BCFEBF-44FB-4A19-8A3C-9E63F4A4EA8C</guid>
+		<pubDate>Tue, 07 May 2019 12:00:00 GMT</pubDate>
+		<itunes:duration>01:04:02</itunes:duration>
+	</item>
+	<item>
+		<title>No iTunes</title>
+	</item>
+</channel>
+</rss>`
+
+func TestItunesPodcast(t *testing.T) {
+	f, err := Read(strings.NewReader(applePodcastData))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Podcast.Author != "The Sunset Explorers" {
+		t.Errorf("Expected feed author [The Sunset Explorers], got [%s]", f.Podcast.Author)
+	}
+	if f.Podcast.Image != "https://applehosted.podcasts.apple.com/hiking_treks/artwork.png" {
+		t.Errorf("Unexpected feed image [%s]", f.Podcast.Image)
+	}
+
+	tests := []Podcast{
+		{Title: "Hiking Treks Trailer", Duration: 1079 * time.Second},
+		{
+			Author:   "Sunset Explorers Guest",
+			Image:    "https://applehosted.podcasts.apple.com/hiking_treks/ep4.png",
+			Episode:  4,
+			Season:   2,
+			Duration: time.Hour + 4*time.Minute + 2*time.Second,
+		},
+		{},
+	}
+	if len(f.Entries) != len(tests) {
+		t.Fatalf("Expected %d entries, got %d", len(tests), len(f.Entries))
+	}
+	for i, p := range tests {
+		if f.Entries[i].Podcast != p {
+			t.Errorf("Expected entry %d podcast %+v, got %+v", i, p, f.Entries[i].Podcast)
+		}
+	}
+	if title := f.Entries[0].Title; title != "S1 Trailer: Hiking Treks" {
+		t.Errorf("Expected the itunes:title not to replace the title, got [%s]", title)
+	}
+	if as := f.Entries[1].Authors; len(as) != 0 {
+		t.Errorf("Expected the itunes:author not to be an entry author, got %v", as)
+	}
+}
+
+func TestParseItunesDuration(t *testing.T) {
+	tests := []struct {
+		in  string
+		out time.Duration
+	}{
+		{"", 0},
+		{"3600", time.Hour},
+		{" 90 ", 90 * time.Second},
+		{"5:30", 5*time.Minute + 30*time.Second},
+		{"01:02:03", time.Hour + 2*time.Minute + 3*time.Second},
+		{"1:02:03.5", time.Hour + 2*time.Minute + 3500*time.Millisecond},
+		{"1 hour", 0},
+		{"-5", 0},
+	}
+	for _, test := range tests {
+		if d := parseItunesDuration(test.in); d != test.out {
+			t.Errorf("Expected [%s] to parse to %s, got %s", test.in, test.out, d)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package webfeed
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"code.google.com/p/go.net/html"
+)
+
+// ReadJSON reads a JSON Feed (https://jsonfeed.org/) from an io.Reader.
+//
+// As with Read, ReadJSON may return the non-fatal error ErrBadTimes if
+// some of the item dates are not valid RFC 3339 times.
+func ReadJSON(r io.Reader) (Feed, error) {
+	var jf jsonFeed
+	if err := json.NewDecoder(r).Decode(&jf); err != nil {
+		return Feed{}, err
+	}
+	return jf.feed()
+}
+
+// ReadAny reads either a JSON Feed or an XML feed from an io.Reader,
+// depending on whether its first non-space character is a '{'.
+func ReadAny(r io.Reader) (Feed, error) {
+	r, err := skipBOM(r)
+	if err != nil {
+		return Feed{}, err
+	}
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			// Let Read report the error for an empty document.
+			return Read(br)
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+			continue
+		case '{':
+			return ReadJSON(br)
+		}
+		return Read(br)
+	}
+}
+
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url"`
+	FeedURL     string     `json:"feed_url"`
+	Hubs        []jsonHub  `json:"hubs"`
+	Items       []jsonItem `json:"items"`
+
+	// Author is from version 1.0; version 1.1 uses Authors.
+	Author  *jsonAuthor  `json:"author"`
+	Authors []jsonAuthor `json:"authors"`
+}
+
+type jsonItem struct {
+	// ID is a string, but some publishers use numbers.
+	ID            json.RawMessage  `json:"id"`
+	URL           string           `json:"url"`
+	ExternalURL   string           `json:"external_url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	ContentText   string           `json:"content_text"`
+	Summary       string           `json:"summary"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Attachments   []jsonAttachment `json:"attachments"`
+	Tags          []string         `json:"tags"`
+
+	Author  *jsonAuthor  `json:"author"`
+	Authors []jsonAuthor `json:"authors"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+// JsonAuthors returns the names of the authors, whether given by the
+// version 1.0 author or the version 1.1 authors.
+func jsonAuthors(author *jsonAuthor, as []jsonAuthor) []string {
+	var names []string
+	if author != nil {
+		names = append(names, author.Name)
+	}
+	for _, a := range as {
+		names = append(names, a.Name)
+	}
+	return authors(names)
+}
+
+type jsonHub struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// JsonHubs returns the URLs of the WebSub hubs, without duplicates.
+func jsonHubs(hs []jsonHub) []string {
+	var hubs []string
+	seen := make(map[string]bool)
+	for _, h := range hs {
+		u := strings.TrimSpace(h.URL)
+		if u == "" || seen[u] || !strings.EqualFold(strings.TrimSpace(h.Type), "WebSub") {
+			continue
+		}
+		seen[u] = true
+		hubs = append(hubs, u)
+	}
+	return hubs
+}
+
+type jsonAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes"`
+}
+
+func (jf jsonFeed) feed() (Feed, error) {
+	var badTimes ErrBadTimes
+	f := Feed{
+		Title:   strings.TrimSpace(jf.Title),
+		Link:    strings.TrimSpace(jf.HomePageURL),
+		Self:    strings.TrimSpace(jf.FeedURL),
+		Hubs:    jsonHubs(jf.Hubs),
+		Authors: jsonAuthors(jf.Author, jf.Authors),
+	}
+	for _, it := range jf.Items {
+		e := Entry{
+			ID:         it.id(),
+			Title:      strings.TrimSpace(it.Title),
+			Link:       strings.TrimSpace(firstNonEmpty(it.URL, it.ExternalURL)),
+			Authors:    jsonAuthors(it.Author, it.Authors),
+			Categories: categories(it.Tags),
+		}
+		if it.Summary != "" {
+			e.Summary = []byte(html.EscapeString(strings.TrimSpace(it.Summary)))
+		}
+		if it.ContentHTML != "" {
+			e.Content = resolveURLs(f.Link, fixHtml([]byte(it.ContentHTML)))
+		} else if it.ContentText != "" {
+			e.Content = []byte(html.EscapeString(it.ContentText))
+		}
+		for _, d := range []struct {
+			s string
+			t *time.Time
+		}{
+			{it.DatePublished, &e.Published},
+			{it.DateModified, &e.When},
+		} {
+			t, err := jsonTime(d.s)
+			if err != nil {
+				badTimes = append(badTimes, BadTime{Title: e.Title, Time: err.(ErrBadTime)})
+			}
+			*d.t = t
+		}
+		if e.When.IsZero() {
+			e.When = e.Published
+		}
+		if e.ID == "" {
+			e.ID = e.Link
+		}
+		for _, a := range it.Attachments {
+			e.Enclosures = append(e.Enclosures, Enclosure{
+				URL:    strings.TrimSpace(a.URL),
+				Type:   strings.TrimSpace(a.MimeType),
+				Length: a.SizeInBytes,
+			})
+		}
+		e.setTruncated()
+		f.Entries = append(f.Entries, e)
+	}
+	f.setUpdated()
+	if len(badTimes) > 0 {
+		return f, badTimes
+	}
+	return f, nil
+}
+
+// JsonTime parses an RFC 3339 time. If the string is empty, the zero
+// time is returned. If the string could not be parsed then the zero time
+// is returned with an ErrBadTime error.
+func jsonTime(s string) (time.Time, error) {
+	if strings.TrimSpace(s) == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, ErrBadTime(s)
+	}
+	return t, nil
+}
+
+// Id returns the item's id as a string, whether it was given as a JSON
+// string or a number.
+func (it jsonItem) id() string {
+	var s string
+	if err := json.Unmarshal(it.ID, &s); err == nil {
+		return strings.TrimSpace(s)
+	}
+	return string(bytes.TrimSpace(it.ID))
+}
@@ -0,0 +1,107 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// JsonFeedData is an excerpt of the feed at https://jsonfeed.org/feed.json.
+const jsonFeedData = `{
+	"version": "https://jsonfeed.org/version/1.1",
+	"title": "JSON Feed",
+	"icon": "https://jsonfeed.org/graphics/icon.png",
+	"home_page_url": "https://jsonfeed.org/",
+	"feed_url": "https://jsonfeed.org/feed.json",
+	"items": [
+		{
+			"id": "http://jsonfeed.micro.blog/2020/08/07/json-feed-version.html",
+			"title": "JSON Feed version 1.1",
+			"content_html": "<p>We’ve updated the spec to <a href=\"/version/1.1/\">version 1.1</a>.</p>",
+			"date_published": "2020-08-07T11:44:36-05:00",
+			"url": "https://jsonfeed.org/2020/08/07/json-feed-version.html"
+		},
+		{
+			"id": 2,
+			"title": "Announcing JSON Feed",
+			"summary": "A format similar to RSS & Atom",
+			"content_text": "We — Manton Reece and Brent Simmons — have noticed...",
+			"date_published": "2017-05-17T10:02:12-05:00",
+			"url": "https://jsonfeed.org/2017/05/17/announcing_json_feed",
+			"attachments": [
+				{"url": "https://jsonfeed.org/a.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 1234}
+			]
+		}
+	]
+}`
+
+func TestReadJSON(t *testing.T) {
+	f, err := ReadJSON(strings.NewReader(jsonFeedData))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Title != "JSON Feed" || f.Link != "https://jsonfeed.org/" || f.Self != "https://jsonfeed.org/feed.json" {
+		t.Errorf("Unexpected feed: %+v", f)
+	}
+	if len(f.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(f.Entries))
+	}
+
+	e := f.Entries[0]
+	if e.ID != "http://jsonfeed.micro.blog/2020/08/07/json-feed-version.html" {
+		t.Errorf("Unexpected ID [%s]", e.ID)
+	}
+	exp := `<p>We’ve updated the spec to <a href="https://jsonfeed.org/version/1.1/">version 1.1</a>.</p>`
+	if string(e.Content) != exp {
+		t.Errorf("Expected content [%s], got [%s]", exp, e.Content)
+	}
+	if w := time.Date(2020, 8, 7, 16, 44, 36, 0, time.UTC); !e.When.Equal(w) {
+		t.Errorf("Expected time %s, got %s", w, e.When)
+	}
+
+	e = f.Entries[1]
+	if e.ID != "2" {
+		t.Errorf("Expected numeric ID [2], got [%s]", e.ID)
+	}
+	if string(e.Summary) != "A format similar to RSS &amp; Atom" {
+		t.Errorf("Unexpected summary [%s]", e.Summary)
+	}
+	if len(e.Content) == 0 {
+		t.Errorf("Expected content_text to be used for content")
+	}
+	if len(e.Enclosures) != 1 || e.Enclosures[0].Length != 1234 {
+		t.Errorf("Unexpected enclosures %v", e.Enclosures)
+	}
+}
+
+func TestReadJSONBadTime(t *testing.T) {
+	const data = `{"version": "https://jsonfeed.org/version/1.1", "items": [{"id": "1", "title": "T", "date_published": "yesterday"}]}`
+	f, err := ReadJSON(strings.NewReader(data))
+	if _, ok := err.(ErrBadTimes); !ok {
+		t.Fatalf("Expected ErrBadTimes, got %v", err)
+	}
+	if len(f.Entries) != 1 || !f.Entries[0].When.IsZero() {
+		t.Errorf("Expected one entry with a zero time, got %v", f.Entries)
+	}
+}
+
+func TestReadAny(t *testing.T) {
+	tests := []struct {
+		data, title string
+	}{
+		{jsonFeedData, "JSON Feed"},
+		{"\xef\xbb\xbf\n  " + jsonFeedData, "JSON Feed"},
+		{`<rss version="2.0"><channel><title>RSS</title></channel></rss>`, "RSS"},
+		{"\n<feed xmlns=\"http://www.w3.org/2005/Atom\"><title>Atom</title></feed>", "Atom"},
+	}
+	for _, test := range tests {
+		f, err := ReadAny(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+			continue
+		}
+		if f.Title != test.title {
+			t.Errorf("Expected title [%s], got [%s]", test.title, f.Title)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLanguageRights(t *testing.T) {
+	tests := []struct {
+		name, data       string
+		language, rights string
+	}{
+		{
+			name: "RSS",
+			data: `<rss version="2.0"><channel><title>T</title>
+<language>en-us</language><copyright>Copyright 2014 Example</copyright>
+</channel></rss>`,
+			language: "en-us",
+			rights:   "Copyright 2014 Example",
+		},
+		{
+			name: "RSS 1.0",
+			data: `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel rdf:about="http://example.com/"><title>T</title><dc:language>fr</dc:language><dc:rights>CC BY</dc:rights></channel>
+</rdf:RDF>`,
+			language: "fr",
+			rights:   "CC BY",
+		},
+		{
+			name: "Atom",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="de"><title>T</title>
+<rights type="html">&amp;copy; 2014 &lt;b&gt;Example&lt;/b&gt;</rights>
+</feed>`,
+			language: "de",
+			rights:   "© 2014 Example",
+		},
+		{
+			name: "none",
+			data: `<rss version="2.0"><channel><title>T</title></channel></rss>`,
+		},
+		{
+			name: "Atom none",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title></feed>`,
+		},
+	}
+	for _, test := range tests {
+		f, err := Read(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if f.Language != test.language {
+			t.Errorf("%s: expected language [%s], got [%s]", test.name, test.language, f.Language)
+		}
+		if f.Rights != test.rights {
+			t.Errorf("%s: expected rights [%s], got [%s]", test.name, test.rights, f.Rights)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package webfeed
+
+import (
+	"strings"
+)
+
+// Media is an image, video, or other media object described with the
+// Media RSS <media:content> element.
+type Media struct {
+	URL string
+	// Type is the MIME type of the object.
+	Type string
+	// Width and Height are the dimensions of the object in pixels, or 0
+	// if they are unknown.
+	Width, Height int
+	// Medium is the kind of object: "image", "audio", "video",
+	// "document", or "executable". It may be empty.
+	Medium string
+}
+
+// A Thumbnail is an image representing an entry or a media object,
+// described with the Media RSS <media:thumbnail> element.
+type Thumbnail struct {
+	URL string
+	// Width and Height are the dimensions of the image in pixels, or 0
+	// if they are unknown.
+	Width, Height int
+}
+
+// MediaElements are the Media RSS elements that can appear in an item
+// or entry. It is embedded in rssItem and atomEntry.
+type mediaElements struct {
+	MediaContents   []mediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaThumbnails []mediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	MediaGroups     []mediaGroup     `xml:"http://search.yahoo.com/mrss/ group"`
+	// MediaTitle is not used, but it keeps <media:title> from being
+	// unmarshalled as the title of the item or entry.
+	MediaTitle string `xml:"http://search.yahoo.com/mrss/ title"`
+}
+
+// A mediaGroup groups alternative versions of the same media object.
+type mediaGroup struct {
+	Contents   []mediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnails []mediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type mediaContent struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Width  string `xml:"width,attr"`
+	Height string `xml:"height,attr"`
+	Medium string `xml:"medium,attr"`
+}
+
+type mediaThumbnail struct {
+	URL    string `xml:"url,attr"`
+	Width  string `xml:"width,attr"`
+	Height string `xml:"height,attr"`
+}
+
+// Media returns the media objects and thumbnails, including those
+// inside of groups.
+func (m mediaElements) media() ([]Media, []Thumbnail) {
+	contents := m.MediaContents
+	thumbs := m.MediaThumbnails
+	for _, g := range m.MediaGroups {
+		contents = append(contents, g.Contents...)
+		thumbs = append(thumbs, g.Thumbnails...)
+	}
+
+	var media []Media
+	for _, c := range contents {
+		if strings.TrimSpace(c.URL) == "" {
+			continue
+		}
+		media = append(media, Media{
+			URL:    strings.TrimSpace(c.URL),
+			Type:   strings.TrimSpace(c.Type),
+			Width:  parseNonNegInt(c.Width),
+			Height: parseNonNegInt(c.Height),
+			Medium: strings.TrimSpace(c.Medium),
+		})
+	}
+	var thumbnails []Thumbnail
+	for _, t := range thumbs {
+		if strings.TrimSpace(t.URL) == "" {
+			continue
+		}
+		thumbnails = append(thumbnails, Thumbnail{
+			URL:    strings.TrimSpace(t.URL),
+			Width:  parseNonNegInt(t.Width),
+			Height: parseNonNegInt(t.Height),
+		})
+	}
+	return media, thumbnails
+}
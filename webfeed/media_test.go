@@ -0,0 +1,77 @@
+package webfeed
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlickrMedia(t *testing.T) {
+	const data = `<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
+<channel><title>Uploads from someone</title>
+<item>
+	<title>Sunset</title>
+	<link>https://www.flickr.com/photos/someone/1/</link>
+	<description>A sunset</description>
+	<media:content url="https://live.staticflickr.com/1/1_b.jpg" type="image/jpeg" height="768" width="1024" medium="image"/>
+	<media:title>Sunset</media:title>
+	<media:thumbnail url="https://live.staticflickr.com/1/1_s.jpg" height="75" width="75"/>
+</item>
+<item><title>No media</title></item>
+</channel></rss>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	e := f.Entries[0]
+	media := []Media{{URL: "https://live.staticflickr.com/1/1_b.jpg", Type: "image/jpeg", Width: 1024, Height: 768, Medium: "image"}}
+	if !reflect.DeepEqual(e.Media, media) {
+		t.Errorf("Expected media %v, got %v", media, e.Media)
+	}
+	thumbs := []Thumbnail{{URL: "https://live.staticflickr.com/1/1_s.jpg", Width: 75, Height: 75}}
+	if !reflect.DeepEqual(e.Thumbnails, thumbs) {
+		t.Errorf("Expected thumbnails %v, got %v", thumbs, e.Thumbnails)
+	}
+	if _, ok := e.Extensions["media:content"]; ok {
+		t.Errorf("Expected media:content not to be an extension")
+	}
+	if e := f.Entries[1]; e.Media != nil || e.Thumbnails != nil {
+		t.Errorf("Expected no media, got %v and %v", e.Media, e.Thumbnails)
+	}
+}
+
+func TestYouTubeMedia(t *testing.T) {
+	const data = `<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns:media="http://search.yahoo.com/mrss/" xmlns="http://www.w3.org/2005/Atom">
+<title>A Channel</title>
+<entry>
+	<id>yt:video:abc123</id>
+	<title>A Video</title>
+	<link rel="alternate" href="https://www.youtube.com/watch?v=abc123"/>
+	<content type="html">The description</content>
+	<media:group>
+		<media:title>A Video</media:title>
+		<media:content url="https://www.youtube.com/v/abc123?version=3" type="application/x-shockwave-flash" width="640" height="390"/>
+		<media:thumbnail url="https://i1.ytimg.com/vi/abc123/hqdefault.jpg" width="480" height="360"/>
+		<media:description>The description</media:description>
+	</media:group>
+</entry>
+</feed>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	e := f.Entries[0]
+	media := []Media{{URL: "https://www.youtube.com/v/abc123?version=3", Type: "application/x-shockwave-flash", Width: 640, Height: 390}}
+	if !reflect.DeepEqual(e.Media, media) {
+		t.Errorf("Expected media %v, got %v", media, e.Media)
+	}
+	thumbs := []Thumbnail{{URL: "https://i1.ytimg.com/vi/abc123/hqdefault.jpg", Width: 480, Height: 360}}
+	if !reflect.DeepEqual(e.Thumbnails, thumbs) {
+		t.Errorf("Expected thumbnails %v, got %v", thumbs, e.Thumbnails)
+	}
+	if string(e.Content) != "The description" {
+		t.Errorf("Expected content [The description], got [%s]", e.Content)
+	}
+}
@@ -0,0 +1,51 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNotAFeed(t *testing.T) {
+	tests := []struct {
+		name, data string
+	}{
+		{"HTML", `<!DOCTYPE html>
+<html><head><title>A Blog</title>
+<link rel="alternate" type="application/rss+xml" href="/feed.xml"/>
+</head><body><p>Hello</p></body></html>`},
+		// Real web pages are rarely well-formed XML.
+		{"malformed HTML", `<!DOCTYPE html><HTML lang="en"><head><meta charset="utf-8"><br></head></HTML>`},
+		{"unknown root", `<document><title>T</title></document>`},
+	}
+	for _, test := range tests {
+		if _, err := Read(strings.NewReader(test.data)); err != ErrNotAFeed {
+			t.Errorf("%s: expected Read to return ErrNotAFeed, got %v", test.name, err)
+		}
+		if _, err := ReadAll(strings.NewReader(test.data)); err != ErrNotAFeed && test.name != "malformed HTML" {
+			t.Errorf("%s: expected ReadAll to return ErrNotAFeed, got %v", test.name, err)
+		}
+	}
+}
+
+func TestEmptyFeed(t *testing.T) {
+	tests := []struct {
+		name, data string
+		format     Format
+	}{
+		{"RSS", `<rss version="2.0"><channel><title>Empty</title></channel></rss>`, FormatRSS},
+		{"Atom", `<feed xmlns="http://www.w3.org/2005/Atom"><title>Empty</title></feed>`, FormatAtom},
+	}
+	for _, test := range tests {
+		f, format, err := ReadWithFormat(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if format != test.format {
+			t.Errorf("%s: expected format %s, got %s", test.name, test.format, format)
+		}
+		if f.Title != "Empty" || len(f.Entries) != 0 {
+			t.Errorf("%s: expected an empty feed titled [Empty], got %+v", test.name, f)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package webfeed
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxEntries(t *testing.T) {
+	items := ""
+	for i := 0; i < 10; i++ {
+		items += "<item><title>" + strconv.Itoa(i) + "</title><pubDate>bad " + strconv.Itoa(i) + "</pubDate></item>"
+	}
+	data := `<rss version="2.0"><channel><title>T</title>` + items + `</channel></rss>`
+
+	tests := []struct {
+		max, n int
+	}{
+		{0, 10},
+		{3, 3},
+		{10, 10},
+		{20, 10},
+	}
+	for _, test := range tests {
+		f, err := ReadWithOptions(strings.NewReader(data), ReadOptions{MaxEntries: test.max})
+		bts, ok := err.(ErrBadTimes)
+		if !ok {
+			t.Errorf("MaxEntries %d: expected ErrBadTimes, got %v", test.max, err)
+		}
+		if len(bts) != test.n {
+			t.Errorf("MaxEntries %d: expected %d bad times, got %d", test.max, test.n, len(bts))
+		}
+		if len(f.Entries) != test.n {
+			t.Errorf("MaxEntries %d: expected %d entries, got %d", test.max, test.n, len(f.Entries))
+			continue
+		}
+		for i, e := range f.Entries {
+			if e.Title != strconv.Itoa(i) {
+				t.Errorf("MaxEntries %d: expected entry %d to have title [%d], got [%s]", test.max, i, i, e.Title)
+			}
+		}
+	}
+
+	// A feed without its own update time is as new as its newest kept
+	// entry, not as the newer entries that were dropped.
+	data = `<rss version="2.0"><channel><title>T</title>
+<item><title>0</title><pubDate>Wed, 01 Jan 2020 00:00:00 GMT</pubDate></item>
+<item><title>1</title><pubDate>Sat, 01 Feb 2020 00:00:00 GMT</pubDate></item>
+</channel></rss>`
+	f, err := ReadWithOptions(strings.NewReader(data), ReadOptions{MaxEntries: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if exp := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC); !f.Updated.Equal(exp) {
+		t.Errorf("Expected the feed to be updated at %s, got %s", exp, f.Updated)
+	}
+}
+
+const dupData = `<rss version="2.0"><channel><title>T</title>
+<item><title>First</title><guid>a</guid></item>
+<item><title>Repeat of first</title><guid>a</guid></item>
+<item><title>Link only</title><link>http://example.com/b</link></item>
+<item><title>Link only again</title><link>http://example.com/b</link></item>
+<item><title>Neither</title></item>
+<item><title>Neither again</title></item>
+<item><title>Second</title><guid>c</guid></item>
+</channel></rss>`
+
+func TestDedup(t *testing.T) {
+	exp := []string{"First", "Link only", "Neither", "Neither again", "Second"}
+
+	f, err := Read(strings.NewReader(dupData))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(f.Entries) != 7 {
+		t.Errorf("Expected 7 entries before Dedup, got %d", len(f.Entries))
+	}
+	f.Dedup()
+	if ts := entryTitles(f); !reflect.DeepEqual(ts, exp) {
+		t.Errorf("Expected Dedup to give %v, got %v", exp, ts)
+	}
+
+	f, err = ReadWithOptions(strings.NewReader(dupData), ReadOptions{Dedup: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if ts := entryTitles(f); !reflect.DeepEqual(ts, exp) {
+		t.Errorf("Expected the Dedup option to give %v, got %v", exp, ts)
+	}
+
+	f, err = ReadWithOptions(strings.NewReader(dupData), ReadOptions{Dedup: true, MaxEntries: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if ts := entryTitles(f); !reflect.DeepEqual(ts, exp[:2]) {
+		t.Errorf("Expected duplicates not to count toward MaxEntries, got %v", ts)
+	}
+}
+
+func entryTitles(f Feed) []string {
+	var ts []string
+	for _, e := range f.Entries {
+		ts = append(ts, e.Title)
+	}
+	return ts
+}
@@ -0,0 +1,79 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RdfFeed is an RSS 1.0 feed in the style of Slashdot's.
+const rdfFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF
+	xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmlns="http://purl.org/rss/1.0/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"
+	xmlns:slash="http://purl.org/rss/1.0/modules/slash/">
+<channel rdf:about="https://slashdot.org/">
+	<title>Slashdot</title>
+	<link>https://slashdot.org/</link>
+	<description>News for nerds, stuff that matters</description>
+	<dc:date>2014-03-02T01:00:00+00:00</dc:date>
+	<items>
+		<rdf:Seq>
+			<rdf:li rdf:resource="https://tech.slashdot.org/story/14/03/01/1/"/>
+			<rdf:li rdf:resource="https://science.slashdot.org/story/14/03/01/2/"/>
+		</rdf:Seq>
+	</items>
+</channel>
+<item rdf:about="https://tech.slashdot.org/story/14/03/01/1/">
+	<title>First Story</title>
+	<link>https://tech.slashdot.org/story/14/03/01/1/</link>
+	<description>The first story.</description>
+	<dc:creator>timothy</dc:creator>
+	<dc:date>2014-03-01T23:00:00+00:00</dc:date>
+	<slash:comments>42</slash:comments>
+</item>
+<item rdf:about="https://science.slashdot.org/story/14/03/01/2/">
+	<title>Second Story</title>
+	<link>https://science.slashdot.org/story/14/03/01/2/?utm_source=rss</link>
+	<description>The second story.</description>
+	<dc:date>2014-03-01T22:00:00+00:00</dc:date>
+</item>
+</rdf:RDF>`
+
+func TestReadRDF(t *testing.T) {
+	f, format, err := ReadWithFormat(strings.NewReader(rdfFeed))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if format != FormatRDF {
+		t.Errorf("Expected format %s, got %s", FormatRDF, format)
+	}
+	if f.Title != "Slashdot" || f.Link != "https://slashdot.org/" {
+		t.Errorf("Expected Slashdot at https://slashdot.org/, got [%s] at [%s]", f.Title, f.Link)
+	}
+	if exp := time.Date(2014, 3, 2, 1, 0, 0, 0, time.UTC); !f.Updated.Equal(exp) {
+		t.Errorf("Expected updated %s, got %s", exp, f.Updated)
+	}
+	if len(f.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(f.Entries))
+	}
+
+	e := f.Entries[0]
+	if e.Title != "First Story" || string(e.Summary) != "The first story." {
+		t.Errorf("Expected the first story, got [%s] [%s]", e.Title, e.Summary)
+	}
+	if exp := time.Date(2014, 3, 1, 23, 0, 0, 0, time.UTC); !e.When.Equal(exp) {
+		t.Errorf("Expected entry time %s, got %s", exp, e.When)
+	}
+	if c := e.Extensions["slash:comments"]; len(c) != 1 || c[0] != "42" {
+		t.Errorf("Expected slash:comments [42], got %v", c)
+	}
+	if _, ok := e.Extensions[rdfRssNamespace+":title"]; ok {
+		t.Errorf("Expected RSS 1.0 elements not to be extensions, got %v", e.Extensions)
+	}
+
+	if id := f.Entries[1].ID; id != "https://science.slashdot.org/story/14/03/01/2/" {
+		t.Errorf("Expected the rdf:about ID, got [%s]", id)
+	}
+}
@@ -0,0 +1,59 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+)
+
+const atomDoc1 = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+<title>First</title>
+<entry><title>First Entry</title></entry>
+</feed>
+`
+
+const atomDoc2 = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+<title>Second</title>
+<entry><title>Second Entry 1</title></entry>
+<entry><title>Second Entry 2</title></entry>
+</feed>
+`
+
+func TestReadAll(t *testing.T) {
+	feeds, err := ReadAll(strings.NewReader(atomDoc1 + "<!-- next feed -->\n" + atomDoc2))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(feeds) != 2 {
+		t.Fatalf("Expected 2 feeds, got %d", len(feeds))
+	}
+	if feeds[0].Title != "First" || len(feeds[0].Entries) != 1 {
+		t.Errorf("Expected the first feed to be First with 1 entry, got %s with %d",
+			feeds[0].Title, len(feeds[0].Entries))
+	}
+	if feeds[1].Title != "Second" || len(feeds[1].Entries) != 2 {
+		t.Errorf("Expected the second feed to be Second with 2 entries, got %s with %d",
+			feeds[1].Title, len(feeds[1].Entries))
+	}
+}
+
+func TestReadAllMalformed(t *testing.T) {
+	feeds, err := ReadAll(strings.NewReader(atomDoc1 + "<feed><title>Broken"))
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+	if len(feeds) != 1 || feeds[0].Title != "First" {
+		t.Errorf("Expected the first feed to be returned, got %v", feeds)
+	}
+}
+
+func TestReadAllEmpty(t *testing.T) {
+	feeds, err := ReadAll(strings.NewReader(""))
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if len(feeds) != 0 {
+		t.Errorf("Expected no feeds, got %d", len(feeds))
+	}
+}
@@ -0,0 +1,106 @@
+package webfeed
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"code.google.com/p/go.net/html"
+)
+
+// ResolveURLs returns the HTML fragment h with the relative URLs in its
+// href, src, and srcset attributes made absolute by resolving them against
+// base. Absolute URLs and fragment-only links (#foo) are left untouched.
+// If base is not an absolute URL or h cannot be parsed, h is returned
+// unchanged.
+func resolveURLs(base string, h []byte) []byte {
+	b, err := url.Parse(base)
+	if err != nil || !b.IsAbs() {
+		return h
+	}
+	n, err := html.Parse(bytes.NewReader(h))
+	if err != nil {
+		return h
+	}
+	body := findElement(n, "body")
+	if body == nil || !resolveNode(b, body) {
+		return h
+	}
+
+	out, err := renderChildren(body, len(h))
+	if err != nil {
+		return h
+	}
+	return out
+}
+
+// ResolveNode resolves the URL attributes of n and its descendants
+// against base, returning true if any were changed.
+func resolveNode(base *url.URL, n *html.Node) bool {
+	changed := false
+	if n.Type == html.ElementNode {
+		for i, a := range n.Attr {
+			var v string
+			switch a.Key {
+			case "href", "src":
+				v = resolveURL(base, a.Val)
+			case "srcset":
+				v = resolveSrcset(base, a.Val)
+			default:
+				continue
+			}
+			if v != a.Val {
+				n.Attr[i].Val = v
+				changed = true
+			}
+		}
+	}
+	for k := n.FirstChild; k != nil; k = k.NextSibling {
+		if resolveNode(base, k) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// ResolveURL returns ref resolved against base. Absolute URLs,
+// fragment-only references, and unparsable URLs are returned unchanged.
+func resolveURL(base *url.URL, ref string) string {
+	if base == nil || ref == "" || strings.HasPrefix(ref, "#") {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.IsAbs() {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+// ResolveSrcset resolves each of the image candidate URLs in the value
+// of a srcset attribute against base.
+func resolveSrcset(base *url.URL, srcset string) string {
+	cands := strings.Split(srcset, ",")
+	for i, c := range cands {
+		fs := strings.Fields(c)
+		if len(fs) == 0 {
+			continue
+		}
+		fs[0] = resolveURL(base, fs[0])
+		cands[i] = strings.Join(fs, " ")
+	}
+	return strings.Join(cands, ", ")
+}
+
+// XmlBase returns the base URL given by an xml:base attribute, resolved
+// against the enclosing base, parent, if there is one. If the attribute
+// is empty or unparsable, parent is returned.
+func xmlBase(parent *url.URL, base string) *url.URL {
+	u, err := url.Parse(strings.TrimSpace(base))
+	if err != nil || base == "" {
+		return parent
+	}
+	if parent == nil {
+		return u
+	}
+	return parent.ResolveReference(u)
+}
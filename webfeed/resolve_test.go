@@ -0,0 +1,108 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveURLs(t *testing.T) {
+	const base = "http://example.com/blog/"
+	tests := []struct {
+		in, out string
+	}{
+		{"", ""},
+		{"plain text", "plain text"},
+		{`<a href="/about">a</a>`, `<a href="http://example.com/about">a</a>`},
+		{`<a href="post.html">a</a>`, `<a href="http://example.com/blog/post.html">a</a>`},
+		{`<img src="/wp-content/image.png"/>`, `<img src="http://example.com/wp-content/image.png"/>`},
+		{`<img srcset="a.png 1x, /b.png 2x"/>`, `<img srcset="http://example.com/blog/a.png 1x, http://example.com/b.png 2x"/>`},
+		{`<a href="https://other.com/x">a</a>`, `<a href="https://other.com/x">a</a>`},
+		{`<a href="#foo">a</a>`, `<a href="#foo">a</a>`},
+		{`<a href="mailto:a@example.com">a</a>`, `<a href="mailto:a@example.com">a</a>`},
+	}
+
+	for _, test := range tests {
+		o := string(resolveURLs(base, []byte(test.in)))
+		if o != test.out {
+			t.Errorf("Expected [%s] to resolve to [%s], but got [%s]", test.in, test.out, o)
+		}
+	}
+}
+
+func TestResolveURLsBadBase(t *testing.T) {
+	const in = `<a href="/about">a</a>`
+	for _, base := range []string{"", "/relative/", "%"} {
+		if o := string(resolveURLs(base, []byte(in))); o != in {
+			t.Errorf("Expected base [%s] to leave [%s] unchanged, got [%s]", base, in, o)
+		}
+	}
+}
+
+func TestReadResolvesURLs(t *testing.T) {
+	const data = `<rss version="2.0"><channel><title>T</title><link>http://example.com/</link>
+<item><title>I</title><description>&lt;img src="/i.png"&gt;</description></item>
+</channel></rss>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	exp := `<img src="http://example.com/i.png"/>`
+	if s := string(f.Entries[0].Summary); s != exp {
+		t.Errorf("Expected summary [%s], got [%s]", exp, s)
+	}
+}
+
+func TestAtomXmlBase(t *testing.T) {
+	const data = `<feed xmlns="http://www.w3.org/2005/Atom" xml:base="http://example.com/">
+<title>T</title>
+<link href="/"/>
+<entry>
+	<title>Relative</title>
+	<link href="posts/1"/>
+	<link rel="enclosure" href="media/1.mp3" type="audio/mpeg"/>
+	<summary type="html">&lt;img src="a.png"&gt;</summary>
+</entry>
+<entry xml:base="blog/">
+	<title>Nested base</title>
+	<link href="2"/>
+</entry>
+<entry xml:base="http://other.com/">
+	<title>Absolute base</title>
+	<link href="3"/>
+</entry>
+<entry>
+	<title>Absolute link</title>
+	<link href="http://elsewhere.com/4"/>
+</entry>
+</feed>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Link != "http://example.com/" {
+		t.Errorf("Expected feed link [http://example.com/], got [%s]", f.Link)
+	}
+	links := []string{
+		"http://example.com/posts/1",
+		"http://example.com/blog/2",
+		"http://other.com/3",
+		"http://elsewhere.com/4",
+	}
+	if len(f.Entries) != len(links) {
+		t.Fatalf("Expected %d entries, got %d", len(links), len(f.Entries))
+	}
+	for i, l := range links {
+		if f.Entries[i].Link != l {
+			t.Errorf("Expected entry %d link [%s], got [%s]", i, l, f.Entries[i].Link)
+		}
+	}
+	if u := f.Entries[0].Enclosures[0].URL; u != "http://example.com/media/1.mp3" {
+		t.Errorf("Expected enclosure URL [http://example.com/media/1.mp3], got [%s]", u)
+	}
+	exp := `<img src="http://example.com/a.png"/>`
+	if s := string(f.Entries[0].Summary); s != exp {
+		t.Errorf("Expected summary [%s], got [%s]", exp, s)
+	}
+}
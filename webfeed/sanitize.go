@@ -0,0 +1,201 @@
+package webfeed
+
+import (
+	"bytes"
+	"strings"
+
+	"code.google.com/p/go.net/html"
+)
+
+// UnsafeElements are elements that are removed, along with their
+// contents, by SanitizeHTML. Elements that are neither unsafe nor in
+// safeElements are removed, but their contents are kept.
+var unsafeElements = map[string]bool{
+	"applet":   true,
+	"base":     true,
+	"button":   true,
+	"embed":    true,
+	"form":     true,
+	"frame":    true,
+	"frameset": true,
+	"iframe":   true,
+	"input":    true,
+	"link":     true,
+	"math":     true,
+	"meta":     true,
+	"noscript": true,
+	"object":   true,
+	"script":   true,
+	"select":   true,
+	"style":    true,
+	"svg":      true,
+	"template": true,
+	"textarea": true,
+}
+
+// SafeElements are the elements kept by SanitizeHTML.
+var safeElements = map[string]bool{
+	"a": true, "abbr": true, "acronym": true, "address": true,
+	"article": true, "aside": true, "audio": true, "b": true,
+	"bdi": true, "bdo": true, "big": true, "blockquote": true,
+	"br": true, "caption": true, "center": true, "cite": true,
+	"code": true, "col": true, "colgroup": true, "dd": true,
+	"del": true, "details": true, "dfn": true, "div": true,
+	"dl": true, "dt": true, "em": true, "figcaption": true,
+	"figure": true, "font": true, "footer": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "header": true, "hr": true, "i": true,
+	"img": true, "ins": true, "kbd": true, "li": true,
+	"main": true, "mark": true, "ol": true, "p": true,
+	"picture": true, "pre": true, "q": true, "rp": true,
+	"rt": true, "ruby": true, "s": true, "samp": true,
+	"section": true, "small": true, "source": true, "span": true,
+	"strike": true, "strong": true, "sub": true, "summary": true,
+	"sup": true, "table": true, "tbody": true, "td": true,
+	"tfoot": true, "th": true, "thead": true, "time": true,
+	"tr": true, "track": true, "tt": true, "u": true,
+	"ul": true, "var": true, "video": true, "wbr": true,
+}
+
+// GlobalAttributes are the attributes kept on any safe element.
+var globalAttributes = map[string]bool{
+	"class": true,
+	"dir":   true,
+	"lang":  true,
+	"title": true,
+}
+
+// ElementAttributes are the attributes kept on particular safe elements,
+// in addition to the globalAttributes.
+var elementAttributes = map[string]map[string]bool{
+	"a":          {"href": true, "hreflang": true, "rel": true},
+	"audio":      {"controls": true, "loop": true, "muted": true, "preload": true, "src": true},
+	"blockquote": {"cite": true},
+	"col":        {"span": true, "width": true},
+	"colgroup":   {"span": true, "width": true},
+	"del":        {"cite": true, "datetime": true},
+	"details":    {"open": true},
+	"div":        {"align": true},
+	"font":       {"color": true, "face": true, "size": true},
+	"hr":         {"align": true, "width": true},
+	"img":        {"align": true, "alt": true, "height": true, "sizes": true, "src": true, "srcset": true, "width": true},
+	"ins":        {"cite": true, "datetime": true},
+	"li":         {"value": true},
+	"ol":         {"reversed": true, "start": true, "type": true},
+	"p":          {"align": true},
+	"q":          {"cite": true},
+	"source":     {"media": true, "sizes": true, "src": true, "srcset": true, "type": true},
+	"table":      {"align": true, "border": true, "cellpadding": true, "cellspacing": true, "summary": true, "width": true},
+	"td":         {"align": true, "colspan": true, "headers": true, "rowspan": true, "valign": true, "width": true},
+	"th":         {"align": true, "colspan": true, "headers": true, "rowspan": true, "scope": true, "valign": true, "width": true},
+	"time":       {"datetime": true},
+	"tr":         {"align": true, "valign": true},
+	"track":      {"kind": true, "label": true, "src": true, "srclang": true},
+	"ul":         {"type": true},
+	"video":      {"controls": true, "height": true, "loop": true, "muted": true, "poster": true, "preload": true, "src": true, "width": true},
+}
+
+// UrlAttributes are the kept attributes whose values are URLs.
+var urlAttributes = map[string]bool{
+	"cite":   true,
+	"href":   true,
+	"poster": true,
+	"src":    true,
+	"srcset": true,
+}
+
+// SanitizeHTML parses bytes as HTML and returns well-formed HTML with
+// only the formatting elements of safeElements and their safe attributes.
+// Scripts, frames, forms, SVG, and other dangerous elements are removed
+// with their contents, and so are javascript: URLs. If the HTML cannot be
+// parsed, it is returned escaped.
+func SanitizeHTML(in []byte) []byte {
+	n, err := html.Parse(bytes.NewReader(in))
+	if err != nil {
+		return []byte(html.EscapeString(string(in)))
+	}
+	body := findElement(n, "body")
+	if body == nil {
+		return []byte(html.EscapeString(string(in)))
+	}
+	sanitize(body)
+
+	out, err := renderChildren(body, len(in))
+	if err != nil {
+		return []byte(html.EscapeString(string(in)))
+	}
+	return out
+}
+
+// Sanitize removes unsafe elements and attributes from the children of n.
+// Elements that are not safe, but not known to be unsafe, are replaced by
+// their sanitized children.
+func sanitize(n *html.Node) {
+	for k := n.FirstChild; k != nil; {
+		next := k.NextSibling
+		switch {
+		case k.Type == html.CommentNode:
+			n.RemoveChild(k)
+		case k.Type != html.ElementNode:
+		case k.Namespace != "" || unsafeElements[strings.ToLower(k.Data)]:
+			// Foreign SVG and MathML content can hide
+			// script URLs in attributes like values.
+			n.RemoveChild(k)
+		case safeElements[strings.ToLower(k.Data)]:
+			k.Attr = safeAttributes(strings.ToLower(k.Data), k.Attr)
+			sanitize(k)
+		default:
+			sanitize(k)
+			for c := k.FirstChild; c != nil; c = k.FirstChild {
+				k.RemoveChild(c)
+				n.InsertBefore(c, k)
+			}
+			n.RemoveChild(k)
+		}
+		k = next
+	}
+}
+
+// SafeAttributes returns the attributes that are allowed on the element,
+// leaving out those with script URLs.
+func safeAttributes(elem string, attrs []html.Attribute) []html.Attribute {
+	var safe []html.Attribute
+	for _, a := range attrs {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" || !globalAttributes[key] && !elementAttributes[elem][key] {
+			continue
+		}
+		if urlAttributes[key] && isScriptURL(a.Val) {
+			continue
+		}
+		safe = append(safe, a)
+	}
+	return safe
+}
+
+// IsScriptURL returns true if the URL uses a scheme that executes script.
+// Browsers ignore whitespace and control characters in the scheme, so
+// they are ignored here too.
+func isScriptURL(u string) bool {
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(u))
+	return strings.HasPrefix(u, "javascript:") || strings.HasPrefix(u, "vbscript:")
+}
+
+// FindElement returns the first element named name in a depth-first
+// traversal of n, or nil if there is none.
+func findElement(n *html.Node, name string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == name {
+		return n
+	}
+	for k := n.FirstChild; k != nil; k = k.NextSibling {
+		if e := findElement(k, name); e != nil {
+			return e
+		}
+	}
+	return nil
+}
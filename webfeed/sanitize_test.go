@@ -0,0 +1,59 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"", ""},
+		{"<script>alert(1)</script>", ""},
+		{"Hello<script>alert(1)</script> world", "Hello world"},
+		{"<p><b>bold</b> <i>italic</i></p>", "<p><b>bold</b> <i>italic</i></p>"},
+		{`<iframe src="http://evil.example.com"></iframe>ok`, "ok"},
+		{`<img src="a.png" onerror="alert(1)">`, `<img src="a.png"/>`},
+		{`<a href="javascript:alert(1)">x</a>`, "<a>x</a>"},
+		{`<a href=" JaVa&#x09;Script:alert(1)">x</a>`, "<a>x</a>"},
+		{`<a href="http://example.com/" title="t">x</a>`, `<a href="http://example.com/" title="t">x</a>`},
+		{"<!-- comment -->text", "text"},
+		{"<style>body{}</style><form><input></form>text", "text"},
+		{`<svg><a><animate attributeName="href" values="javascript:alert(1)"/><text>click</text></a></svg>`, ""},
+		{`<math><maction actiontype="statusline" xlink:href="javascript:alert(1)">x</maction></math>ok`, "ok"},
+		{`<p><set attributeName="href" to="javascript:alert(1)">x</set></p>`, "<p>x</p>"},
+		{`<custom-tag onclick="alert(1)"><b>kept</b></custom-tag>`, "<b>kept</b>"},
+		{`<p id="x" style="color: red" class="c" align="center">x</p>`, `<p class="c" align="center">x</p>`},
+		{`<img src="a.png" alt="A" width="10" data-x="y">`, `<img src="a.png" alt="A" width="10"/>`},
+	}
+
+	for _, test := range tests {
+		o := string(SanitizeHTML([]byte(test.in)))
+		if o != test.out {
+			t.Errorf("Expected [%s] to sanitize to [%s], but got [%s]", test.in, test.out, o)
+		}
+	}
+}
+
+func TestReadSanitize(t *testing.T) {
+	const data = `<rss version="2.0"><channel><title>T</title>
+<item><title>I</title><description>&lt;p&gt;Hi&lt;/p&gt;&lt;script&gt;alert(1)&lt;/script&gt;</description></item>
+</channel></rss>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if s := string(f.Entries[0].Summary); !strings.Contains(s, "<script>") {
+		t.Errorf("Expected the unsanitized summary to keep its script, got [%s]", s)
+	}
+
+	f, err = ReadWithOptions(strings.NewReader(data), ReadOptions{Sanitize: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if s := string(f.Entries[0].Summary); s != "<p>Hi</p>" {
+		t.Errorf("Expected sanitized summary [<p>Hi</p>], got [%s]", s)
+	}
+}
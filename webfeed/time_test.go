@@ -0,0 +1,267 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBadTimes(t *testing.T) {
+	const data = `<rss version="2.0"><channel><title>T</title>
+<pubDate>yesterday</pubDate>
+<item><title>Good</title><pubDate>Mon, 2 Jan 2006 15:04:05 -0700</pubDate></item>
+<item><title>Bad 1</title><pubDate>last week</pubDate></item>
+<item><title>Bad 2</title><pubDate>someday</pubDate></item>
+</channel></rss>`
+
+	f, err := Read(strings.NewReader(data))
+	bts, ok := err.(ErrBadTimes)
+	if !ok {
+		t.Fatalf("Expected ErrBadTimes, got %v", err)
+	}
+	exp := ErrBadTimes{
+		{Time: "yesterday"},
+		{Title: "Bad 1", Time: "last week"},
+		{Title: "Bad 2", Time: "someday"},
+	}
+	if len(bts) != len(exp) {
+		t.Fatalf("Expected %d bad times, got %d: %v", len(exp), len(bts), bts)
+	}
+	for i := range exp {
+		if bts[i] != exp[i] {
+			t.Errorf("Expected bad time %d to be %v, got %v", i, exp[i], bts[i])
+		}
+	}
+
+	if len(f.Entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(f.Entries))
+	}
+	if f.Entries[0].When.IsZero() {
+		t.Errorf("Expected the good entry to have a time")
+	}
+	for _, e := range f.Entries[1:] {
+		if !e.When.IsZero() {
+			t.Errorf("Expected %s to have the zero time, got %s", e.Title, e.When)
+		}
+	}
+}
+
+func TestReadAllBadTimes(t *testing.T) {
+	const doc = `<rss version="2.0"><channel><title>T</title>
+<item><title>Bad</title><pubDate>never</pubDate></item>
+</channel></rss>`
+
+	feeds, err := ReadAll(strings.NewReader(doc + doc))
+	if bts, ok := err.(ErrBadTimes); !ok || len(bts) != 2 {
+		t.Errorf("Expected two bad times, got %v", err)
+	}
+	if len(feeds) != 2 {
+		t.Errorf("Expected 2 feeds, got %d", len(feeds))
+	}
+}
+
+func TestRssTime(t *testing.T) {
+	est := time.FixedZone("", -5*60*60)
+	tests := []struct {
+		in  string
+		out time.Time
+	}{
+		{"", time.Time{}},
+		{"Mon, 2 Jan 2006 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"Mon, 02 Jan 2006 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"Tue, 10 Jun 2003 04:00:00 GMT", time.Date(2003, 6, 10, 4, 0, 0, 0, time.UTC)},
+		{"Mon, 2 Jan 06 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"02 January 2006", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"2006-01-02 15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"2006-01-02T15:04:05Z", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"2006-01-02T15:04:05-05:00", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"2006-01-02T15:04:05.25-05:00", time.Date(2006, 1, 2, 15, 4, 5, 250000000, est)},
+		{"2006-01-02T15:04:05-0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"2006-01-02T15:04-05:00", time.Date(2006, 1, 2, 15, 4, 0, 0, est)},
+		{"2006-01-02", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"2006-01", time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2006", time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		o, err := rssTime(test.in)
+		if err != nil {
+			t.Errorf("Unexpected error parsing [%s]: %s", test.in, err)
+			continue
+		}
+		if !o.Equal(test.out) {
+			t.Errorf("Expected [%s] to parse as %s, got %s", test.in, test.out, o)
+		}
+	}
+}
+
+func TestRssTimeBad(t *testing.T) {
+	for _, s := range []string{"yesterday", "2006-13-45", "Mon, 2 Jan"} {
+		if _, err := rssTime(s); err != ErrBadTime(s) {
+			t.Errorf("Expected ErrBadTime parsing [%s], got %v", s, err)
+		}
+	}
+}
+
+func TestRssTimeZoneAbbreviations(t *testing.T) {
+	tests := []struct {
+		in  string
+		out time.Time
+	}{
+		{"Mon, 2 Jan 2006 15:04:05 EST", time.Date(2006, 1, 2, 20, 4, 5, 0, time.UTC)},
+		{"Mon, 2 Jan 2006 15:04:05 PDT", time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)},
+		{"Mon, 2 Jan 2006 15:04:05 CEST", time.Date(2006, 1, 2, 13, 4, 5, 0, time.UTC)},
+		{"Mon, 2 Jan 2006 15:04:05 IST", time.Date(2006, 1, 2, 9, 34, 5, 0, time.UTC)},
+		{"Mon, 2 Jan 2006 15:04:05 GMT", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"Mon, 2 Jan 2006 15:04:05 XYZ", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		o, err := rssTime(test.in)
+		if err != nil {
+			t.Errorf("Unexpected error parsing [%s]: %s", test.in, err)
+			continue
+		}
+		if !o.Equal(test.out) {
+			t.Errorf("Expected [%s] to parse as %s, got %s", test.in, test.out, o.UTC())
+		}
+	}
+}
+
+func TestDcDate(t *testing.T) {
+	const data = `<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+	<title>T</title>
+	<dc:date>2014-03-01T12:00:00Z</dc:date>
+	<item>
+		<title>Only dc:date</title>
+		<dc:date>2014-02-28T08:30:00-05:00</dc:date>
+	</item>
+	<item>
+		<title>Both</title>
+		<pubDate>Sat, 01 Mar 2014 09:00:00 +0000</pubDate>
+		<dc:date>2000-01-01T00:00:00Z</dc:date>
+	</item>
+</channel>
+</rss>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if exp := time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC); !f.Updated.Equal(exp) {
+		t.Errorf("Expected feed updated %s, got %s", exp, f.Updated)
+	}
+	if exp := time.Date(2014, 2, 28, 13, 30, 0, 0, time.UTC); !f.Entries[0].When.Equal(exp) {
+		t.Errorf("Expected entry time %s, got %s", exp, f.Entries[0].When)
+	}
+	if exp := time.Date(2014, 3, 1, 9, 0, 0, 0, time.UTC); !f.Entries[1].When.Equal(exp) {
+		t.Errorf("Expected pubDate %s to take precedence, got %s", exp, f.Entries[1].When)
+	}
+}
+
+func TestPublished(t *testing.T) {
+	published := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2014, 2, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name, data      string
+		when, published time.Time
+	}{
+		{
+			name: "atom both",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title><entry><title>E</title>
+<published>2014-01-01T00:00:00Z</published><updated>2014-02-01T00:00:00Z</updated></entry></feed>`,
+			when:      updated,
+			published: published,
+		},
+		{
+			name: "atom updated",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title><entry><title>E</title>
+<updated>2014-02-01T00:00:00Z</updated></entry></feed>`,
+			when: updated,
+		},
+		{
+			name: "atom published",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title><entry><title>E</title>
+<published>2014-01-01T00:00:00Z</published></entry></feed>`,
+			when:      published,
+			published: published,
+		},
+		{
+			name: "rss",
+			data: `<rss version="2.0"><channel><title>T</title><item><title>E</title>
+<pubDate>Wed, 01 Jan 2014 00:00:00 +0000</pubDate></item></channel></rss>`,
+			when:      published,
+			published: published,
+		},
+		{
+			name:      "json",
+			data:      `{"items": [{"id": "1", "date_published": "2014-01-01T00:00:00Z", "date_modified": "2014-02-01T00:00:00Z"}]}`,
+			when:      updated,
+			published: published,
+		},
+	}
+
+	for _, test := range tests {
+		f, err := ReadAny(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		e := f.Entries[0]
+		if !e.When.Equal(test.when) {
+			t.Errorf("%s: expected When %s, got %s", test.name, test.when, e.When)
+		}
+		if !e.Published.Equal(test.published) {
+			t.Errorf("%s: expected Published %s, got %s", test.name, test.published, e.Published)
+		}
+	}
+}
+
+func TestUpdatedFallback(t *testing.T) {
+	latest := time.Date(2014, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name, data string
+		updated    time.Time
+	}{
+		{
+			name: "rss",
+			data: `<rss version="2.0"><channel><title>T</title>
+<item><title>A</title><pubDate>Sat, 01 Feb 2014 00:00:00 +0000</pubDate></item>
+<item><title>B</title><pubDate>Sat, 01 Mar 2014 00:00:00 +0000</pubDate></item>
+<item><title>C</title></item>
+</channel></rss>`,
+			updated: latest,
+		},
+		{
+			name: "atom",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title>
+<entry><title>A</title><updated>2014-03-01T00:00:00Z</updated></entry>
+<entry><title>B</title><updated>2014-01-01T00:00:00Z</updated></entry>
+</feed>`,
+			updated: latest,
+		},
+		{
+			name: "own time",
+			data: `<rss version="2.0"><channel><title>T</title><pubDate>Wed, 01 Jan 2014 00:00:00 +0000</pubDate>
+<item><title>A</title><pubDate>Sat, 01 Mar 2014 00:00:00 +0000</pubDate></item>
+</channel></rss>`,
+			updated: time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "no times",
+			data: `<rss version="2.0"><channel><title>T</title><item><title>A</title></item></channel></rss>`,
+		},
+	}
+
+	for _, test := range tests {
+		f, err := Read(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if !f.Updated.Equal(test.updated) {
+			t.Errorf("%s: expected Updated %s, got %s", test.name, test.updated, f.Updated)
+		}
+	}
+}
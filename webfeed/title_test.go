@@ -0,0 +1,50 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTitleEntities(t *testing.T) {
+	const rssData = `<rss version="2.0"><channel><title>AT&amp;amp;T News</title>
+<item><title>Ben &amp;#38; Jerry</title></item>
+<item><title>Caf&amp;eacute; &amp;#x263A;</title></item>
+<item><title>Already &lt;plain&gt; &amp; fine</title></item>
+</channel></rss>`
+
+	f, err := Read(strings.NewReader(rssData))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Title != "AT&T News" {
+		t.Errorf("Expected feed title [AT&T News], got [%s]", f.Title)
+	}
+	exp := []string{"Ben & Jerry", "Café ☺", "Already <plain> & fine"}
+	for i, e := range f.Entries {
+		if e.Title != exp[i] {
+			t.Errorf("Expected RSS entry %d title [%s], got [%s]", i, exp[i], e.Title)
+		}
+	}
+
+	const atomData = `<feed xmlns="http://www.w3.org/2005/Atom"><title type="html">AT&amp;amp;T &lt;em&gt;News&lt;/em&gt;</title>
+<entry><title>Ben &amp;#38; Jerry</title></entry>
+<entry><title type="html">Caf&amp;eacute; &amp;amp; &lt;b&gt;Bar&lt;/b&gt;</title></entry>
+<entry><title type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml">Fish &amp;amp; <b>Chips</b></div></title></entry>
+</feed>`
+
+	f, err = Read(strings.NewReader(atomData))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Title != "AT&T News" {
+		t.Errorf("Expected feed title [AT&T News], got [%s]", f.Title)
+	}
+	// The XHTML title is markup, so &amp;amp; is the text "&amp;",
+	// which must not be unescaped again.
+	exp = []string{"Ben & Jerry", "Café & Bar", "Fish &amp; Chips"}
+	for i, e := range f.Entries {
+		if e.Title != exp[i] {
+			t.Errorf("Expected Atom entry %d title [%s], got [%s]", i, exp[i], e.Title)
+		}
+	}
+}
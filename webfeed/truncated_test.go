@@ -0,0 +1,106 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestIsTruncated(t *testing.T) {
+	long := strings.Repeat("word ", truncatedLength/4)
+	tests := []struct {
+		text      string
+		truncated bool
+	}{
+		{"", true},
+		{"A short summary.", true},
+		{long, false},
+		{long + "and so on…", true},
+		{long + "and so on...", true},
+		{long + "and so on [...]", true},
+		{long + "and so on […]", true},
+		{long + "Read more", true},
+		{long + "Read More »", true},
+		{long + "Continue reading →", true},
+	}
+
+	for _, test := range tests {
+		if tr := isTruncated(test.text); tr != test.truncated {
+			t.Errorf("Expected isTruncated([%s]) to be %t, got %t", test.text, test.truncated, tr)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"<p>Hello,\n\t<b>world</b></p>", "Hello, world"},
+		{"AT&amp;T", "AT&T"},
+		{"<script>alert(1)</script>text<style>p{}</style>", "text"},
+		{"un<b>believ</b>able <i>ad</i>vertising", "unbelievable advertising"},
+		{"<p>one</p><p>two</p>", "one two"},
+		{"line<br>break", "line break"},
+		{"<ul><li>a</li><li>b</li></ul>", "a b"},
+		{"<table><tr><td>cell</td><td>cell</td></tr></table>", "cell cell"},
+	}
+
+	for _, test := range tests {
+		if o := plainText([]byte(test.in)); o != test.out {
+			t.Errorf("Expected plainText([%s]) to be [%s], got [%s]", test.in, test.out, o)
+		}
+	}
+}
+
+func TestSetTruncated(t *testing.T) {
+	e := Entry{Summary: []byte("<p>Just the first paragraph… <a href=\"#\">Read more</a></p>")}
+	e.setTruncated()
+	if !e.ContentTruncated {
+		t.Errorf("Expected the summary to be truncated")
+	}
+	if e.TextLength != 35 {
+		t.Errorf("Expected a text length of 35, got %d", e.TextLength)
+	}
+
+	e = Entry{
+		Summary: []byte("Short"),
+		Content: []byte("<p>" + strings.Repeat("word ", truncatedLength) + "</p>"),
+	}
+	e.setTruncated()
+	if e.ContentTruncated {
+		t.Errorf("Expected the content not to be truncated")
+	}
+}
+
+func TestTextSummary(t *testing.T) {
+	tests := []struct {
+		in       string
+		maxRunes int
+		out      string
+	}{
+		{"", 10, ""},
+		{"<p>Short</p>", 10, "Short"},
+		{"<p>Exactly ten</p>", 11, "Exactly ten"},
+		{"<p>Hello,</p>\n<p>   world</p>", 0, "Hello, world"},
+		{"<p>Hello, world</p>", 6, "Hello…"},
+		{"<p>Hello, world</p>", 8, "Hello,…"},
+		{"Tom &amp; Jerry &lt;3", 20, "Tom & Jerry <3"},
+		{"Caf&eacute; cr&egrave;me", 20, "Café crème"},
+		{"日本語のテキスト", 4, "日本語…"},
+		{"<style>p {}</style><script>x()</script>Text", 10, "Text"},
+	}
+	for _, test := range tests {
+		o := TextSummary([]byte(test.in), test.maxRunes)
+		if o != test.out {
+			t.Errorf("Expected [%s] to summarize to [%s] in %d runes, got [%s]", test.in, test.out, test.maxRunes, o)
+		}
+		if test.maxRunes > 0 && utf8.RuneCountInString(o) > test.maxRunes {
+			t.Errorf("Expected at most %d runes, got %d in [%s]", test.maxRunes, utf8.RuneCountInString(o), o)
+		}
+		if !utf8.ValidString(o) {
+			t.Errorf("Expected valid UTF-8, got [%q]", o)
+		}
+	}
+}
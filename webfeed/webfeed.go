@@ -2,56 +2,372 @@ package webfeed
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"errors"
 	"io"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"code.google.com/p/go.net/html"
 )
 
 type Feed struct {
-	Title   string
-	Link    string
+	Title string
+	// Link is the URL of the website of the feed.
+	Link string
+	// Self is the URL of the feed itself, if the feed gives one with
+	// a rel="self" link.
+	Self string
+	// Hubs are the URLs of the WebSub hubs that the feed gives with
+	// rel="hub" links.
+	Hubs []string
+	// Updated is the time the feed was last updated. If the feed does
+	// not give one, Updated is the newest time of its entries.
 	Updated time.Time
+	// Authors are the names of the feed's authors.
+	Authors []string
+	// Image is the URL of the feed's logo or artwork: the RSS <image>
+	// or the Atom <logo>. Icon is the URL of a small icon for the feed,
+	// such as a favicon, from the Atom <icon>.
+	Image string
+	Icon  string
+	// Language is the language of the feed, such as "en-us": the RSS
+	// <language> or the xml:lang of the Atom feed.
+	Language string
+	// Rights is the plain text copyright notice of the feed: the RSS
+	// <copyright> or the Atom <rights>.
+	Rights  string
 	Entries []Entry
+
+	// Blocked is true if the publisher has asked podcast directories
+	// not to list the feed (<itunes:block>yes</itunes:block>).
+	Blocked bool
+	// Complete is true if the publisher has said that no more entries
+	// will be added to the feed (<itunes:complete>yes</itunes:complete>).
+	Complete bool
+	// Podcast is the iTunes podcast information of the feed.
+	Podcast Podcast
 }
 
 type Entry struct {
+	// ID is a stable identifier for the entry: the RSS guid or the Atom id.
+	// If the entry has neither, ID is the entry's Link.
+	ID    string
 	Title string
 	Link  string
 	// Summary is a valid HTML or escaped HTML summary of the entry.
 	Summary []byte
 	// Contents is the main contents of the entry in valid HTML or escaped HTML.
 	Content []byte
-	When    time.Time
+	// When is the time that the entry was last updated. If the feed
+	// gives only a publication time, When is that time.
+	When time.Time
+	// Published is the time that the entry was first published, or the
+	// zero time if the feed does not give one. For RSS, Published is
+	// the same as When.
+	Published time.Time
+	// Authors are the names of the entry's authors.
+	Authors []string
+	// Categories are the entry's categories or tags.
+	Categories []string
+
+	// TextLength is the length in runes of the plain text of the entry's
+	// Content, or of its Summary if it has no Content.
+	TextLength int
+	// ContentTruncated is true if the entry's text appears to be an
+	// excerpt of a longer article.
+	ContentTruncated bool
+
+	// Enclosures are media files attached to the entry.
+	Enclosures []Enclosure
+	// Media and Thumbnails are the entry's Media RSS objects and
+	// thumbnail images.
+	Media      []Media
+	Thumbnails []Thumbnail
+	// Podcast is the iTunes podcast information of the entry.
+	Podcast Podcast
+
+	// Extensions holds the text of namespaced elements that are not
+	// otherwise understood, keyed by "namespace:localname". Well-known
+	// namespaces use their conventional prefix (see extensionPrefixes);
+	// all others use the full namespace URI.
+	Extensions map[string][]string
+}
+
+// An Enclosure is a media file, such as a podcast episode, attached to an entry.
+type Enclosure struct {
+	URL string
+	// Type is the MIME type of the file.
+	Type string
+	// Length is the size of the file in bytes, or 0 if it is unknown.
+	Length int64
 }
 
 // Read reads a feed from an io.Reader and returns it or an error if one was encountered.
 //
+// If the document is HTML, or is neither RSS nor Atom and has no entries,
+// Read returns ErrNotAFeed.
+//
 // RSS is like the wild west with respect to time. When reading RSS, this
-// function may return the non-fatal error ErrBadTime containing the
-// first unparsable time encountered.
+// function may return the non-fatal error ErrBadTimes listing every
+// unparsable time encountered. The Feed is still returned, with zero
+// times in place of the unparsable ones.
 func Read(r io.Reader) (Feed, error) {
-	var f feed
+	return ReadContext(context.Background(), r)
+}
+
+// ErrNotAFeed is returned when reading a document that is not a feed,
+// such as a web page. The page may link to its feeds; see Discover.
+var ErrNotAFeed = errors.New("the document is not a feed")
+
+// ReadContext is like Read, but it stops reading and returns the
+// context's error if the context is cancelled or its deadline passes.
+func ReadContext(ctx context.Context, r io.Reader) (Feed, error) {
+	f, _, err := readWithFormat(ctx, r, ReadOptions{})
+	return f, err
+}
+
+// ReadOptions control how a feed is read.
+type ReadOptions struct {
+	// Sanitize removes scripts and other dangerous markup from the
+	// Summary and Content of each entry, using SanitizeHTML.
+	Sanitize bool
+
+	// MaxEntries is the maximum number of entries to return. The first
+	// MaxEntries entries in document order are kept and the rest are
+	// discarded as they are read. Unparsable times in the discarded
+	// entries are not reported. Zero means there is no limit.
+	MaxEntries int
+
+	// Dedup removes entries with the same key as an earlier entry, as
+	// Feed.Dedup does. Removed entries do not count toward MaxEntries.
+	Dedup bool
+}
+
+// ReadWithOptions is like Read, but with options.
+func ReadWithOptions(r io.Reader, opts ReadOptions) (Feed, error) {
+	f, _, err := readWithFormat(context.Background(), r, opts)
+	return f, err
+}
+
+// Apply modifies an entry as directed by the options.
+func (opts ReadOptions) apply(e *Entry) {
+	if opts.Sanitize {
+		e.Summary = SanitizeHTML(e.Summary)
+		e.Content = SanitizeHTML(e.Content)
+	}
+}
+
+// A Format is a syndication format.
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatRSS
+	FormatAtom
+	// FormatRDF is RSS 1.0, which is based on RDF.
+	FormatRDF
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatRSS:
+		return "RSS"
+	case FormatAtom:
+		return "Atom"
+	case FormatRDF:
+		return "RSS 1.0"
+	}
+	return "unknown"
+}
+
+// ReadWithFormat is like Read, but it also returns the format of the feed.
+func ReadWithFormat(r io.Reader) (Feed, Format, error) {
+	return readWithFormat(context.Background(), r, ReadOptions{})
+}
+
+func readWithFormat(ctx context.Context, r io.Reader, opts ReadOptions) (Feed, Format, error) {
+	d := NewDecoder(r)
+	var badTimes ErrBadTimes
+	var entries []Entry
+	// Newest is the newest time of the kept entries; the decoder's
+	// newest time also counts the entries dropped here.
+	var newest time.Time
+	seen := make(map[string]bool)
+	for {
+		if err := ctx.Err(); err != nil {
+			return Feed{}, FormatUnknown, err
+		}
+		e, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		bts, ok := err.(ErrBadTimes)
+		if err != nil && !ok {
+			return Feed{}, FormatUnknown, err
+		}
+		if opts.MaxEntries > 0 && len(entries) >= opts.MaxEntries {
+			continue
+		}
+		if k := e.key(); opts.Dedup && k != "" {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		badTimes = append(badTimes, bts...)
+		opts.apply(&e)
+		if e.When.After(newest) {
+			newest = e.When
+		}
+		entries = append(entries, e)
+	}
+	f, err := d.feed()
+	if err != nil {
+		badTimes = append(ErrBadTimes{err.(BadTime)}, badTimes...)
+	}
+	if d.header.Updated.IsZero() {
+		f.Updated = newest
+	}
+	if d.Format() == FormatUnknown && len(entries) == 0 {
+		return Feed{}, FormatUnknown, ErrNotAFeed
+	}
+	f.Entries = entries
+	if len(badTimes) > 0 {
+		return f, d.Format(), badTimes
+	}
+	return f, d.Format(), nil
+}
+
+// ReadAll reads a stream of concatenated feed documents from an io.Reader
+// and returns a Feed for each. Anything between the documents that is not
+// an element, such as whitespace, comments, or an XML declaration, is
+// ignored. If an error is encountered, the feeds read so far are returned
+// along with the error.
+//
+// As with Read, ReadAll may return the non-fatal error ErrBadTimes, in
+// which case all of the feeds were read.
+func ReadAll(r io.Reader) ([]Feed, error) {
+	var feeds []Feed
+	var badTimes ErrBadTimes
+	d, err := newXMLDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var f feed
+		switch err := d.Decode(&f); {
+		case err == io.EOF:
+			if len(badTimes) > 0 {
+				return feeds, badTimes
+			}
+			return feeds, nil
+		case err != nil:
+			return feeds, err
+		}
+		cf, err := cleanFeed(f)
+		if bts, ok := err.(ErrBadTimes); ok {
+			badTimes = append(badTimes, bts...)
+		} else if err != nil {
+			return feeds, err
+		}
+		feeds = append(feeds, cf)
+	}
+}
+
+// NewXMLDecoder returns an XML decoder for r, after skipping a leading
+// byte-order mark.
+func newXMLDecoder(r io.Reader) (*xml.Decoder, error) {
+	r, err := skipBOM(r)
+	if err != nil {
+		return nil, err
+	}
 	d := xml.NewDecoder(r)
 	d.CharsetReader = charsetReader
-	if err := d.Decode(&f); err != nil {
-		return Feed{}, err
-	}
-	if f.Rss.Title != "" {
+	return d, nil
+}
+
+// CleanFeed returns the exported Feed for the unmarshalled feed.
+func cleanFeed(f feed) (Feed, error) {
+	switch f.format() {
+	case FormatRSS:
 		return rssFeed(f.Rss)
+	case FormatRDF:
+		return rssFeed(f.rdfChannel())
+	case FormatUnknown:
+		if isHTML(f.XMLName) || len(f.Entries) == 0 {
+			return Feed{}, ErrNotAFeed
+		}
 	}
 	return atomFeed(f)
 }
 
+// CharsetReader returns a reader that transcodes the given character set
+// into UTF-8. It is used by the XML decoder for non-UTF-8 documents.
 func charsetReader(charset string, r io.Reader) (io.Reader, error) {
-	if charset == "ISO-8859-1" || charset == "iso-8859-1" {
+	switch strings.ToLower(charset) {
+	case "us-ascii", "ascii":
 		return r, nil
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1":
+		return newCharmapReader(r, nil), nil
+	case "windows-1252", "cp1252":
+		return newCharmapReader(r, &windows1252), nil
 	}
 	return nil, errors.New("Unsupported character set encoding: " + charset)
 }
 
+// Canonical returns the URL that best identifies the feed: its self link
+// if it has one, otherwise the link to its website.
+func (f Feed) Canonical() string {
+	if f.Self != "" {
+		return f.Self
+	}
+	return f.Link
+}
+
+// Dedup removes each entry whose key, its ID or else its Link, is the
+// same as that of an earlier entry. Entries with neither an ID nor a Link
+// are kept. The order of the remaining entries is unchanged.
+func (f *Feed) Dedup() {
+	seen := make(map[string]bool)
+	entries := f.Entries[:0]
+	for _, e := range f.Entries {
+		if k := e.key(); k != "" {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		entries = append(entries, e)
+	}
+	f.Entries = entries
+}
+
+// Key returns the entry's ID, or its Link if it has no ID.
+func (e Entry) key() string {
+	if e.ID != "" {
+		return e.ID
+	}
+	return e.Link
+}
+
+// SetUpdated sets Updated to the newest entry time if the feed did not
+// give an updated time of its own.
+func (f *Feed) setUpdated() {
+	if !f.Updated.IsZero() {
+		return
+	}
+	for _, e := range f.Entries {
+		if e.When.After(f.Updated) {
+			f.Updated = e.When
+		}
+	}
+}
+
 // ErrBadTime is a string containing a time that was not parsable.
 type ErrBadTime string
 
@@ -59,29 +375,105 @@ func (e ErrBadTime) Error() string {
 	return "Unable to parse time: " + string(e)
 }
 
-func rssFeed(r rss) (Feed, error) {
-	updated, err := rssTime(r.Updated)
-	f := Feed{
-		Title:   r.Title,
-		Link:    r.link(),
-		Updated: updated,
+// A BadTime is an unparsable time along with the title of the entry in
+// which it was found. Title is empty for the feed's own time.
+type BadTime struct {
+	Title string
+	Time  ErrBadTime
+}
+
+func (b BadTime) Error() string {
+	if b.Title == "" {
+		return b.Time.Error()
 	}
+	return b.Title + ": " + b.Time.Error()
+}
+
+// ErrBadTimes is a non-fatal error listing the unparsable times in a feed.
+type ErrBadTimes []BadTime
 
+func (es ErrBadTimes) Error() string {
+	s := make([]string, len(es))
+	for i, e := range es {
+		s[i] = e.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+func rssFeed(r rss) (Feed, error) {
+	var badTimes ErrBadTimes
+	f, err := rssHeader(r)
+	if err != nil {
+		badTimes = append(badTimes, err.(BadTime))
+	}
 	for _, it := range r.Items {
-		when, e := rssTime(it.Updated)
-		if err == nil && e != nil {
-			err = e
-		}
-		ent := Entry{
-			Title:   it.Title,
-			Link:    it.Link,
-			Summary: fixHtml(it.Description),
-			Content: fixHtml(it.Content.Data),
-			When:    when,
+		ent, err := rssEntry(it, f.Link)
+		if err != nil {
+			badTimes = append(badTimes, err.(BadTime))
 		}
 		f.Entries = append(f.Entries, ent)
 	}
-	return f, err
+	f.setUpdated()
+	if len(badTimes) > 0 {
+		return f, badTimes
+	}
+	return f, nil
+}
+
+// RssHeader returns the Feed, without entries, for an RSS channel. If
+// the channel's time is unparsable, a BadTime error is also returned.
+func rssHeader(r rss) (Feed, error) {
+	updated, err := rssTime(firstNonEmpty(r.Updated, r.DcDate))
+	f := Feed{
+		Title:    strings.TrimSpace(html.UnescapeString(r.Title)),
+		Link:     strings.TrimSpace(r.link()),
+		Self:     strings.TrimSpace(selfLink(r.AtomLinks)),
+		Hubs:     hubLinks(nil, r.AtomLinks),
+		Updated:  updated,
+		Authors:  authors(r.DcCreator),
+		Image:    strings.TrimSpace(r.Image.URL),
+		Language: strings.TrimSpace(firstNonEmpty(r.Language, r.DcLanguage)),
+		Rights:   strings.TrimSpace(firstNonEmpty(r.Copyright, r.DcRights)),
+		Blocked:  itunesYes(r.ItunesBlock),
+		Complete: itunesYes(r.ItunesComplete),
+		Podcast:  r.podcast(),
+	}
+	if err != nil {
+		return f, BadTime{Time: err.(ErrBadTime)}
+	}
+	return f, nil
+}
+
+// RssEntry returns the Entry for an RSS item of a feed with the given
+// link. If the item's time is unparsable, a BadTime error is also
+// returned.
+func rssEntry(it rssItem, link string) (Entry, error) {
+	when, err := rssTime(firstNonEmpty(it.Updated, it.DcDate))
+	ent := Entry{
+		ID:         strings.TrimSpace(it.Guid.Value),
+		Title:      strings.TrimSpace(html.UnescapeString(it.Title)),
+		Link:       strings.TrimSpace(firstNonEmpty(it.Link, it.Guid.permaLink())),
+		Summary:    resolveURLs(link, fixHtml(it.Description)),
+		Content:    resolveURLs(link, fixHtml(it.Content.Data)),
+		When:       when,
+		Published:  when,
+		Authors:    authors(it.Author, it.DcCreator),
+		Categories: categories(it.Categories),
+		Extensions: extensions(it.Extensions, rdfRssNamespace),
+	}
+	if ent.ID == "" {
+		ent.ID = firstNonEmpty(strings.TrimSpace(it.About), ent.Link)
+	}
+	for _, enc := range it.Enclosures {
+		ent.Enclosures = append(ent.Enclosures, enc.enclosure())
+	}
+	ent.Media, ent.Thumbnails = it.media()
+	ent.Podcast = it.podcast()
+	ent.setTruncated()
+	if err != nil {
+		return ent, BadTime{Title: ent.Title, Time: err.(ErrBadTime)}
+	}
+	return ent, nil
 }
 
 // RssTimeFormats is a slice of various time formats encountered in the wild.
@@ -89,7 +481,19 @@ var rssTimeFormats = []string{
 	"Mon, 2 Jan 2006 15:04:05 -0700",
 	"Mon, 2 Jan 2006 15:04:05 MST",
 	"Mon, 2 Jan 06 15:04:05 -0700",
+	"Mon, 02 Jan 2006 15:04:05 -0700",
 	"02 January 2006",
+	"2006-01-02 15:04:05",
+
+	// RFC 3339, also with no colon in the zone offset.
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+
+	// The remaining W3C date and time formats.
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
 }
 
 // RssTime tries parsing a string using a variety of different time formats.
@@ -101,50 +505,237 @@ func rssTime(s string) (time.Time, error) {
 
 	for _, f := range rssTimeFormats {
 		if t, err := time.Parse(f, s); err == nil {
-			return t, nil
+			return fixZone(t), nil
 		}
 	}
 
 	return time.Time{}, ErrBadTime(s)
 }
 
-func atomFeed(a feed) (Feed, error) {
-	f := Feed{
-		Title:   a.Title,
-		Link:    a.link(),
-		Updated: a.Updated,
+// ZoneOffsets maps common time zone abbreviations to their offsets from
+// UTC in seconds. Some abbreviations are ambiguous; they are resolved to a
+// best-effort fixed zone: CST is US Central and IST is India.
+var zoneOffsets = map[string]int{
+	"UT":   0,
+	"UTC":  0,
+	"GMT":  0,
+	"Z":    0,
+	"EST":  -5 * 60 * 60,
+	"EDT":  -4 * 60 * 60,
+	"CST":  -6 * 60 * 60,
+	"CDT":  -5 * 60 * 60,
+	"MST":  -7 * 60 * 60,
+	"MDT":  -6 * 60 * 60,
+	"PST":  -8 * 60 * 60,
+	"PDT":  -7 * 60 * 60,
+	"AKST": -9 * 60 * 60,
+	"AKDT": -8 * 60 * 60,
+	"HST":  -10 * 60 * 60,
+	"WET":  0,
+	"WEST": 1 * 60 * 60,
+	"BST":  1 * 60 * 60,
+	"CET":  1 * 60 * 60,
+	"CEST": 2 * 60 * 60,
+	"EET":  2 * 60 * 60,
+	"EEST": 3 * 60 * 60,
+	"MSK":  3 * 60 * 60,
+	"IST":  5*60*60 + 30*60,
+	"SGT":  8 * 60 * 60,
+	"HKT":  8 * 60 * 60,
+	"AWST": 8 * 60 * 60,
+	"JST":  9 * 60 * 60,
+	"KST":  9 * 60 * 60,
+	"ACST": 9*60*60 + 30*60,
+	"AEST": 10 * 60 * 60,
+	"AEDT": 11 * 60 * 60,
+	"NZST": 12 * 60 * 60,
+	"NZDT": 13 * 60 * 60,
+}
+
+// FixZone corrects the offset of a time parsed with a zone abbreviation.
+// The time package gives unknown abbreviations a zero offset, so if the
+// abbreviation is in zoneOffsets, the time is moved to that offset.
+func fixZone(t time.Time) time.Time {
+	name, off := t.Zone()
+	if off != 0 {
+		return t
+	}
+	o, ok := zoneOffsets[name]
+	if !ok || o == 0 {
+		return t
 	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(),
+		time.FixedZone(name, o))
+}
 
+func atomFeed(a feed) (Feed, error) {
+	f := atomHeader(a)
+	base := xmlBase(nil, a.XmlBase)
 	for _, ent := range a.Entries {
-		e := Entry{
-			Title:   ent.Title,
-			Link:    ent.Link.Href,
-			Summary: fixHtml(ent.Summary),
-			When:    ent.Updated,
-		}
-		if len(ent.Content) > 0 {
-			e.Content = fixHtml(ent.Content[0].Data())
-		}
-		f.Entries = append(f.Entries, e)
+		f.Entries = append(f.Entries, ent.entry(base, f.Link))
 	}
+	f.setUpdated()
 	return f, nil
 }
 
+// AtomHeader returns the Feed, without entries, for an Atom feed.
+func atomHeader(a feed) Feed {
+	base := xmlBase(nil, a.XmlBase)
+	return Feed{
+		Title:    a.Title.plainText(),
+		Link:     resolveURL(base, strings.TrimSpace(a.link())),
+		Self:     resolveURL(base, strings.TrimSpace(selfLink(a.Links))),
+		Hubs:     hubLinks(base, a.Links),
+		Updated:  a.Updated,
+		Authors:  authors(a.Author),
+		Image:    resolveURL(base, strings.TrimSpace(a.Logo)),
+		Icon:     resolveURL(base, strings.TrimSpace(a.Icon)),
+		Language: strings.TrimSpace(a.XmlLang),
+		Rights:   plainText(a.Rights.Data()),
+		Blocked:  itunesYes(a.ItunesBlock),
+		Complete: itunesYes(a.ItunesComplete),
+	}
+}
+
+// Entry returns the Entry for an Atom entry of a feed with the given
+// xml:base and link.
+func (ent atomEntry) entry(feedBase *url.URL, link string) Entry {
+	base := xmlBase(feedBase, ent.XmlBase)
+	// Content is resolved against the entry's base if it is
+	// absolute, and against the feed's website if not.
+	contentBase := link
+	if base != nil && base.IsAbs() {
+		contentBase = base.String()
+	}
+	e := Entry{
+		ID:         strings.TrimSpace(ent.Id),
+		Title:      ent.Title.plainText(),
+		Link:       resolveURL(base, strings.TrimSpace(entryLink(ent.Links))),
+		Summary:    resolveURLs(contentBase, fixHtml(ent.Summary.html())),
+		When:       ent.Updated,
+		Published:  ent.Published,
+		Authors:    authors(ent.Author),
+		Categories: categories(atomCategoryTerms(ent.Categories)),
+		Extensions: extensions(ent.Extensions, atomNamespace),
+	}
+	if len(ent.Content) > 0 {
+		e.Content = resolveURLs(contentBase, fixHtml(ent.Content[0].html()))
+	}
+	if e.When.IsZero() {
+		e.When = e.Published
+	}
+	if e.ID == "" {
+		e.ID = e.Link
+	}
+	for _, l := range ent.Links {
+		if l.Rel == "enclosure" {
+			e.Enclosures = append(e.Enclosures, Enclosure{
+				URL:    resolveURL(base, strings.TrimSpace(l.Href)),
+				Type:   strings.TrimSpace(l.Type),
+				Length: parseLength(l.Length),
+			})
+		}
+	}
+	e.Media, e.Thumbnails = ent.media()
+	e.setTruncated()
+	return e
+}
+
 // Feed is an intermediate representation used to unmarshall the XML;
 // it can represent both an Atom feed an an RSS feed.  After unmarshalling
 // this information is moved into a more "clean" format: the exported Feed.
 type feed struct {
-	Title   string      `xml:"title"`
+	XMLName xml.Name
+	XmlBase string      `xml:"base,attr"`
+	XmlLang string      `xml:"lang,attr"`
+	Title   atomText    `xml:"title"`
 	Links   []atomLink  `xml:"link"`
 	Updated time.Time   `xml:"updated"`
 	Author  []string    `xml:"author>name"`
 	Id      string      `xml:"id"`
+	Logo    string      `xml:"logo"`
+	Icon    string      `xml:"icon"`
+	Rights  atomText    `xml:"rights"`
 	Entries []atomEntry `xml:"entry"`
 	Rss     rss         `xml:"channel"`
+	// RdfItems and RdfImage are the items and image of an RSS 1.0 feed.
+	RdfItems []rssItem `xml:"item"`
+	RdfImage rssImage  `xml:"image"`
+
+	ItunesBlock    string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd block"`
+	ItunesComplete string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd complete"`
+}
+
+// SelfLink returns the href of the first rel="self" link.
+func selfLink(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "self" {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+// HubLinks returns the hrefs of the rel="hub" links, resolved against
+// base, without duplicates.
+func hubLinks(base *url.URL, links []atomLink) []string {
+	var hubs []string
+	seen := make(map[string]bool)
+	for _, l := range links {
+		if l.Rel != "hub" || strings.TrimSpace(l.Href) == "" {
+			continue
+		}
+		h := resolveURL(base, strings.TrimSpace(l.Href))
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		hubs = append(hubs, h)
+	}
+	return hubs
+}
+
+// Format returns the format of the unmarshalled feed, judging by its root
+// element, or by the presence of an RSS channel if the root is unexpected.
+func (f *feed) format() Format {
+	switch {
+	case f.XMLName.Local == "rss":
+		return FormatRSS
+	case f.XMLName.Local == "feed":
+		return FormatAtom
+	case f.XMLName.Local == "RDF":
+		return FormatRDF
+	case f.Rss.Title != "" || len(f.Rss.Items) > 0:
+		return FormatRSS
+	}
+	return FormatUnknown
+}
+
+// RdfChannel returns the channel of an RSS 1.0 feed. RSS 1.0 items and
+// images are siblings of the channel, not children, so they are added to
+// it.
+func (f *feed) rdfChannel() rss {
+	r := f.Rss
+	r.Items = append(r.Items, f.RdfItems...)
+	if r.Image.URL == "" {
+		r.Image = f.RdfImage
+	}
+	return r
+}
+
+// IsHTML returns whether the name is that of an HTML root element.
+func isHTML(name xml.Name) bool {
+	return strings.ToLower(name.Local) == "html"
 }
 
 func (f *feed) link() string {
-	for _, l := range f.Links {
+	return alternateLink(f.Links)
+}
+
+// AlternateLink returns the href of the first link with an empty or
+// "alternate" rel.
+func alternateLink(links []atomLink) string {
+	for _, l := range links {
 		if l.Rel == "" || l.Rel == "alternate" {
 			return l.Href
 		}
@@ -152,50 +743,207 @@ func (f *feed) link() string {
 	return ""
 }
 
+// EntryLink returns the href of an entry's alternate link. If it has
+// none, the href of its first link that is not an enclosure is returned,
+// or else that of its first link, which is what entries linked to before
+// rels were considered.
+func entryLink(links []atomLink) string {
+	if href := alternateLink(links); href != "" {
+		return href
+	}
+	for _, l := range links {
+		if l.Rel != "enclosure" {
+			return l.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
 type atomEntry struct {
-	Title   string        `xml:"title"`
-	Link    atomLink      `xml:"link"`
-	Id      string        `xml:"id"`
-	Updated time.Time     `xml:"updated"`
-	Author  []string      `xml:"author>name"`
-	Summary []byte        `xml:"summary"`
-	Content []atomContent `xml:"content"`
+	// MediaElements must precede Title and Content so that, for
+	// example, <media:content> is not unmarshalled as Atom content.
+	mediaElements
+
+	XmlBase   string     `xml:"base,attr"`
+	Title     atomText   `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Id        string     `xml:"id"`
+	Updated   time.Time  `xml:"updated"`
+	Published time.Time  `xml:"published"`
+	Author    []string   `xml:"author>name"`
+	Summary   atomText   `xml:"summary"`
+	Content   []atomText `xml:"content"`
+
+	Categories []atomCategory `xml:"category"`
+
+	Extensions []extension `xml:",any"`
 }
 
 type atomLink struct {
-	Rel  string `xml:"rel,attr"`
-	Href string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
 }
 
-type atomContent struct {
-	Type     string `xml:"type,attr"`
-	Contents []byte `xml:",innerxml"`
+// AtomCategoryTerms returns the terms of the categories.
+func atomCategoryTerms(cs []atomCategory) []string {
+	terms := make([]string, len(cs))
+	for i, c := range cs {
+		terms[i] = c.Term
+	}
+	return terms
+}
+
+// An atomText is an Atom text construct, such as a summary, or an Atom
+// content element.
+type atomText struct {
+	Type string `xml:"type,attr"`
+	// Text is the character data of the element, with entities
+	// and CDATA sections decoded, and Inner is its raw inner XML.
+	Text  []byte `xml:",chardata"`
+	Inner []byte `xml:",innerxml"`
+}
+
+// Data returns the HTML of the text. Type "html" is escaped HTML, which
+// is unescaped, and type "xhtml" is inline XHTML markup, which is used
+// as is. Any other type is plain text, which is escaped.
+func (c atomText) Data() []byte {
+	switch strings.ToLower(strings.TrimSpace(c.Type)) {
+	case "html", "text/html":
+		return c.Text
+	case "xhtml", "application/xhtml+xml":
+		return c.Inner
+	}
+	return []byte(html.EscapeString(string(c.Text)))
+}
+
+// HTML returns the HTML of a summary or content. Unlike Data, a text
+// without a type is taken to be escaped HTML rather than plain text,
+// since many feeds put escaped HTML in untyped content and summaries.
+// Only an explicit "text" type is escaped.
+func (c atomText) html() []byte {
+	if strings.TrimSpace(c.Type) == "" {
+		return c.Text
+	}
+	return c.Data()
 }
 
-func (c atomContent) Data() []byte {
-	unesc := c.Contents
-	if c.Type != "xhtml" {
-		unesc = []byte(html.UnescapeString(string(c.Contents)))
+// PlainText returns the text as plain text, such as for a title. HTML
+// and XHTML markup is removed. Plain text is unescaped, because many
+// feeds escape the entities of their titles twice.
+func (c atomText) plainText() string {
+	switch strings.ToLower(strings.TrimSpace(c.Type)) {
+	case "html", "text/html", "xhtml", "application/xhtml+xml":
+		return plainText(c.Data())
 	}
-	return unesc
+	return strings.TrimSpace(html.UnescapeString(string(c.Text)))
 }
 
 type rss struct {
-	Title       string    `xml:"title"`
-	Links       []string  `xml:"link"`
-	Description []byte    `xml:"description"`
-	Items       []rssItem `xml:"item"`
+	// ItunesElements must precede Title so that <itunes:title> is not
+	// unmarshalled as the title.
+	itunesElements
+
+	Title string `xml:"title"`
+	// AtomLinks must precede Links so that <atom:link> elements are
+	// not unmarshalled as plain RSS links.
+	AtomLinks   []atomLink `xml:"http://www.w3.org/2005/Atom link"`
+	Links       []string   `xml:"link"`
+	Description []byte     `xml:"description"`
+	Image       rssImage   `xml:"image"`
+	Items       []rssItem  `xml:"item"`
+
+	// The Dublin Core elements, used by RSS 1.0, must precede
+	// Language and Copyright so that they are not unmarshalled as them.
+	DcLanguage string `xml:"http://purl.org/dc/elements/1.1/ language"`
+	DcRights   string `xml:"http://purl.org/dc/elements/1.1/ rights"`
+	Language   string `xml:"language"`
+	Copyright  string `xml:"copyright"`
 
 	// RSS uses its own time format (not understood by the XML parser, because it
 	// is apparently a different format from all of the rest of XML in all the land).  We
 	// read it as a string and parse it later.
 
 	Updated string `xml:"pubDate"`
+	// DcDate is the Dublin Core date, in W3CDTF format, used if there
+	// is no pubDate.
+	DcDate string `xml:"http://purl.org/dc/elements/1.1/ date"`
+
+	DcCreator []string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+
+	ItunesBlock    string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd block"`
+	ItunesComplete string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd complete"`
+}
+
+// An rssImage is the image of an RSS channel. It is not read as the path
+// image>url because a path would also consume <itunes:image>.
+type rssImage struct {
+	URL string `xml:"url"`
+}
+
+// Authors returns the trimmed, non-empty author names from the lists,
+// without duplicates.
+func authors(lists ...[]string) []string {
+	var as []string
+	seen := make(map[string]bool)
+	for _, l := range lists {
+		for _, a := range l {
+			a = strings.TrimSpace(a)
+			if a == "" || seen[a] {
+				continue
+			}
+			seen[a] = true
+			as = append(as, a)
+		}
+	}
+	return as
+}
+
+// Categories returns the trimmed, non-empty categories, without
+// duplicates. Categories that differ only by case are duplicates; the
+// first spelling is kept.
+func categories(cs []string) []string {
+	var cats []string
+	seen := make(map[string]bool)
+	for _, c := range cs {
+		c = strings.TrimSpace(c)
+		k := strings.ToLower(c)
+		if c == "" || seen[k] {
+			continue
+		}
+		seen[k] = true
+		cats = append(cats, c)
+	}
+	return cats
+}
+
+// FirstNonEmpty returns the first of its arguments that is not empty
+// or only whitespace.
+func firstNonEmpty(ss ...string) string {
+	for _, s := range ss {
+		if strings.TrimSpace(s) != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// ItunesYes returns true if the value of an iTunes flag element is "yes".
+func itunesYes(s string) bool {
+	return strings.EqualFold(strings.TrimSpace(s), "yes")
 }
 
 func (r rss) link() string {
 	for _, l := range r.Links {
-		if l != "" {
+		if strings.TrimSpace(l) != "" {
 			return l
 		}
 	}
@@ -203,19 +951,175 @@ func (r rss) link() string {
 }
 
 type rssItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description []byte `xml:"description"`
+	// The namespaced elements must precede Title and Author so that,
+	// for example, <itunes:title> is not unmarshalled as the title.
+	mediaElements
+	itunesElements
+
+	// About is the rdf:about URI identifying an RSS 1.0 item.
+	About       string  `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description []byte  `xml:"description"`
+	Guid        rssGuid `xml:"guid"`
+
+	Enclosures []rssEnclosure `xml:"enclosure"`
 
 	// Content contains <content:encoded>, an extension used by Ars Technica's feeds.
-	Content rssContent `xml:"content encoded"`
+	Content rssContent `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
 	Updated string     `xml:"pubDate"`
+	// DcDate is the Dublin Core date, in W3CDTF format, used if there
+	// is no pubDate.
+	DcDate string `xml:"http://purl.org/dc/elements/1.1/ date"`
+
+	// Author is the RSS author, usually an email address, and
+	// DcCreator is the Dublin Core creator, usually a name.
+	Author    []string `xml:"author"`
+	DcCreator []string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+
+	// Categories are the text of the category elements; their domain
+	// attributes are ignored.
+	Categories []string `xml:"category"`
+
+	Extensions []extension `xml:",any"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+func (e rssEnclosure) enclosure() Enclosure {
+	return Enclosure{
+		URL:    strings.TrimSpace(e.URL),
+		Type:   strings.TrimSpace(e.Type),
+		Length: parseLength(e.Length),
+	}
+}
+
+// ParseNonNegInt returns the non-negative integer given by s, such as a
+// media width or an episode number, or 0 if it is missing or malformed.
+func parseNonNegInt(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// ParseLength returns the byte length given by an enclosure's length
+// attribute, or 0 if it is missing or malformed.
+func parseLength(s string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+type rssGuid struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink string `xml:"isPermaLink,attr"`
+}
+
+// PermaLink returns the guid if it is a permalink to the item, and the
+// empty string otherwise. The guid is a permalink unless isPermaLink is
+// "false", but since many feeds omit the attribute from guids that are
+// not URLs, only absolute HTTP URLs are returned.
+func (g rssGuid) permaLink() string {
+	if strings.TrimSpace(strings.ToLower(g.IsPermaLink)) == "false" {
+		return ""
+	}
+	u, err := url.Parse(strings.TrimSpace(g.Value))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
 }
 
 type rssContent struct {
 	Data []byte `xml:",chardata"`
 }
 
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// RdfRssNamespace is the namespace of RSS 1.0 elements. RSS 2.0 elements
+// have no namespace.
+const rdfRssNamespace = "http://purl.org/rss/1.0/"
+
+// ExtensionPrefixes maps well-known namespace URIs to the prefix
+// conventionally used for them.
+var extensionPrefixes = map[string]string{
+	"http://purl.org/dc/elements/1.1/":             "dc",
+	"http://purl.org/dc/terms/":                    "dcterms",
+	"http://purl.org/rss/1.0/modules/slash/":       "slash",
+	"http://purl.org/rss/1.0/modules/content/":     "content",
+	"http://purl.org/rss/1.0/modules/syndication/": "sy",
+	"http://wellformedweb.org/CommentAPI/":         "wfw",
+	"http://search.yahoo.com/mrss/":                "media",
+	"http://www.itunes.com/dtds/podcast-1.0.dtd":   "itunes",
+	"http://www.georss.org/georss":                 "georss",
+	"http://purl.org/syndication/thread/1.0":       "thr",
+	atomNamespace:                                  "atom",
+}
+
+// An extension is an element that is not otherwise understood. Entry
+// fields collect extensions with an ",any" field, so that the elements
+// they do understand are decoded normally, and each extension is then
+// decoded token by token.
+type extension struct {
+	XMLName xml.Name
+	Data    string
+}
+
+// UnmarshalXML records the name of the element and all of the text
+// within it, including that of any elements nested in it, which a
+// ",chardata" field would drop.
+func (e *extension) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	e.XMLName = start.Name
+	var text bytes.Buffer
+	for depth := 0; ; {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := t.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				e.Data = text.String()
+				return nil
+			}
+			depth--
+		}
+	}
+}
+
+// Extensions returns a map of the namespaced extension elements, ignoring
+// those in the feed's own namespace, ns.
+func extensions(exts []extension, ns string) map[string][]string {
+	var m map[string][]string
+	for _, e := range exts {
+		space := e.XMLName.Space
+		if space == ns || space == "" {
+			continue
+		}
+		if p, ok := extensionPrefixes[space]; ok {
+			space = p
+		}
+		if m == nil {
+			m = make(map[string][]string)
+		}
+		k := space + ":" + e.XMLName.Local
+		m[k] = append(m[k], strings.TrimSpace(e.Data))
+	}
+	return m
+}
+
 // FixHtml parses bytes as HTML and returns well-formed HTML if the parse
 // was successful, or escaped HTML, if not.
 func fixHtml(wild []byte) (well []byte) {
@@ -231,23 +1135,129 @@ func fixHtml(wild []byte) (well []byte) {
 			panic(err)
 		}
 	}()
-	buf := bytes.NewBuffer(make([]byte, 0, len(wild)*2))
-	if err := html.Render(buf, n); err != nil {
+	body := findElement(n, "body")
+	if body == nil {
 		return []byte(html.EscapeString(string(wild)))
 	}
-
-	well = buf.Bytes()
-	openBody := []byte("<body>")
-	i := bytes.Index(well, openBody)
-	if i < 0 {
+	well, err = renderChildren(body, len(wild)*2)
+	if err != nil {
 		return []byte(html.EscapeString(string(wild)))
 	}
-	well = well[i+len(openBody):]
+	return well
+}
 
-	closeBody := []byte("</body>")
-	i = bytes.Index(well, closeBody)
-	if i < 0 {
-		return []byte(html.EscapeString(string(wild)))
+// RenderChildren returns the rendered HTML of the children of n. Size is
+// a hint of the size of the result.
+func renderChildren(n *html.Node, size int) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	for k := n.FirstChild; k != nil; k = k.NextSibling {
+		if err := html.Render(buf, k); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// TruncatedLength is the plain-text length, in runes, below which an
+// entry's text is considered to be truncated.
+const truncatedLength = 300
+
+// TruncationMarkers are lower-case suffixes commonly used by publishers
+// to mark an excerpt of a longer article.
+var truncationMarkers = []string{
+	"…",
+	"...",
+	"[…]",
+	"[...]",
+	"read more",
+	"continue reading",
+}
+
+// SetTruncated sets TextLength and ContentTruncated from the entry's
+// Content, or from its Summary if it has no Content.
+func (e *Entry) setTruncated() {
+	data := e.Content
+	if len(data) == 0 {
+		data = e.Summary
+	}
+	text := plainText(data)
+	e.TextLength = utf8.RuneCountInString(text)
+	e.ContentTruncated = isTruncated(text)
+}
+
+// IsTruncated returns true if the plain text appears to be an excerpt:
+// either it is shorter than truncatedLength or it ends with one of the
+// truncationMarkers (ignoring trailing arrows and whitespace).
+func isTruncated(text string) bool {
+	if utf8.RuneCountInString(text) < truncatedLength {
+		return true
+	}
+	t := strings.ToLower(strings.TrimRight(text, " \t\r\n»›→>"))
+	for _, m := range truncationMarkers {
+		if strings.HasSuffix(t, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// TextSummary returns the plain text of an HTML fragment, with its
+// whitespace collapsed and the contents of script and style elements
+// omitted. If the text is longer than maxRunes runes, it is truncated and
+// ends with an ellipsis; the result, ellipsis included, is at most
+// maxRunes runes. If maxRunes is not positive, the text is not truncated.
+func TextSummary(htmlBytes []byte, maxRunes int) string {
+	text := plainText(htmlBytes)
+	if maxRunes <= 0 || utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+	runes := []rune(text)[:maxRunes-1]
+	return strings.TrimRight(string(runes), " ") + "…"
+}
+
+// TextBreakElements are the elements that separate the text before and
+// after them, even when there is no whitespace between the two. Text in
+// other elements, like b and i, runs on into the text around it.
+var textBreakElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"br": true, "caption": true, "dd": true, "details": true,
+	"div": true, "dl": true, "dt": true, "figcaption": true,
+	"figure": true, "footer": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "img": true, "li": true,
+	"main": true, "nav": true, "ol": true, "p": true,
+	"pre": true, "section": true, "summary": true, "table": true,
+	"td": true, "th": true, "tr": true, "ul": true,
+}
+
+// PlainText returns the text of an HTML fragment with its whitespace
+// collapsed, ignoring the contents of script and style elements. Adjacent
+// text is joined as it is displayed: words split by inline markup stay
+// whole, and only block elements and line breaks separate words.
+func plainText(h []byte) string {
+	n, err := html.Parse(bytes.NewReader(h))
+	if err != nil {
+		return strings.Join(strings.Fields(string(h)), " ")
+	}
+	var text bytes.Buffer
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		brk := n.Type == html.ElementNode && textBreakElements[n.Data]
+		switch {
+		case n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style"):
+			return
+		case n.Type == html.TextNode:
+			text.WriteString(n.Data)
+		case brk:
+			text.WriteByte(' ')
+		}
+		for k := n.FirstChild; k != nil; k = k.NextSibling {
+			walk(k)
+		}
+		if brk {
+			text.WriteByte(' ')
+		}
 	}
-	return well[:i]
+	walk(n)
+	return strings.Join(strings.Fields(text.String()), " ")
 }
@@ -0,0 +1,245 @@
+package webfeed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRssCDATAWhitespace(t *testing.T) {
+	const data = `<?xml version="1.0"?>
+<rss version="2.0">
+<channel>
+<title><![CDATA[
+	Whitespace ]]></title>
+<link>
+</link>
+<link><![CDATA[ http://x/ ]]></link>
+<item>
+	<title><![CDATA[
+		An Item
+	]]></title>
+	<link><![CDATA[
+  http://x/
+ ]]></link>
+</item>
+</channel>
+</rss>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Title != "Whitespace" {
+		t.Errorf("Expected feed title [Whitespace], got [%s]", f.Title)
+	}
+	if f.Link != "http://x/" {
+		t.Errorf("Expected feed link [http://x/], got [%s]", f.Link)
+	}
+	e := f.Entries[0]
+	if e.Title != "An Item" {
+		t.Errorf("Expected entry title [An Item], got [%s]", e.Title)
+	}
+	if e.Link != "http://x/" {
+		t.Errorf("Expected entry link [http://x/], got [%s]", e.Link)
+	}
+}
+
+func TestAtomWhitespace(t *testing.T) {
+	const data = `<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+<title>
+	Whitespace
+</title>
+<entry>
+	<title>
+		An Entry
+	</title>
+	<link href=" http://x/
+	"/>
+</entry>
+</feed>`
+
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Title != "Whitespace" {
+		t.Errorf("Expected feed title [Whitespace], got [%s]", f.Title)
+	}
+	e := f.Entries[0]
+	if e.Title != "An Entry" {
+		t.Errorf("Expected entry title [An Entry], got [%s]", e.Title)
+	}
+	if e.Link != "http://x/" {
+		t.Errorf("Expected entry link [http://x/], got [%s]", e.Link)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name, data, canonical string
+	}{
+		{
+			name: "Atom self only",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title>
+				<link rel="self" href="http://x/atom.xml"/></feed>`,
+			canonical: "http://x/atom.xml",
+		},
+		{
+			name: "Atom alternate only",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title>
+				<link rel="alternate" href="http://x/"/></feed>`,
+			canonical: "http://x/",
+		},
+		{
+			name: "Atom both",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title>
+				<link href="http://x/"/><link rel="self" href="http://x/atom.xml"/></feed>`,
+			canonical: "http://x/atom.xml",
+		},
+		{
+			name: "RSS link only",
+			data: `<rss version="2.0"><channel><title>T</title>
+				<link>http://x/</link></channel></rss>`,
+			canonical: "http://x/",
+		},
+		{
+			name: "RSS atom:link self",
+			data: `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>T</title>
+				<atom:link rel="self" href="http://x/rss.xml"/>
+				<link>http://x/</link></channel></rss>`,
+			canonical: "http://x/rss.xml",
+		},
+	}
+
+	for _, test := range tests {
+		f, err := Read(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if c := f.Canonical(); c != test.canonical {
+			t.Errorf("%s: expected canonical [%s], got [%s]", test.name, test.canonical, c)
+		}
+	}
+}
+
+func TestRssAtomLinkNotLink(t *testing.T) {
+	const data = `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>T</title>
+		<atom:link rel="self" href="http://x/rss.xml"/>
+		<link>http://x/</link></channel></rss>`
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Link != "http://x/" {
+		t.Errorf("Expected link [http://x/], got [%s]", f.Link)
+	}
+}
+
+func TestEntryID(t *testing.T) {
+	const rssData = `<rss version="2.0"><channel><title>T</title>
+<item><title>Permalink</title><link>http://x/1</link><guid>http://x/?p=1</guid></item>
+<item><title>Not permalink</title><link>http://x/2</link><guid isPermaLink="false"> tag:x,2013:2 </guid></item>
+<item><title>No guid</title><link>http://x/3</link></item>
+</channel></rss>`
+
+	f, err := Read(strings.NewReader(rssData))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	exp := []string{"http://x/?p=1", "tag:x,2013:2", "http://x/3"}
+	for i, e := range f.Entries {
+		if e.ID != exp[i] {
+			t.Errorf("Expected RSS entry %d to have ID [%s], got [%s]", i, exp[i], e.ID)
+		}
+	}
+
+	const atomData = `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title>
+<entry><title>Id</title><link href="http://x/1"/><id>urn:uuid:1</id></entry>
+<entry><title>No id</title><link href="http://x/2"/></entry>
+</feed>`
+
+	f, err = Read(strings.NewReader(atomData))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	exp = []string{"urn:uuid:1", "http://x/2"}
+	for i, e := range f.Entries {
+		if e.ID != exp[i] {
+			t.Errorf("Expected Atom entry %d to have ID [%s], got [%s]", i, exp[i], e.ID)
+		}
+	}
+}
+
+func TestGuidPermaLink(t *testing.T) {
+	const rssData = `<rss version="2.0"><channel><title>T</title>
+<item><title>Permalink</title><guid isPermaLink="true"> http://x/1 </guid></item>
+<item><title>Default permalink</title><guid>https://x/2</guid></item>
+<item><title>Not permalink</title><guid isPermaLink="false">http://x/3</guid></item>
+<item><title>Not a URL</title><guid>tag:x,2013:4</guid></item>
+<item><title>Link</title><link>http://x/5</link><guid>http://x/?p=5</guid></item>
+</channel></rss>`
+
+	f, err := Read(strings.NewReader(rssData))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	exp := []string{"http://x/1", "https://x/2", "", "", "http://x/5"}
+	if len(f.Entries) != len(exp) {
+		t.Fatalf("Expected %d entries, got %d", len(exp), len(f.Entries))
+	}
+	for i, e := range f.Entries {
+		if e.Link != exp[i] {
+			t.Errorf("Expected entry %d to have link [%s], got [%s]", i, exp[i], e.Link)
+		}
+	}
+}
+
+func TestReadWithFormat(t *testing.T) {
+	tests := []struct {
+		name, data string
+		format     Format
+		entries    int
+		err        error
+	}{
+		{
+			name:    "RSS",
+			data:    `<rss version="2.0"><channel><title>T</title><item><title>I</title></item></channel></rss>`,
+			format:  FormatRSS,
+			entries: 1,
+		},
+		{
+			name:    "RSS without a channel title",
+			data:    `<rss version="2.0"><channel><item><title>I</title></item></channel></rss>`,
+			format:  FormatRSS,
+			entries: 1,
+		},
+		{
+			name:    "Atom",
+			data:    `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title><entry><title>E</title></entry></feed>`,
+			format:  FormatAtom,
+			entries: 1,
+		},
+		{
+			name:   "unknown",
+			data:   `<html><body>Not a feed</body></html>`,
+			format: FormatUnknown,
+			err:    ErrNotAFeed,
+		},
+	}
+
+	for _, test := range tests {
+		f, format, err := ReadWithFormat(strings.NewReader(test.data))
+		if err != test.err {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+			continue
+		}
+		if format != test.format {
+			t.Errorf("%s: expected format %s, got %s", test.name, test.format, format)
+		}
+		if len(f.Entries) != test.entries {
+			t.Errorf("%s: expected %d entries, got %d", test.name, test.entries, len(f.Entries))
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package webfeed
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTrimWhitespace(t *testing.T) {
+	const rssData = `<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel>
+<title>
+	Feed&#160;
+</title>
+<link>
+	http://example.com/
+</link>
+<item>
+	<title><![CDATA[
+		Entry Title
+	]]></title>
+	<link><![CDATA[
+		http://example.com/1
+	]]></link>
+	<dc:creator>
+		Alice
+	</dc:creator>
+	<description> <![CDATA[ <p>Summary</p> ]]> </description>
+</item>
+</channel></rss>`
+
+	f, err := Read(strings.NewReader(rssData))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Title != "Feed" || f.Link != "http://example.com/" {
+		t.Errorf("Expected feed title [Feed] and link [http://example.com/], got [%s] and [%s]", f.Title, f.Link)
+	}
+	e := f.Entries[0]
+	if e.Title != "Entry Title" {
+		t.Errorf("Expected title [Entry Title], got [%q]", e.Title)
+	}
+	if e.Link != "http://example.com/1" {
+		t.Errorf("Expected link [http://example.com/1], got [%q]", e.Link)
+	}
+	if !reflect.DeepEqual(e.Authors, []string{"Alice"}) {
+		t.Errorf("Expected authors [Alice], got %q", e.Authors)
+	}
+	if !bytes.Contains(e.Summary, []byte("<p>Summary</p>")) {
+		t.Errorf("Unexpected summary [%s]", e.Summary)
+	}
+
+	const atomData = `<feed xmlns="http://www.w3.org/2005/Atom"><title> Feed </title>
+<entry>
+	<title>
+		Entry Title
+	</title>
+	<link href="
+		http://example.com/1 "/>
+	<author><name>
+		Alice
+	</name></author>
+</entry>
+</feed>`
+
+	f, err = Read(strings.NewReader(atomData))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Title != "Feed" {
+		t.Errorf("Expected feed title [Feed], got [%q]", f.Title)
+	}
+	e = f.Entries[0]
+	if e.Title != "Entry Title" || e.Link != "http://example.com/1" || !reflect.DeepEqual(e.Authors, []string{"Alice"}) {
+		t.Errorf("Expected the title, link, and authors to be trimmed, got %q, %q, and %q", e.Title, e.Link, e.Authors)
+	}
+}
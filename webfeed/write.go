@@ -0,0 +1,288 @@
+package webfeed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteAtom writes the feed to w as an Atom 1.0 document. Summaries and
+// content are written as escaped HTML. Atom requires an id and an updated
+// time for every entry; entries without an ID use their Link, and
+// entries without a time use the feed's Updated time.
+func (f Feed) WriteAtom(w io.Writer) error {
+	out := atomOutFeed{
+		Title:   f.Title,
+		ID:      f.Canonical(),
+		Updated: atomTime(f.Updated),
+		Authors: atomPersons(f.Authors),
+		Icon:    f.Icon,
+		Logo:    f.Image,
+		Rights:  f.Rights,
+		Lang:    f.Language,
+	}
+	if f.Link != "" {
+		out.Links = append(out.Links, atomOutLink{Rel: "alternate", Href: f.Link})
+	}
+	if f.Self != "" {
+		out.Links = append(out.Links, atomOutLink{Rel: "self", Href: f.Self})
+	}
+	for _, h := range f.Hubs {
+		out.Links = append(out.Links, atomOutLink{Rel: "hub", Href: h})
+	}
+	for _, e := range f.Entries {
+		out.Entries = append(out.Entries, atomOutEntryFor(e, f.Updated))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func atomOutEntryFor(e Entry, feedUpdated time.Time) atomOutEntry {
+	id := e.ID
+	if id == "" {
+		id = e.Link
+	}
+	updated := e.When
+	if updated.IsZero() {
+		updated = feedUpdated
+	}
+	out := atomOutEntry{
+		Title:   e.Title,
+		ID:      id,
+		Updated: atomTime(updated),
+		Authors: atomPersons(e.Authors),
+	}
+	if !e.Published.IsZero() {
+		out.Published = atomTime(e.Published)
+	}
+	if e.Link != "" {
+		out.Links = append(out.Links, atomOutLink{Rel: "alternate", Href: e.Link})
+	}
+	for _, enc := range e.Enclosures {
+		l := atomOutLink{Rel: "enclosure", Href: enc.URL, Type: enc.Type}
+		if enc.Length > 0 {
+			l.Length = strconv.FormatInt(enc.Length, 10)
+		}
+		out.Links = append(out.Links, l)
+	}
+	for _, c := range e.Categories {
+		out.Categories = append(out.Categories, atomOutCategory{Term: c})
+	}
+	if len(e.Summary) > 0 {
+		out.Summary = &atomOutText{Type: "html", Text: string(e.Summary)}
+	}
+	if len(e.Content) > 0 {
+		out.Content = &atomOutText{Type: "html", Text: string(e.Content)}
+	}
+	return out
+}
+
+// WriteRSS writes the feed to w as an RSS 2.0 document. Times are written
+// in the RFC 1123 format with a numeric zone, the entry ID is written as
+// the guid, and summaries and content containing markup are wrapped in
+// CDATA sections. Content is written as <content:encoded>.
+func (f Feed) WriteRSS(w io.Writer) error {
+	out := rssOutFeed{
+		Version: "2.0",
+		Channel: rssOutChannel{
+			Title:     f.Title,
+			Link:      f.Link,
+			Language:  f.Language,
+			Copyright: f.Rights,
+		},
+	}
+	c := &out.Channel
+	if f.Self != "" {
+		c.AtomLinks = append(c.AtomLinks, atomOutLink{Rel: "self", Href: f.Self, Type: "application/rss+xml"})
+	}
+	for _, h := range f.Hubs {
+		c.AtomLinks = append(c.AtomLinks, atomOutLink{Rel: "hub", Href: h})
+	}
+	if !f.Updated.IsZero() {
+		c.PubDate = f.Updated.Format(time.RFC1123Z)
+	}
+	c.Creators = f.Authors
+	if f.Image != "" {
+		c.Image = &rssOutImage{URL: f.Image, Title: f.Title, Link: f.Link}
+	}
+	for _, e := range f.Entries {
+		c.Items = append(c.Items, rssOutItemFor(e))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func rssOutItemFor(e Entry) rssOutItem {
+	out := rssOutItem{
+		Title:      e.Title,
+		Link:       e.Link,
+		Creators:   e.Authors,
+		Categories: e.Categories,
+	}
+	if e.ID != "" {
+		out.Guid = &rssOutGuid{ID: e.ID, IsPermaLink: strconv.FormatBool(e.ID == e.Link)}
+	}
+	if !e.When.IsZero() {
+		out.PubDate = e.When.Format(time.RFC1123Z)
+	}
+	if len(e.Summary) > 0 {
+		out.Description = rssOutTextFor(e.Summary)
+	}
+	if len(e.Content) > 0 {
+		out.Content = rssOutTextFor(e.Content)
+	}
+	for _, enc := range e.Enclosures {
+		out.Enclosures = append(out.Enclosures, rssOutEnclosure{
+			URL:    enc.URL,
+			Type:   enc.Type,
+			Length: strconv.FormatInt(enc.Length, 10),
+		})
+	}
+	return out
+}
+
+// RssOutTextFor returns the text, wrapped in a CDATA section if it
+// contains markup.
+func rssOutTextFor(h []byte) *rssOutText {
+	if bytes.ContainsAny(h, "<>&") {
+		return &rssOutText{CDATA: string(h)}
+	}
+	return &rssOutText{Text: string(h)}
+}
+
+// AtomTime returns a time in the RFC 3339 format used by Atom.
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func atomPersons(names []string) []atomOutPerson {
+	var ps []atomOutPerson
+	for _, n := range names {
+		ps = append(ps, atomOutPerson{Name: n})
+	}
+	return ps
+}
+
+// The atomOut types are used to marshal an Atom document.
+
+type atomOutFeed struct {
+	XMLName xml.Name        `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang    string          `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Title   string          `xml:"title"`
+	ID      string          `xml:"id"`
+	Updated string          `xml:"updated"`
+	Links   []atomOutLink   `xml:"link"`
+	Authors []atomOutPerson `xml:"author"`
+	Icon    string          `xml:"icon,omitempty"`
+	Logo    string          `xml:"logo,omitempty"`
+	Rights  string          `xml:"rights,omitempty"`
+	Entries []atomOutEntry  `xml:"entry"`
+}
+
+type atomOutEntry struct {
+	Title      string            `xml:"title"`
+	ID         string            `xml:"id"`
+	Updated    string            `xml:"updated"`
+	Published  string            `xml:"published,omitempty"`
+	Links      []atomOutLink     `xml:"link"`
+	Authors    []atomOutPerson   `xml:"author"`
+	Categories []atomOutCategory `xml:"category"`
+	Summary    *atomOutText      `xml:"summary"`
+	Content    *atomOutText      `xml:"content"`
+}
+
+type atomOutLink struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length string `xml:"length,attr,omitempty"`
+}
+
+type atomOutPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomOutCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomOutText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// The rssOut types are used to marshal an RSS document.
+
+type rssOutFeed struct {
+	XMLName xml.Name      `xml:"rss"`
+	Version string        `xml:"version,attr"`
+	Channel rssOutChannel `xml:"channel"`
+}
+
+type rssOutChannel struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	Language    string        `xml:"language,omitempty"`
+	Copyright   string        `xml:"copyright,omitempty"`
+	AtomLinks   []atomOutLink `xml:"http://www.w3.org/2005/Atom link"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	Creators    []string      `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Image       *rssOutImage  `xml:"image"`
+	Items       []rssOutItem  `xml:"item"`
+}
+
+type rssOutImage struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type rssOutItem struct {
+	Title       string            `xml:"title,omitempty"`
+	Link        string            `xml:"link,omitempty"`
+	Description *rssOutText       `xml:"description"`
+	Guid        *rssOutGuid       `xml:"guid"`
+	PubDate     string            `xml:"pubDate,omitempty"`
+	Creators    []string          `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories  []string          `xml:"category"`
+	Enclosures  []rssOutEnclosure `xml:"enclosure"`
+	Content     *rssOutText       `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+type rssOutGuid struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+type rssOutEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length string `xml:"length,attr"`
+}
+
+// An rssOutText is written as either character data or a CDATA section.
+type rssOutText struct {
+	Text  string `xml:",chardata"`
+	CDATA string `xml:",cdata"`
+}
@@ -0,0 +1,140 @@
+package webfeed
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const roundTripAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="en">
+	<title>Round &amp; Round</title>
+	<link href="http://example.com/"/>
+	<link rel="self" href="http://example.com/feed.atom"/>
+	<id>http://example.com/feed.atom</id>
+	<updated>2014-03-02T00:00:00Z</updated>
+	<author><name>Feed Author</name></author>
+	<icon>http://example.com/favicon.ico</icon>
+	<logo>http://example.com/logo.png</logo>
+	<rights>Copyright 2014 Example</rights>
+	<entry>
+		<title>First &lt;post&gt;</title>
+		<link href="http://example.com/1"/>
+		<link rel="enclosure" href="http://example.com/1.mp3" type="audio/mpeg" length="1234"/>
+		<id>tag:example.com,2014:1</id>
+		<updated>2014-03-02T00:00:00Z</updated>
+		<published>2014-03-01T00:00:00Z</published>
+		<author><name>Alice</name></author>
+		<category term="Go"/>
+		<summary type="html">&lt;p&gt;Summary &amp;amp; more&lt;/p&gt;</summary>
+		<content type="html">&lt;p&gt;Content with &lt;a href="http://example.com/x"&gt;a link&lt;/a&gt;&lt;/p&gt;</content>
+	</entry>
+	<entry>
+		<title>Second</title>
+		<link href="http://example.com/2"/>
+		<updated>2014-03-01T12:00:00Z</updated>
+	</entry>
+</feed>`
+
+func TestWriteAtomRoundTrip(t *testing.T) {
+	f, err := Read(strings.NewReader(roundTripAtom))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := f.WriteAtom(&buf); err != nil {
+		t.Fatalf("Unexpected error writing: %s", err)
+	}
+	g, format, err := ReadWithFormat(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error rereading: %s\n%s", err, buf.String())
+	}
+	if format != FormatAtom {
+		t.Errorf("Expected format %s, got %s", FormatAtom, format)
+	}
+	if !reflect.DeepEqual(f, g) {
+		t.Errorf("Expected the feed to round trip:\n%+v\ngot\n%+v", f, g)
+	}
+	if len(g.Entries) != 2 || g.Entries[0].Title != "First <post>" || g.Entries[1].Link != "http://example.com/2" {
+		t.Errorf("Unexpected entries %+v", g.Entries)
+	}
+}
+
+func TestWriteAtomDefaults(t *testing.T) {
+	updated := time.Date(2014, 3, 1, 0, 0, 0, 0, time.UTC)
+	f := Feed{
+		Title:   "T",
+		Link:    "http://example.com/",
+		Updated: updated,
+		Entries: []Entry{{Title: "No ID or time", Link: "http://example.com/1"}},
+	}
+	var buf bytes.Buffer
+	if err := f.WriteAtom(&buf); err != nil {
+		t.Fatalf("Unexpected error writing: %s", err)
+	}
+	g, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error rereading: %s", err)
+	}
+	e := g.Entries[0]
+	if e.ID != "http://example.com/1" {
+		t.Errorf("Expected the link as the ID, got [%s]", e.ID)
+	}
+	if !e.When.Equal(updated) {
+		t.Errorf("Expected the feed's time %s, got %s", updated, e.When)
+	}
+}
+
+func TestWriteRSS(t *testing.T) {
+	f, err := Read(strings.NewReader(roundTripAtom))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := f.WriteRSS(&buf); err != nil {
+		t.Fatalf("Unexpected error writing: %s", err)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		"<pubDate>Sun, 02 Mar 2014 00:00:00 +0000</pubDate>",
+		`<guid isPermaLink="false">tag:example.com,2014:1</guid>`,
+		"<![CDATA[<p>Summary &amp; more</p>]]>",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected the output to contain [%s]:\n%s", s, out)
+		}
+	}
+
+	g, format, err := ReadWithFormat(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error rereading: %s\n%s", err, out)
+	}
+	if format != FormatRSS {
+		t.Errorf("Expected format %s, got %s", FormatRSS, format)
+	}
+	if g.Title != f.Title || g.Link != f.Link || g.Self != f.Self || g.Image != f.Image || g.Language != f.Language || g.Rights != f.Rights || !g.Updated.Equal(f.Updated) {
+		t.Errorf("Expected feed header %+v, got %+v", f, g)
+	}
+	if !reflect.DeepEqual(g.Authors, f.Authors) {
+		t.Errorf("Expected authors %v, got %v", f.Authors, g.Authors)
+	}
+	if len(g.Entries) != len(f.Entries) {
+		t.Fatalf("Expected %d entries, got %d", len(f.Entries), len(g.Entries))
+	}
+	for i, e := range f.Entries {
+		h := g.Entries[i]
+		if h.ID != e.ID || h.Title != e.Title || h.Link != e.Link || !h.When.Equal(e.When) {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, e, h)
+		}
+		if !bytes.Equal(h.Summary, e.Summary) || !bytes.Equal(h.Content, e.Content) {
+			t.Errorf("Entry %d: expected summary [%s] and content [%s], got [%s] and [%s]",
+				i, e.Summary, e.Content, h.Summary, h.Content)
+		}
+		if !reflect.DeepEqual(h.Enclosures, e.Enclosures) || !reflect.DeepEqual(h.Categories, e.Categories) ||
+			!reflect.DeepEqual(h.Authors, e.Authors) {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, e, h)
+		}
+	}
+}